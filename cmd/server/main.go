@@ -3,17 +3,21 @@ package main
 import (
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
-	"golang.org/x/time/rate"
 
 	"sub-router/internal/config"
 	"sub-router/internal/handler"
 	"sub-router/internal/middleware"
+	"sub-router/internal/server"
+	"sub-router/internal/upstream"
+	"sub-router/pkg/quota"
 	"sub-router/pkg/transport"
+	"sub-router/pkg/usage"
 )
 
 func main() {
@@ -35,52 +39,128 @@ func main() {
 	}
 
 	// 获取服务器配置
-	port, timeout, rateLimit := config.GetServerConfig()
+	port, timeout, _ := config.GetServerConfig()
 
 	// 初始化全局连接池
 	transport.InitGlobalPool(config.GetTransportConfig())
 	defer transport.CloseGlobalPool()
 
+	// 配置热加载：连接池随传输配置变化重建，并清理出口或超时已变化的 Transport；服务映射等在请求时读取最新快照
+	config.OnReload(func(old, cfg *config.Config) {
+		transport.Global().UpdateConfig(cfg.TransportPool())
+		transport.PruneGlobalPool(cfg)
+		upstream.GlobalRegistry.Prune(cfg.APIMappings)
+		upstream.GlobalRegistry.ReloadCredentials()
+	})
+	if file := config.ConfigFile(); file != "" {
+		watcher, err := config.NewWatcher(file, config.Reload)
+		if err != nil {
+			log.Printf("Config hot reload disabled: %v", err)
+		} else {
+			defer watcher.Stop()
+		}
+	}
+
+	// 启动上游主动健康检查
+	healthChecker := upstream.NewHealthChecker(upstream.GlobalRegistry, transport.ClientForService)
+	healthChecker.Start()
+	defer healthChecker.Stop()
+
+	// 启动出口代理健康检查
+	if hc := config.Get().Proxy.HealthCheck; hc.Enabled {
+		proxyChecker := transport.NewProxyChecker(transport.GlobalProxyHealth,
+			config.AllEgressProxies, hc.Target, hc.Interval, hc.Timeout)
+		proxyChecker.Start()
+		defer proxyChecker.Stop()
+	}
+
+	// 用量日志（修改路径需要重启）
+	if cfg := config.Get().Usage; cfg.Enabled && cfg.LogFile != "" {
+		usageLog, err := usage.OpenFileLog(cfg.LogFile)
+		if err != nil {
+			log.Fatalf("Failed to open usage log: %v", err)
+		}
+		usage.GlobalRecorder.Add(usageLog)
+		defer usageLog.Close()
+	}
+
+	// 客户端配额计数（存储文件修改需要重启，配额规则随配置热加载）。
+	// 未启用配额时只在内存中计数，不创建存储文件
+	quotaFile := ""
+	if quotas := config.Get().Quotas; quotas.Enabled {
+		quotaFile = quotas.StoreFile
+	}
+	quotaStore, err := quota.Open(quotaFile)
+	if err != nil {
+		log.Fatalf("Failed to open quota store: %v", err)
+	}
+	quotaStore.Start(config.Get().Quotas.FlushInterval, func(err error) {
+		logger.Warn("failed to save quota counters", zap.Error(err))
+	})
+	defer quotaStore.Close()
+	usage.GlobalRecorder.Add(middleware.QuotaRecorder{Store: quotaStore})
+
 	// 创建 gin 引擎
-	ginMode := config.GlobalConfig.Server.GinMode // 读取 GIN_MODE
-	gin.SetMode(ginMode)                          // 设置 GIN_MODE
+	ginMode := config.Get().Server.GinMode // 读取 GIN_MODE
+	gin.SetMode(ginMode)                   // 设置 GIN_MODE
 	r := gin.New()
+	// 单段路径（如 /v1）交给 NoRoute 按路由表处理，不重定向到 /v1/
+	r.RedirectTrailingSlash = false
 
 	// 添加中间件（注意顺序）
 	r.Use(middleware.RequestLogger()) // 添加请求日志中间件
 	if ginMode != "release" {
 		r.Use(gin.Logger()) // 仅在非 release 模式下使用日志中间件
 	}
-	r.Use(gin.Recovery())              // 错误恢复
-	r.Use(middleware.Tracing())        // 请求追踪
-	r.Use(middleware.Logger(logger))   // 自定义日志记录
-	r.Use(middleware.Security())       // 安全头
-	r.Use(middleware.IPControl())      // IP 控制
-	r.Use(middleware.Metrics())        // 指标收集
-	r.Use(middleware.Timeout(timeout)) // 超时控制
-	r.Use(middleware.RateLimit(rate.Limit(rateLimit.RequestsPerSecond), rateLimit.Burst))
+	r.Use(gin.Recovery())            // 错误恢复
+	r.Use(middleware.Tracing())      // 请求追踪
+	r.Use(middleware.Logger(logger)) // 自定义日志记录
+	r.Use(middleware.Security())     // 安全头
+	r.Use(middleware.IPControl())    // IP 控制
+	r.Use(middleware.Metrics())      // 指标收集
+
+	// 本地路由使用统一的处理超时；代理路由按服务的 timeouts 配置控制，避免截断流式响应
+	local := r.Group("", middleware.Timeout(timeout))
 
 	// 监控路由
-	if config.GlobalConfig.Monitoring.Metrics.Enabled {
-		r.GET(config.GlobalConfig.Monitoring.Metrics.Path, gin.WrapH(promhttp.Handler()))
+	if config.Get().Monitoring.Metrics.Enabled {
+		local.GET(config.Get().Monitoring.Metrics.Path, gin.WrapH(promhttp.Handler()))
 	}
 
 	// 健康检查路由
-	if config.GlobalConfig.Monitoring.Health.Enabled {
-		r.GET(config.GlobalConfig.Monitoring.Health.DetailedPath, handler.DetailedHealthCheck)
+	if config.Get().Monitoring.Health.Enabled {
+		local.GET(config.Get().Monitoring.Health.DetailedPath, handler.DetailedHealthCheck)
 	}
 
 	// 基础路由
-	r.GET("/", handler.HealthCheck)
-	r.GET("/index.html", handler.HealthCheck)
-	r.GET("/robots.txt", handler.RobotsHandler)
+	local.GET("/", handler.HealthCheck)
+	local.GET("/index.html", handler.HealthCheck)
+	local.GET("/robots.txt", handler.RobotsHandler)
+
+	// API 代理路由：先按路由表选择服务，未匹配时按第一个路径段选择；
+	// /:service/*path 无法匹配的路径（POST /、/v1 等）同样经过路由表
+	proxyChain := []gin.HandlerFunc{middleware.Route(), middleware.Auth(), middleware.RateLimit(), middleware.Quota(quotaStore), handler.ProxyHandler}
+	r.Any("/:service/*path", proxyChain...)
+	r.NoRoute(proxyChain...)
 
-	// API 代理路由（确保路径处理正确）
-	r.Any("/:service/*path", handler.ProxyHandler)
+	// 管理接口使用独立端口
+	if admin := config.Get().Admin; admin.Enabled {
+		adminRouter := gin.New()
+		adminRouter.Use(gin.Recovery(), middleware.Tracing(), middleware.AdminAuth())
+		handler.RegisterAdminRoutes(adminRouter.Group("/admin"))
+		go func() {
+			log.Printf("Admin API starting on port %d...", admin.Port)
+			if err := http.ListenAndServe(fmt.Sprintf(":%d", admin.Port), adminRouter); err != nil {
+				log.Printf("Admin API stopped: %v", err)
+			}
+		}()
+	}
 
-	// 启动服务器
+	// 启动服务器，收到 SIGINT/SIGTERM 时优雅关闭，以便保存配额计数等状态；
+	// 不设置写超时，避免截断流式响应
 	log.Printf("Server starting on port %d...", port)
-	if err := r.Run(fmt.Sprintf(":%d", port)); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	srv := server.NewServer(r, server.Options{Addr: fmt.Sprintf(":%d", port), Logger: logger})
+	if err := srv.Start(); err != nil {
+		logger.Error("Server stopped", zap.Error(err))
 	}
 }
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// MaxArgon2idMemory argon2id 哈希允许的最大内存参数（KiB），避免每次校验占用过多内存
+const MaxArgon2idMemory = 1 << 20
+
+// APIKeysConfig API 密钥认证配置
+type APIKeysConfig struct {
+	Enabled bool           `mapstructure:"enabled"`
+	Keys    []APIKeyConfig `mapstructure:"keys"`
+}
+
+// APIKeyConfig 颁发给客户端的 API 密钥
+type APIKeyConfig struct {
+	Name      string   `mapstructure:"name"`       // 客户端名称，用于日志和指标
+	Key       string   `mapstructure:"key"`        // 明文密钥
+	KeySHA256 string   `mapstructure:"key_sha256"` // 密钥的 SHA-256 十六进制摘要，与 key 二选一
+	Services  []string `mapstructure:"services"`   // 允许访问的服务，为空表示全部
+}
+
+// AllowsService 判断服务是否在允许列表中，"*" 或空列表表示全部
+func AllowsService(services []string, service string) bool {
+	if len(services) == 0 {
+		return true
+	}
+	for _, s := range services {
+		if s == "*" || s == service {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPasswordHash 判断密码是否为支持的哈希格式
+func IsPasswordHash(password string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$", "$argon2id$"} {
+		if strings.HasPrefix(password, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Argon2idHash 解析后的 argon2id 哈希
+type Argon2idHash struct {
+	Memory  uint32 // KiB
+	Time    uint32
+	Threads uint8
+	Salt    []byte
+	Key     []byte
+}
+
+// ParseArgon2id 解析并校验 PHC 格式的 argon2id 哈希：
+// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>
+func ParseArgon2id(encoded string) (*Argon2idHash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, fmt.Errorf("invalid argon2id hash format")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2id version %q", parts[2])
+	}
+	h := &Argon2idHash{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.Memory, &h.Time, &h.Threads); err != nil {
+		return nil, fmt.Errorf("invalid argon2id parameters %q", parts[3])
+	}
+	if h.Time < 1 || h.Threads < 1 {
+		return nil, fmt.Errorf("argon2id time and parallelism must be at least 1")
+	}
+	if h.Memory > MaxArgon2idMemory {
+		return nil, fmt.Errorf("argon2id memory must not exceed %d KiB", MaxArgon2idMemory)
+	}
+	var err error
+	if h.Salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil || len(h.Salt) == 0 {
+		return nil, fmt.Errorf("invalid argon2id salt")
+	}
+	if h.Key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(h.Key) == 0 {
+		return nil, fmt.Errorf("invalid argon2id hash")
+	}
+	return h, nil
+}
+
+// validateAuthConfig 验证已启用的认证配置
+func validateAuthConfig(cfg SecurityConfig) error {
+	if cfg.BasicAuth.Enabled {
+		if err := validateBasicAuth(cfg.BasicAuth); err != nil {
+			return fmt.Errorf("basic auth: %w", err)
+		}
+	}
+	if cfg.APIKeys.Enabled {
+		if err := validateAPIKeys(cfg.APIKeys); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+	return nil
+}
+
+// validateBasicAuth 验证 Basic 认证凭证
+func validateBasicAuth(cfg BasicAuthConfig) error {
+	usernames := make(map[string]bool)
+	for _, cred := range cfg.Credentials {
+		if cred.Username == "" {
+			return fmt.Errorf("username is required")
+		}
+		if usernames[cred.Username] {
+			return fmt.Errorf("duplicate username %q", cred.Username)
+		}
+		usernames[cred.Username] = true
+		if !IsPasswordHash(cred.Password) {
+			return fmt.Errorf("password of %q must be a bcrypt or argon2id hash", cred.Username)
+		}
+		if strings.HasPrefix(cred.Password, "$argon2id$") {
+			if _, err := ParseArgon2id(cred.Password); err != nil {
+				return fmt.Errorf("password of %q: %w", cred.Username, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateAPIKeys 验证 API 密钥
+func validateAPIKeys(cfg APIKeysConfig) error {
+	names := make(map[string]bool)
+	for _, key := range cfg.Keys {
+		if key.Name == "" {
+			return fmt.Errorf("name is required")
+		}
+		if names[key.Name] {
+			return fmt.Errorf("duplicate name %q", key.Name)
+		}
+		names[key.Name] = true
+		if (key.Key == "") == (key.KeySHA256 == "") {
+			return fmt.Errorf("exactly one of key and key_sha256 is required for %q", key.Name)
+		}
+		if key.KeySHA256 != "" {
+			if b, err := hex.DecodeString(key.KeySHA256); err != nil || len(b) != 32 {
+				return fmt.Errorf("invalid key_sha256 for %q", key.Name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultCacheMaxEntrySize 单个缓存响应默认的大小上限
+const DefaultCacheMaxEntrySize = 1 << 20
+
+// CacheConfig 服务响应缓存配置
+type CacheConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	TTL          time.Duration `mapstructure:"ttl"`            // 上游没有给出缓存时间时使用
+	ForceTTL     bool          `mapstructure:"force_ttl"`      // 忽略上游缓存头，总是按 ttl 缓存
+	Methods      []string      `mapstructure:"methods"`        // 可缓存的方法，默认 GET、HEAD
+	KeyHeaders   []string      `mapstructure:"key_headers"`    // 计入缓存键的请求头
+	KeyClient    bool          `mapstructure:"key_client"`     // 按客户端隔离缓存
+	BodyHash     bool          `mapstructure:"body_hash"`      // 将请求体哈希计入缓存键（用于 POST）
+	MaxEntrySize int64         `mapstructure:"max_entry_size"` // 超过该大小的响应不缓存，默认 1MB
+}
+
+// AllowsMethod 判断请求方法是否可缓存
+func (c CacheConfig) AllowsMethod(method string) bool {
+	methods := c.Methods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead}
+	}
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// EntryLimit 获取单个缓存响应的大小上限
+func (c CacheConfig) EntryLimit() int64 {
+	if c.MaxEntrySize > 0 {
+		return c.MaxEntrySize
+	}
+	return DefaultCacheMaxEntrySize
+}
+
+// ResponseCacheConfig 响应缓存存储配置，所有服务共享
+type ResponseCacheConfig struct {
+	MaxBytes int64 `mapstructure:"max_bytes"` // 内存缓存总字节上限
+}
+
+// validateCacheConfig 验证服务缓存配置
+func validateCacheConfig(cfg CacheConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.TTL < 0 || (cfg.ForceTTL && cfg.TTL == 0) {
+		return fmt.Errorf("invalid ttl: %v", cfg.TTL)
+	}
+	if cfg.MaxEntrySize < 0 {
+		return fmt.Errorf("invalid max_entry_size: %d", cfg.MaxEntrySize)
+	}
+	for _, m := range cfg.Methods {
+		switch strings.ToUpper(m) {
+		case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodOptions:
+		default:
+			return fmt.Errorf("method %s cannot be cached", m)
+		}
+	}
+	return nil
+}
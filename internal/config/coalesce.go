@@ -0,0 +1,47 @@
+package config
+
+import "fmt"
+
+const (
+	// DefaultCoalesceMaxWaiters 默认每个进行中的请求最多合并多少个相同请求
+	DefaultCoalesceMaxWaiters = 100
+
+	// DefaultCoalesceMaxBodySize 默认可以共享的响应体大小上限
+	DefaultCoalesceMaxBodySize = 1 << 20
+)
+
+// CoalesceConfig 相同请求合并配置，只对 GET、HEAD 生效
+type CoalesceConfig struct {
+	Enabled     bool     `mapstructure:"enabled"`
+	KeyHeaders  []string `mapstructure:"key_headers"`   // 计入合并键的请求头
+	KeyClient   bool     `mapstructure:"key_client"`    // 只合并同一客户端的请求
+	MaxWaiters  int      `mapstructure:"max_waiters"`   // 超过后的请求单独请求上游，默认 100
+	MaxBodySize int64    `mapstructure:"max_body_size"` // 超过该大小或流式的响应不共享，默认 1MB
+}
+
+// WaiterLimit 获取等待者上限
+func (c CoalesceConfig) WaiterLimit() int {
+	if c.MaxWaiters > 0 {
+		return c.MaxWaiters
+	}
+	return DefaultCoalesceMaxWaiters
+}
+
+// BodyLimit 获取可共享的响应体大小上限
+func (c CoalesceConfig) BodyLimit() int64 {
+	if c.MaxBodySize > 0 {
+		return c.MaxBodySize
+	}
+	return DefaultCoalesceMaxBodySize
+}
+
+// validateCoalesceConfig 验证请求合并配置
+func validateCoalesceConfig(cfg CoalesceConfig) error {
+	if cfg.MaxWaiters < 0 {
+		return fmt.Errorf("invalid max_waiters: %d", cfg.MaxWaiters)
+	}
+	if cfg.MaxBodySize < 0 {
+		return fmt.Errorf("invalid max_body_size: %d", cfg.MaxBodySize)
+	}
+	return nil
+}
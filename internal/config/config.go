@@ -2,15 +2,18 @@ package config
 
 import (
 	"log"
+	"sync/atomic"
 	"time"
 
+	"sub-router/pkg/router"
+
 	"github.com/spf13/viper"
 )
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
 	Port      int           `mapstructure:"port"`
-	Timeout   time.Duration `mapstructure:"timeout"`
+	Timeout   time.Duration `mapstructure:"timeout"` // 非代理请求（健康检查、指标等）的处理超时
 	RateLimit struct {
 		RequestsPerSecond float64 `mapstructure:"requests_per_second"`
 		Burst             int     `mapstructure:"burst"`
@@ -20,14 +23,20 @@ type ServerConfig struct {
 
 // ProxyConfig 代理配置
 type ProxyConfig struct {
-	Enabled bool   `mapstructure:"enabled"`
-	URL     string `mapstructure:"url"`
+	Enabled  bool          `mapstructure:"enabled"`
+	URL      string        `mapstructure:"url"`
+	Fallback []EgressProxy `mapstructure:"fallback"` // url 不可用时依次尝试的代理
+
+	// Egress 命名出口，服务通过 api_mappings 中的 egress 引用
+	Egress      map[string]EgressConfig `mapstructure:"egress"`
+	HealthCheck ProxyHealthCheckConfig  `mapstructure:"health_check"`
 }
 
 // SecurityConfig 安全配置
 type SecurityConfig struct {
 	IPControl IPControlConfig `mapstructure:"ip_control"`
 	BasicAuth BasicAuthConfig `mapstructure:"basic_auth"`
+	APIKeys   APIKeysConfig   `mapstructure:"api_keys"`
 }
 
 // IPControlConfig IP控制配置
@@ -45,8 +54,16 @@ type BasicAuthConfig struct {
 
 // BasicAuthCredentials 认证凭证
 type BasicAuthCredentials struct {
-	Username string `mapstructure:"username"`
-	Password string `mapstructure:"password"`
+	Username string   `mapstructure:"username"`
+	Password string   `mapstructure:"password"` // bcrypt 或 argon2id 哈希
+	Services []string `mapstructure:"services"` // 允许访问的服务，为空表示全部
+}
+
+// AdminConfig 管理接口配置
+type AdminConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Port    int    `mapstructure:"port"`  // 独立监听端口，修改后需重启
+	Token   string `mapstructure:"token"` // 访问令牌，通过 Authorization: Bearer 传递
 }
 
 // MonitoringConfig 监控配置
@@ -87,6 +104,32 @@ type TransportConfig struct {
 	IdleConnTimeout     time.Duration `mapstructure:"idle_conn_timeout"`
 	MaxConnLifetime     time.Duration `mapstructure:"max_conn_lifetime"`
 	TLSSkipVerify       bool          `mapstructure:"tls_skip_verify"`
+
+	// MaxConnsPerHost 每个上游主机的连接数上限，0 表示不限制；流式响应会长时间占用连接
+	MaxConnsPerHost int `mapstructure:"max_conns_per_host"`
+	// MaxResponseHeaderBytes 上游响应头大小上限，默认 1MB
+	MaxResponseHeaderBytes int64 `mapstructure:"max_response_header_bytes"`
+}
+
+// CircuitBreakerConfig 熔断器配置
+type CircuitBreakerConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	ErrorThreshold   int           `mapstructure:"error_threshold"`   // 连续失败多少次后熔断
+	SuccessThreshold int           `mapstructure:"success_threshold"` // 半开状态成功多少次后恢复
+	Timeout          time.Duration `mapstructure:"timeout"`           // 熔断持续时间
+	MaxRequests      int           `mapstructure:"max_requests"`      // 半开状态最大请求数
+}
+
+// RetryConfig 重试配置
+type RetryConfig struct {
+	MaxAttempts       int           `mapstructure:"max_attempts"`        // 最大尝试次数（含首次请求），1 表示不重试
+	RetryOnStatus     []int         `mapstructure:"retry_on_status"`     // 可重试的响应状态码
+	Methods           []string      `mapstructure:"methods"`             // 可重试的请求方法
+	BaseDelay         time.Duration `mapstructure:"base_delay"`          // 指数退避基础延迟
+	MaxDelay          time.Duration `mapstructure:"max_delay"`           // 单次退避最大延迟
+	RespectRetryAfter bool          `mapstructure:"respect_retry_after"` // 是否遵循上游 Retry-After
+	MaxRetryAfter     time.Duration `mapstructure:"max_retry_after"`     // Retry-After 超过该值时不再重试
+	MaxBodySize       int64         `mapstructure:"max_body_size"`       // 可缓存重放的请求体上限（字节）
 }
 
 // CompressionConfig 压缩配置
@@ -97,66 +140,199 @@ type CompressionConfig struct {
 
 // Config 总配置结构
 type Config struct {
-	APIMappings map[string]string `mapstructure:"api_mappings"`
-	Server      ServerConfig      `mapstructure:"server"`
-	Proxy       ProxyConfig       `mapstructure:"proxy"`
-	Security    SecurityConfig    `mapstructure:"security"`
-	Monitoring  MonitoringConfig  `mapstructure:"monitoring"`
-	Tracing     TracingConfig     `mapstructure:"tracing"`
-	Transport   TransportConfig   `mapstructure:"transport"`
-	Compression CompressionConfig `mapstructure:"compression"`
+	APIMappings map[string]APIMapping `mapstructure:"api_mappings"`
+	Server      ServerConfig          `mapstructure:"server"`
+	Proxy       ProxyConfig           `mapstructure:"proxy"`
+	Security    SecurityConfig        `mapstructure:"security"`
+	Monitoring  MonitoringConfig      `mapstructure:"monitoring"`
+	Tracing     TracingConfig         `mapstructure:"tracing"`
+	Transport   TransportConfig       `mapstructure:"transport"`
+	Compression CompressionConfig     `mapstructure:"compression"`
+	Admin       AdminConfig           `mapstructure:"admin"`
+	RateLimit   RateLimitConfig       `mapstructure:"rate_limit"` // 分键限流，server.rate_limit 为全局限流
+	Usage       UsageConfig           `mapstructure:"usage"`
+	Quotas      QuotaConfig           `mapstructure:"quotas"`
+	Cache       ResponseCacheConfig   `mapstructure:"cache"` // 服务在 api_mappings 中通过 cache 启用
+
+	// ModelRouting 按 model 字段路由的虚拟服务，键为虚拟服务名
+	ModelRouting map[string]ModelRouterConfig `mapstructure:"model_routing"`
+
+	// Routes 路由表，按主机名、路径、方法和请求头选择服务；未匹配的请求按 /:service/*path 处理
+	Routes []router.Spec `mapstructure:"routes"`
+
+	// CircuitBreaker 默认熔断器配置，可在 api_mappings 中按服务覆盖
+	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+
+	// Retry 默认重试配置，可在 api_mappings 中按服务覆盖
+	Retry RetryConfig `mapstructure:"retry"`
+
+	// Timeouts 默认上游超时配置，api_mappings 中的 timeouts 按项覆盖
+	Timeouts TimeoutsConfig `mapstructure:"timeouts"`
+
+	// generation 配置快照的代数，Set 时分配，用于判断映射是否来自同一份配置
+	generation uint64
 }
 
-var GlobalConfig Config
+var (
+	// current 当前生效的配置快照，整体替换而不是原地修改
+	current atomic.Pointer[Config]
+
+	// configFile 当前使用的配置文件路径
+	configFile atomic.Value
+
+	// generations 已分配的配置快照代数
+	generations atomic.Uint64
+)
+
+// Get 获取当前配置快照，返回值不应被修改
+func Get() *Config {
+	if cfg := current.Load(); cfg != nil {
+		return cfg
+	}
+	return &Config{}
+}
+
+// Set 替换当前配置快照
+func Set(cfg *Config) {
+	cfg.generation = generations.Add(1)
+	current.Store(cfg)
+}
+
+// ConfigFile 获取当前使用的配置文件路径，未找到配置文件时为空
+func ConfigFile() string {
+	file, _ := configFile.Load().(string)
+	return file
+}
 
 // LoadConfig 加载配置文件
 func LoadConfig() error {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(".")         // 首先在根目录查找
-	viper.AddConfigPath("./configs") // 然后在configs目录查找
+	v := viper.New()
+	v.SetConfigName("config")
+	v.SetConfigType("yaml")
+	v.AddConfigPath(".")         // 首先在根目录查找
+	v.AddConfigPath("./configs") // 然后在configs目录查找
+
+	cfg, err := load(v)
+	if err != nil {
+		return err
+	}
+	configFile.Store(v.ConfigFileUsed())
+	Set(cfg)
+	return nil
+}
+
+// LoadFile 加载指定的配置文件
+func LoadFile(file string) error {
+	cfg, err := reload(file)
+	if err != nil {
+		return err
+	}
+	configFile.Store(file)
+	Set(cfg)
+	return nil
+}
 
+// load 读取、解析并校验配置
+func load(v *viper.Viper) (*Config, error) {
 	// 设置默认值
-	setDefaultConfig()
+	setDefaultConfig(v)
 
-	if err := viper.ReadInConfig(); err != nil {
+	if err := v.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
 			log.Println("No config file found, using defaults")
 		} else {
-			return err
+			return nil, err
 		}
 	}
 
 	// 解析配置到结构体
-	if err := viper.Unmarshal(&GlobalConfig); err != nil {
-		return err
+	cfg := &Config{}
+	if err := v.Unmarshal(cfg, viper.DecodeHook(decodeHook())); err != nil {
+		return nil, err
 	}
-
-	// 监听配置文件变化
-	viper.WatchConfig()
-
-	return nil
+	if err := ValidateConfig(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
 
 // setDefaultConfig 设置默认配置
-func setDefaultConfig() {
+func setDefaultConfig(v *viper.Viper) {
 	// 服务器默认配置
-	viper.SetDefault("server.port", 8080)
-	viper.SetDefault("server.timeout", "30s")
-	viper.SetDefault("server.rate_limit.requests_per_second", 100)
-	viper.SetDefault("server.rate_limit.burst", 200)
-	viper.SetDefault("server.gin_mode", "debug")
+	v.SetDefault("server.port", 8080)
+	v.SetDefault("server.timeout", "30s")
+	v.SetDefault("server.rate_limit.requests_per_second", 100)
+	v.SetDefault("server.rate_limit.burst", 200)
+	v.SetDefault("server.gin_mode", "debug")
 
 	// 传输层默认配置
-	viper.SetDefault("transport.max_idle_conns", 100)
-	viper.SetDefault("transport.max_idle_conns_per_host", 10)
-	viper.SetDefault("transport.idle_conn_timeout", "90s")
-	viper.SetDefault("transport.max_conn_lifetime", "4m")
-	viper.SetDefault("transport.tls_skip_verify", false)
+	v.SetDefault("transport.max_idle_conns", 100)
+	v.SetDefault("transport.max_idle_conns_per_host", 10)
+	v.SetDefault("transport.idle_conn_timeout", "90s")
+	v.SetDefault("transport.max_conn_lifetime", "4m")
+	v.SetDefault("transport.tls_skip_verify", false)
+	v.SetDefault("transport.max_conns_per_host", 0)
+	v.SetDefault("transport.max_response_header_bytes", DefaultMaxResponseHeaderBytes)
+
+	// 出口代理健康检查默认配置
+	v.SetDefault("proxy.health_check.enabled", true)
+	v.SetDefault("proxy.health_check.target", "www.gstatic.com:443")
+	v.SetDefault("proxy.health_check.interval", "30s")
+	v.SetDefault("proxy.health_check.timeout", "5s")
+
+	// 熔断器默认配置，默认关闭，启用后使用以下阈值
+	v.SetDefault("circuit_breaker.enabled", false)
+	v.SetDefault("circuit_breaker.error_threshold", 5)
+	v.SetDefault("circuit_breaker.success_threshold", 2)
+	v.SetDefault("circuit_breaker.timeout", "30s")
+	v.SetDefault("circuit_breaker.max_requests", 2)
+
+	// 重试默认配置，默认不重试，max_attempts 大于 1 时按以下策略重试
+	v.SetDefault("retry.max_attempts", 1)
+	v.SetDefault("retry.retry_on_status", []int{429, 502, 503, 504})
+	v.SetDefault("retry.methods", []string{"GET", "HEAD", "OPTIONS", "PUT", "DELETE"})
+	v.SetDefault("retry.base_delay", "100ms")
+	v.SetDefault("retry.max_delay", "2s")
+	v.SetDefault("retry.respect_retry_after", true)
+	v.SetDefault("retry.max_retry_after", "10s")
+	v.SetDefault("retry.max_body_size", 1<<20)
+
+	// 用量统计默认配置
+	// 用量日志会把每个请求的客户端和用量写入磁盘，需要显式启用
+	v.SetDefault("usage.enabled", false)
+	v.SetDefault("usage.log_file", "logs/usage.log")
+	v.SetDefault("usage.max_body_size", DefaultUsageMaxBodySize)
+
+	// 配额默认配置
+	v.SetDefault("quotas.enabled", false)
+	v.SetDefault("quotas.store_file", "data/quotas.json")
+	v.SetDefault("quotas.flush_interval", "10s")
+	v.SetDefault("quotas.soft_limit", 0.8)
+
+	// 响应缓存默认配置
+	v.SetDefault("cache.max_bytes", 64<<20)
+
+	// 上游超时默认配置，total 为 0 表示不限制，避免截断流式响应
+	v.SetDefault("timeouts.connect", "10s")
+	v.SetDefault("timeouts.tls_handshake", "10s")
+	v.SetDefault("timeouts.response_header", "30s")
+	v.SetDefault("timeouts.total", "0s")
+	v.SetDefault("timeouts.idle_stream", "60s")
+
+	// 分键限流默认配置
+	v.SetDefault("rate_limit.max_keys", 10000)
+	v.SetDefault("rate_limit.store.type", "memory")
+	v.SetDefault("rate_limit.store.prefix", "sub-router:ratelimit:")
+	v.SetDefault("rate_limit.store.timeout", "100ms")
+	v.SetDefault("rate_limit.store.failure_mode", "open")
+
+	// 管理接口默认配置
+	v.SetDefault("admin.enabled", false)
+	v.SetDefault("admin.port", 9090)
 
 	// 压缩默认配置
-	viper.SetDefault("compression.enabled", true)
-	viper.SetDefault("compression.level", "default")
+	v.SetDefault("compression.enabled", true)
+	v.SetDefault("compression.level", "default")
 }
 
 // GetServerConfig 获取服务器配置
@@ -164,44 +340,78 @@ func GetServerConfig() (port int, timeout time.Duration, rateLimit struct {
 	RequestsPerSecond float64
 	Burst             int
 }) {
-	return GlobalConfig.Server.Port,
-		GlobalConfig.Server.Timeout,
+	cfg := Get()
+	return cfg.Server.Port,
+		cfg.Server.Timeout,
 		struct {
 			RequestsPerSecond float64
 			Burst             int
 		}{
-			RequestsPerSecond: GlobalConfig.Server.RateLimit.RequestsPerSecond,
-			Burst:             GlobalConfig.Server.RateLimit.Burst,
+			RequestsPerSecond: cfg.Server.RateLimit.RequestsPerSecond,
+			Burst:             cfg.Server.RateLimit.Burst,
 		}
 }
 
-// GetProxyConfig 获取代理配置
+// GetProxyConfig 获取默认出口代理配置
 func GetProxyConfig() (enabled bool, proxyURL string) {
-	return GlobalConfig.Proxy.Enabled, GlobalConfig.Proxy.URL
+	cfg := Get()
+	return cfg.Proxy.Enabled, cfg.Proxy.URL
 }
 
-// GetAPIMapping 获取 API 映射
-func GetAPIMapping(service string) (string, bool) {
-	baseURL, exists := GlobalConfig.APIMappings[service]
-	return baseURL, exists
+// GetAPIMapping 获取 API 映射，未单独配置的项使用全局默认值
+func GetAPIMapping(service string) (APIMapping, bool) {
+	return Get().APIMapping(service)
 }
 
+// APIMapping 获取 API 映射，未单独配置的项使用全局默认值
+func (c *Config) APIMapping(service string) (APIMapping, bool) {
+	mapping, exists := c.APIMappings[service]
+	if !exists {
+		return mapping, false
+	}
+	if mapping.CircuitBreaker == nil {
+		breaker := c.CircuitBreaker
+		mapping.CircuitBreaker = &breaker
+	}
+	if mapping.Retry == nil {
+		retry := c.Retry
+		mapping.Retry = &retry
+	}
+	timeouts := c.Timeouts.Merge(mapping.Timeouts)
+	mapping.Timeouts = &timeouts
+	mapping.generation = c.generation
+	return mapping, true
+}
+
+// DefaultMaxResponseHeaderBytes 默认上游响应头大小上限
+const DefaultMaxResponseHeaderBytes = 1 << 20
+
 // TransportPoolConfig 连接池配置
 type TransportPoolConfig struct {
-	MaxIdleConns        int
-	MaxIdleConnsPerHost int
-	IdleConnTimeout     time.Duration
-	MaxConnLifetime     time.Duration
-	InsecureSkipVerify  bool
+	MaxIdleConns           int
+	MaxIdleConnsPerHost    int
+	IdleConnTimeout        time.Duration
+	MaxConnLifetime        time.Duration
+	InsecureSkipVerify     bool
+	MaxConnsPerHost        int   // 0 表示不限制
+	MaxResponseHeaderBytes int64 // 0 表示使用默认值
 }
 
 // GetTransportConfig 获取传输配置
 func GetTransportConfig() TransportPoolConfig {
+	return Get().TransportPool()
+}
+
+// TransportPool 获取传输配置
+func (c *Config) TransportPool() TransportPoolConfig {
 	return TransportPoolConfig{
-		MaxIdleConns:        GlobalConfig.Transport.MaxIdleConns,
-		MaxIdleConnsPerHost: GlobalConfig.Transport.MaxIdleConnsPerHost,
-		IdleConnTimeout:     GlobalConfig.Transport.IdleConnTimeout,
-		MaxConnLifetime:     GlobalConfig.Transport.MaxConnLifetime,
-		InsecureSkipVerify:  GlobalConfig.Transport.TLSSkipVerify,
+		MaxIdleConns:        c.Transport.MaxIdleConns,
+		MaxIdleConnsPerHost: c.Transport.MaxIdleConnsPerHost,
+		IdleConnTimeout:     c.Transport.IdleConnTimeout,
+		MaxConnLifetime:     c.Transport.MaxConnLifetime,
+		InsecureSkipVerify:  c.Transport.TLSSkipVerify,
+
+		MaxConnsPerHost:        c.Transport.MaxConnsPerHost,
+		MaxResponseHeaderBytes: c.Transport.MaxResponseHeaderBytes,
 	}
 }
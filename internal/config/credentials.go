@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 上游凭证注入方式
+const (
+	CredentialBearer = "bearer"    // Authorization: Bearer <key>
+	CredentialAPIKey = "x-api-key" // x-api-key: <key>（Anthropic）
+	CredentialQuery  = "query"     // ?key=<key>（Gemini）
+)
+
+// 密钥池选择策略
+const (
+	KeyStrategyRoundRobin = "round_robin" // 轮询
+	KeyStrategyQuota      = "quota"       // 优先使用剩余配额最多的密钥
+)
+
+// CredentialConfig 上游凭证配置，配置后会移除客户端携带的凭证并注入配置的密钥
+type CredentialConfig struct {
+	Type     string          `mapstructure:"type"`     // bearer（默认）、x-api-key、query
+	Header   string          `mapstructure:"header"`   // 自定义请求头名称，覆盖 type 的默认值
+	Format   string          `mapstructure:"format"`   // 请求头取值模板，{key} 替换为密钥
+	Query    string          `mapstructure:"query"`    // 查询参数名称，type 为 query 时默认 key
+	Strip    []string        `mapstructure:"strip"`    // 额外需要移除的客户端请求头
+	Strategy string          `mapstructure:"strategy"` // round_robin（默认）、quota
+	Keys     []CredentialKey `mapstructure:"keys"`
+}
+
+// CredentialKey 上游密钥，value、env、file 三选一
+type CredentialKey struct {
+	Name  string `mapstructure:"name"`  // 用于日志和指标，默认按序号命名
+	Value string `mapstructure:"value"` // 明文密钥
+	Env   string `mapstructure:"env"`   // 从环境变量读取
+	File  string `mapstructure:"file"`  // 从文件读取（去除首尾空白）
+}
+
+// Resolve 读取密钥内容
+func (k CredentialKey) Resolve() (string, error) {
+	switch {
+	case k.Value != "":
+		return k.Value, nil
+	case k.Env != "":
+		value := os.Getenv(k.Env)
+		if value == "" {
+			return "", fmt.Errorf("environment variable %s is empty", k.Env)
+		}
+		return value, nil
+	case k.File != "":
+		data, err := os.ReadFile(k.File)
+		if err != nil {
+			return "", err
+		}
+		value := strings.TrimSpace(string(data))
+		if value == "" {
+			return "", fmt.Errorf("key file %s is empty", k.File)
+		}
+		return value, nil
+	}
+	return "", fmt.Errorf("one of value, env and file is required")
+}
+
+// Injection 获取凭证的注入位置：请求头名称和取值模板，或查询参数名称
+func (c *CredentialConfig) Injection() (header, format, query string) {
+	switch c.Type {
+	case CredentialQuery:
+		query = "key"
+	case CredentialAPIKey:
+		header, format = "X-Api-Key", "{key}"
+	default:
+		header, format = "Authorization", "Bearer {key}"
+	}
+	if c.Query != "" {
+		query = c.Query
+	}
+	if query != "" {
+		return "", "", query
+	}
+	if c.Header != "" {
+		header = c.Header
+		if c.Format == "" {
+			format = "{key}"
+		}
+	}
+	if c.Format != "" {
+		format = c.Format
+	}
+	return header, format, ""
+}
+
+// validateCredentials 验证上游凭证配置，并确认所有密钥可以读取
+func validateCredentials(cfg *CredentialConfig) error {
+	switch cfg.Type {
+	case "", CredentialBearer, CredentialAPIKey, CredentialQuery:
+	default:
+		return fmt.Errorf("unknown credential type: %s", cfg.Type)
+	}
+	switch cfg.Strategy {
+	case "", KeyStrategyRoundRobin, KeyStrategyQuota:
+	default:
+		return fmt.Errorf("unknown key strategy: %s", cfg.Strategy)
+	}
+	if cfg.Format != "" && !strings.Contains(cfg.Format, "{key}") {
+		return fmt.Errorf("format must contain {key}")
+	}
+	if len(cfg.Keys) == 0 {
+		return fmt.Errorf("no key configured")
+	}
+	for i, key := range cfg.Keys {
+		if _, err := key.Resolve(); err != nil {
+			return fmt.Errorf("key %d: %w", i, err)
+		}
+	}
+	return nil
+}
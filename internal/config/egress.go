@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// EgressDirect 表示不经过任何代理直连上游的出口名称
+const EgressDirect = "direct"
+
+// EgressDefault 默认出口名称，即 proxy.url 及其 fallback 列表
+const EgressDefault = "default"
+
+// EgressConfig 出口配置
+type EgressConfig struct {
+	Proxies []EgressProxy `mapstructure:"proxies"` // 按顺序尝试的代理列表
+}
+
+// EgressProxy 出口代理
+type EgressProxy struct {
+	URL      string `mapstructure:"url"` // 支持 http、https、socks5
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+}
+
+// ProxyHealthCheckConfig 出口代理健康检查配置
+type ProxyHealthCheckConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Target   string        `mapstructure:"target"` // 通过代理连接的目标地址（host:port）
+	Interval time.Duration `mapstructure:"interval"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+}
+
+// String 返回带认证信息的代理地址
+func (p EgressProxy) String() string {
+	if p.Username == "" {
+		return p.URL
+	}
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return p.URL
+	}
+	u.User = url.UserPassword(p.Username, p.Password)
+	return u.String()
+}
+
+// EgressFor 获取服务使用的出口名称和代理列表，代理列表为空表示直连
+func EgressFor(mapping APIMapping) (string, []string) {
+	return Get().EgressFor(mapping)
+}
+
+// EgressFor 获取服务使用的出口名称和代理列表，代理列表为空表示直连
+func (c *Config) EgressFor(mapping APIMapping) (string, []string) {
+	switch mapping.Egress {
+	case EgressDirect:
+		return EgressDirect, nil
+	case "", EgressDefault:
+		if !c.Proxy.Enabled || c.Proxy.URL == "" {
+			return EgressDirect, nil
+		}
+		proxies := []string{c.Proxy.URL}
+		for _, p := range c.Proxy.Fallback {
+			proxies = append(proxies, p.String())
+		}
+		return EgressDefault, proxies
+	default:
+		egress := c.Proxy.Egress[mapping.Egress]
+		proxies := make([]string, 0, len(egress.Proxies))
+		for _, p := range egress.Proxies {
+			proxies = append(proxies, p.String())
+		}
+		return mapping.Egress, proxies
+	}
+}
+
+// AllEgressProxies 获取所有配置的出口代理（去重后排序）
+func AllEgressProxies() []string {
+	return Get().AllEgressProxies()
+}
+
+// AllEgressProxies 获取所有配置的出口代理（去重后排序）
+func (c *Config) AllEgressProxies() []string {
+	seen := make(map[string]bool)
+	if _, proxies := c.EgressFor(APIMapping{}); len(proxies) > 0 {
+		for _, p := range proxies {
+			seen[p] = true
+		}
+	}
+	for _, egress := range c.Proxy.Egress {
+		for _, p := range egress.Proxies {
+			seen[p.String()] = true
+		}
+	}
+	result := make([]string, 0, len(seen))
+	for p := range seen {
+		result = append(result, p)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// validateProxyURL 验证代理地址
+func validateProxyURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid proxy url %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("proxy url %q has no host", raw)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// durationType time.Duration 的反射类型
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Export 按配置文件的键名（mapstructure 标签）导出配置，用于 JSON 输出。
+// 键名与 YAML 配置一致，时长输出为 30s 这样的字符串
+func (c *Config) Export() map[string]any {
+	out, _ := exportValue(reflect.ValueOf(*c)).(map[string]any)
+	return out
+}
+
+// exportValue 递归转换配置值：结构体转为以标签为键的 map，时长转为字符串
+func exportValue(v reflect.Value) any {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return exportValue(v.Elem())
+	case reflect.Struct:
+		out := make(map[string]any, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+			if !field.IsExported() || name == "" || name == "-" {
+				continue
+			}
+			out[name] = exportValue(v.Field(i))
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = exportValue(v.Index(i))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		out := make(map[string]any, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			out[fmt.Sprint(iter.Key().Interface())] = exportValue(iter.Value())
+		}
+		return out
+	default:
+		return v.Interface()
+	}
+}
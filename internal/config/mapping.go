@@ -0,0 +1,155 @@
+package config
+
+import (
+	"reflect"
+	"time"
+
+	"sub-router/pkg/transform"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// BackendConfig 后端配置
+type BackendConfig struct {
+	URL      string `mapstructure:"url"`
+	Weight   int    `mapstructure:"weight"`
+	Priority int    `mapstructure:"priority"` // 数值越小优先级越高
+}
+
+// APIMapping API 映射配置
+//
+// 支持三种写法：
+//
+//	openai: "https://api.openai.com"            # 单个地址
+//	groq: ["https://a", "https://b"]             # 后端列表
+//	claude: {strategy: ..., backends: [...]}     # 完整配置
+type APIMapping struct {
+	URL      string          `mapstructure:"url"`
+	Strategy string          `mapstructure:"strategy"` // round_robin, random, weighted_round_robin
+	Backends []BackendConfig `mapstructure:"backends"`
+	Egress   string          `mapstructure:"egress"` // 出口名称：default（默认）、direct 或 proxy.egress 中的名称
+
+	// CircuitBreaker 服务熔断配置，为空时使用全局配置
+	CircuitBreaker *CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+
+	// Retry 服务重试配置，为空时使用全局配置
+	Retry *RetryConfig `mapstructure:"retry"`
+
+	// HealthCheck 后端主动健康检查配置，为空时不检查
+	HealthCheck *UpstreamHealthCheck `mapstructure:"health_check"`
+
+	// Credentials 上游凭证，为空时透传客户端的凭证
+	Credentials *CredentialConfig `mapstructure:"credentials"`
+
+	// Timeouts 服务超时配置，未设置的项使用全局配置
+	Timeouts *TimeoutsConfig `mapstructure:"timeouts"`
+
+	// Cache 响应缓存配置，为空时不缓存
+	Cache *CacheConfig `mapstructure:"cache"`
+
+	// Coalesce 相同请求合并配置，为空时不合并
+	Coalesce *CoalesceConfig `mapstructure:"coalesce"`
+
+	// Transforms 请求、响应转换规则，按顺序执行
+	Transforms []transform.Spec `mapstructure:"transforms"`
+
+	// Translate 上游接口格式：anthropic 或 gemini，客户端以 OpenAI Chat Completions 格式访问；为空时不翻译
+	Translate string `mapstructure:"translate"`
+
+	// Shadow 请求镜像配置，为空时不镜像
+	Shadow *ShadowConfig `mapstructure:"shadow"`
+
+	// generation 所属配置快照的代数，由 Config.APIMapping 设置
+	generation uint64
+}
+
+// Generation 获取映射所属配置快照的代数，同一代数的映射内容相同；不来自已生效的配置时为 0
+func (m APIMapping) Generation() uint64 {
+	return m.generation
+}
+
+// Equal 判断两个映射的配置是否相同，忽略所属的配置快照
+func (m APIMapping) Equal(other APIMapping) bool {
+	m.generation, other.generation = 0, 0
+	return reflect.DeepEqual(m, other)
+}
+
+// SameBackends 判断两个映射的后端、负载均衡策略和熔断配置是否相同，相同时可以沿用后端状态
+func (m APIMapping) SameBackends(other APIMapping) bool {
+	return m.Strategy == other.Strategy &&
+		reflect.DeepEqual(m.Targets(), other.Targets()) &&
+		reflect.DeepEqual(m.CircuitBreaker, other.CircuitBreaker)
+}
+
+// UpstreamHealthCheck 后端主动健康检查配置
+type UpstreamHealthCheck struct {
+	Enabled            bool          `mapstructure:"enabled"`
+	Method             string        `mapstructure:"method"`              // 默认 GET
+	Path               string        `mapstructure:"path"`                // 相对后端地址的检查路径
+	Interval           time.Duration `mapstructure:"interval"`            // 检查间隔
+	Timeout            time.Duration `mapstructure:"timeout"`             // 单次检查超时
+	ExpectedStatus     []int         `mapstructure:"expected_status"`     // 为空时接受 2xx/3xx
+	ExpectedBody       string        `mapstructure:"expected_body"`       // 响应体需包含的字符串
+	MaxLatency         time.Duration `mapstructure:"max_latency"`         // 超过该延迟视为失败
+	HealthyThreshold   int           `mapstructure:"healthy_threshold"`   // 连续成功多少次后标记可用
+	UnhealthyThreshold int           `mapstructure:"unhealthy_threshold"` // 连续失败多少次后标记不可用
+}
+
+// Targets 获取映射的全部后端，单地址写法视为权重为 1 的单个后端
+func (m APIMapping) Targets() []BackendConfig {
+	if len(m.Backends) > 0 {
+		return m.Backends
+	}
+	if m.URL == "" {
+		return nil
+	}
+	return []BackendConfig{{URL: m.URL, Weight: 1}}
+}
+
+var (
+	apiMappingType    = reflect.TypeOf(APIMapping{})
+	backendConfigType = reflect.TypeOf(BackendConfig{})
+	egressProxyType   = reflect.TypeOf(EgressProxy{})
+)
+
+// apiMappingDecodeHook 将字符串和列表形式的映射转换为完整结构
+func apiMappingDecodeHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	switch to {
+	case apiMappingType:
+		switch from.Kind() {
+		case reflect.String:
+			return map[string]interface{}{"url": data}, nil
+		case reflect.Slice:
+			return map[string]interface{}{"backends": data}, nil
+		}
+	case backendConfigType, egressProxyType:
+		if from.Kind() == reflect.String {
+			return map[string]interface{}{"url": data}, nil
+		}
+	}
+	return data, nil
+}
+
+// DecodeAPIMapping 解析 API 映射，支持与配置文件相同的写法
+func DecodeAPIMapping(raw interface{}) (APIMapping, error) {
+	var mapping APIMapping
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       decodeHook(),
+		WeaklyTypedInput: true,
+		Result:           &mapping,
+	})
+	if err != nil {
+		return mapping, err
+	}
+	err = decoder.Decode(raw)
+	return mapping, err
+}
+
+// decodeHook 配置解析钩子，保留 viper 默认的时间和切片解析
+func decodeHook() mapstructure.DecodeHookFunc {
+	return mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		apiMappingDecodeHook,
+	)
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// DefaultModelRouterMaxBodySize 默认读取请求体的大小上限
+const DefaultModelRouterMaxBodySize = 8 << 20
+
+// DefaultModelFallbackStatus 默认切换到后备服务的上游状态码
+var DefaultModelFallbackStatus = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// ModelRouterConfig 按请求体中的 model 字段选择上游服务的虚拟服务，
+// 目标服务需要提供相同的（OpenAI 兼容）接口
+type ModelRouterConfig struct {
+	PathPrefix       string       `mapstructure:"path_prefix"`        // 转发到目标服务时添加的路径前缀，默认为 /<虚拟服务名>
+	Routes           []ModelRoute `mapstructure:"routes"`             // 按顺序匹配，使用第一条匹配的规则
+	Default          string       `mapstructure:"default"`            // 没有 model 字段或未匹配任何规则时使用的服务，为空时拒绝请求
+	FallbackOnStatus []int        `mapstructure:"fallback_on_status"` // 切换到下一个目标的状态码，默认 429、502、503、504
+	MaxBodySize      int64        `mapstructure:"max_body_size"`      // 请求体大小上限，默认 8MB
+}
+
+// ModelRoute 模型路由规则
+type ModelRoute struct {
+	Models  []string      `mapstructure:"models"`  // 模型名，支持通配符，如 gpt-4o*、meta-llama/*
+	Targets []ModelTarget `mapstructure:"targets"` // 按顺序尝试，前一个不可用或返回 fallback_on_status 时使用下一个
+}
+
+// ModelTarget 模型路由目标
+type ModelTarget struct {
+	Service string `mapstructure:"service"` // api_mappings 中的服务名
+	Model   string `mapstructure:"model"`   // 改写后的模型名，{model} 替换为原模型名；为空时不改写
+}
+
+// Rewrite 获取转发给目标服务的模型名
+func (t ModelTarget) Rewrite(model string) string {
+	if t.Model == "" {
+		return model
+	}
+	return strings.ReplaceAll(t.Model, "{model}", model)
+}
+
+// TargetsFor 获取模型的路由目标，未匹配时使用 default
+func (c ModelRouterConfig) TargetsFor(model string) []ModelTarget {
+	if model != "" {
+		for _, route := range c.Routes {
+			for _, pattern := range route.Models {
+				if ok, _ := path.Match(pattern, model); ok {
+					return route.Targets
+				}
+			}
+		}
+	}
+	if c.Default != "" {
+		return []ModelTarget{{Service: c.Default}}
+	}
+	return nil
+}
+
+// Prefix 获取转发路径前缀
+func (c ModelRouterConfig) Prefix(name string) string {
+	if c.PathPrefix != "" {
+		return "/" + strings.Trim(c.PathPrefix, "/")
+	}
+	return "/" + name
+}
+
+// Fallback 判断上游状态码是否切换到下一个目标
+func (c ModelRouterConfig) Fallback(status int) bool {
+	codes := c.FallbackOnStatus
+	if codes == nil {
+		codes = DefaultModelFallbackStatus
+	}
+	for _, code := range codes {
+		if code == status {
+			return true
+		}
+	}
+	return false
+}
+
+// BodyLimit 获取请求体大小上限
+func (c ModelRouterConfig) BodyLimit() int64 {
+	if c.MaxBodySize > 0 {
+		return c.MaxBodySize
+	}
+	return DefaultModelRouterMaxBodySize
+}
+
+// GetModelRouter 获取模型路由虚拟服务
+func GetModelRouter(name string) (ModelRouterConfig, bool) {
+	router, ok := Get().ModelRouting[name]
+	return router, ok
+}
+
+// validateModelRouting 验证模型路由配置，目标服务必须在 api_mappings 中存在
+func validateModelRouting(routers map[string]ModelRouterConfig, mappings map[string]APIMapping) error {
+	for name, router := range routers {
+		if _, ok := mappings[name]; ok {
+			return fmt.Errorf("%q: conflicts with api mapping of the same name", name)
+		}
+		if router.Default != "" {
+			if _, ok := mappings[router.Default]; !ok {
+				return fmt.Errorf("%q: unknown default service %q", name, router.Default)
+			}
+		}
+		if len(router.Routes) == 0 && router.Default == "" {
+			return fmt.Errorf("%q: no routes configured", name)
+		}
+		if router.MaxBodySize < 0 {
+			return fmt.Errorf("%q: invalid max_body_size: %d", name, router.MaxBodySize)
+		}
+		for _, code := range router.FallbackOnStatus {
+			if code < 400 || code > 599 {
+				return fmt.Errorf("%q: invalid fallback status %d", name, code)
+			}
+		}
+		for i, route := range router.Routes {
+			if len(route.Models) == 0 || len(route.Targets) == 0 {
+				return fmt.Errorf("%q: route %d requires models and targets", name, i)
+			}
+			for _, pattern := range route.Models {
+				if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+					return fmt.Errorf("%q: route %d: invalid model pattern %q", name, i, pattern)
+				}
+			}
+			for _, target := range route.Targets {
+				if _, ok := mappings[target.Service]; !ok {
+					return fmt.Errorf("%q: route %d: unknown service %q", name, i, target.Service)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"sub-router/pkg/quota"
+)
+
+// QuotaConfig 按客户端（API 密钥或 Basic 认证用户）的每日、每月配额配置
+type QuotaConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	StoreFile     string        `mapstructure:"store_file"`     // 计数持久化文件，为空时只保存在内存中
+	FlushInterval time.Duration `mapstructure:"flush_interval"` // 持久化间隔
+	SoftLimit     float64       `mapstructure:"soft_limit"`     // 用量达到上限的该比例时返回警告响应头
+	Prices        []ModelPrice  `mapstructure:"prices"`
+	Limits        []QuotaLimit  `mapstructure:"limits"`
+}
+
+// ModelPrice 模型价格，单位为每百万 token 的价格
+type ModelPrice struct {
+	Model      string  `mapstructure:"model"` // 模型名，支持通配符，如 gpt-4o*
+	Prompt     float64 `mapstructure:"prompt"`
+	Completion float64 `mapstructure:"completion"`
+	Cached     float64 `mapstructure:"cached"` // 命中缓存的提示词价格，为 0 时按 prompt 计
+}
+
+// QuotaLimit 客户端配额
+type QuotaLimit struct {
+	Client  string       `mapstructure:"client"` // 客户端名称，支持通配符，* 匹配所有客户端（含未认证的 anonymous）
+	Daily   quota.Amount `mapstructure:"daily"`
+	Monthly quota.Amount `mapstructure:"monthly"`
+}
+
+// LimitFor 获取客户端的配额，使用第一条匹配的规则
+func (c QuotaConfig) LimitFor(client string) (quota.Limits, bool) {
+	for _, limit := range c.Limits {
+		if ok, _ := path.Match(limit.Client, client); ok {
+			return quota.Limits{Daily: limit.Daily, Monthly: limit.Monthly}, true
+		}
+	}
+	return quota.Limits{}, false
+}
+
+// Cost 按价格表估算费用，没有匹配的价格时返回 0
+func (c QuotaConfig) Cost(model string, prompt, completion, cached int64) float64 {
+	for _, price := range c.Prices {
+		if ok, _ := path.Match(price.Model, model); !ok {
+			continue
+		}
+		cachedPrice := price.Cached
+		if cachedPrice == 0 {
+			cachedPrice = price.Prompt
+		}
+		return (float64(prompt-cached)*price.Prompt +
+			float64(cached)*cachedPrice +
+			float64(completion)*price.Completion) / 1e6
+	}
+	return 0
+}
+
+// validateQuotaConfig 验证配额配置
+func validateQuotaConfig(cfg QuotaConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.SoftLimit < 0 || cfg.SoftLimit > 1 {
+		return fmt.Errorf("invalid soft_limit: %v", cfg.SoftLimit)
+	}
+	for i, price := range cfg.Prices {
+		if _, err := path.Match(price.Model, ""); err != nil || price.Model == "" {
+			return fmt.Errorf("price %d: invalid model pattern %q", i, price.Model)
+		}
+		if price.Prompt < 0 || price.Completion < 0 || price.Cached < 0 {
+			return fmt.Errorf("price %d: negative price", i)
+		}
+	}
+	for i, limit := range cfg.Limits {
+		if _, err := path.Match(limit.Client, ""); err != nil || limit.Client == "" {
+			return fmt.Errorf("limit %d: invalid client pattern %q", i, limit.Client)
+		}
+		for _, amount := range []quota.Amount{limit.Daily, limit.Monthly} {
+			if amount.Requests < 0 || amount.Tokens < 0 || amount.Cost < 0 {
+				return fmt.Errorf("limit %d: negative quota", i)
+			}
+		}
+	}
+	return nil
+}
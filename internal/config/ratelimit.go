@@ -0,0 +1,159 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// 限流键的组成部分
+const (
+	RateLimitKeyGlobal  = "global"  // 所有请求共享
+	RateLimitKeyIP      = "ip"      // 客户端 IP
+	RateLimitKeyClient  = "client"  // 认证后的客户端名称，未认证时使用 IP
+	RateLimitKeyService = "service" // 目标服务
+)
+
+// 限流存储类型
+const (
+	RateLimitStoreMemory = "memory" // 进程内存储，每个实例独立限流
+	RateLimitStoreRedis  = "redis"  // Redis 协议存储，所有实例共享限流
+)
+
+// 限流存储不可用时的处理方式
+const (
+	RateLimitFailOpen   = "open"   // 放行请求
+	RateLimitFailClosed = "closed" // 拒绝请求
+)
+
+// RateLimitConfig 分键限流配置
+type RateLimitConfig struct {
+	MaxKeys int                  `mapstructure:"max_keys"` // 内存中最多保留的限流键，超过后淘汰最久未使用的键
+	Store   RateLimitStoreConfig `mapstructure:"store"`
+	Rules   []RateLimitRule      `mapstructure:"rules"`
+}
+
+// RateLimitStoreConfig 限流存储配置，修改后需重启
+type RateLimitStoreConfig struct {
+	Type        string        `mapstructure:"type"` // memory（默认）、redis
+	Addr        string        `mapstructure:"addr"` // Redis 地址 host:port
+	Password    string        `mapstructure:"password"`
+	DB          int           `mapstructure:"db"`
+	Prefix      string        `mapstructure:"prefix"`       // 键前缀
+	Timeout     time.Duration `mapstructure:"timeout"`      // 单次请求超时
+	PoolSize    int           `mapstructure:"pool_size"`    // 连接数上限，默认 16
+	FailureMode string        `mapstructure:"failure_mode"` // open（默认）、closed
+}
+
+// RateLimitRule 限流规则，请求需要同时满足所有匹配的规则
+type RateLimitRule struct {
+	Name              string              `mapstructure:"name"`
+	Key               string              `mapstructure:"key"` // ip、client、service、global 或用 + 组合，如 client+service
+	RequestsPerSecond float64             `mapstructure:"requests_per_second"`
+	Burst             int                 `mapstructure:"burst"`
+	Services          []string            `mapstructure:"services"`  // 只对这些服务生效，为空表示全部
+	Overrides         []RateLimitOverride `mapstructure:"overrides"` // 按键值单独设置速率
+}
+
+// RateLimitOverride 针对特定键值的限流参数
+type RateLimitOverride struct {
+	Match             string  `mapstructure:"match"` // 键值，组合键用 + 连接，如 team-a+openai
+	RequestsPerSecond float64 `mapstructure:"requests_per_second"`
+	Burst             int     `mapstructure:"burst"`
+}
+
+// KeyParts 获取限流键的组成部分
+func (r RateLimitRule) KeyParts() []string {
+	return strings.Split(r.Key, "+")
+}
+
+// Class 获取规范化的键类别，用于指标标签
+func (r RateLimitRule) Class() string {
+	return strings.Join(r.KeyParts(), "+")
+}
+
+// Limit 获取键值对应的速率和突发容量
+func (r RateLimitRule) Limit(value string) (float64, int) {
+	for _, o := range r.Overrides {
+		if o.Match == value {
+			return o.RequestsPerSecond, burstOrDefault(o.Burst, o.RequestsPerSecond)
+		}
+	}
+	return r.RequestsPerSecond, burstOrDefault(r.Burst, r.RequestsPerSecond)
+}
+
+// RateLimitRules 获取生效的限流规则，server.rate_limit 作为全局规则
+func (c *Config) RateLimitRules() []RateLimitRule {
+	rules := make([]RateLimitRule, 0, len(c.RateLimit.Rules)+1)
+	if global := c.Server.RateLimit; global.RequestsPerSecond > 0 {
+		rules = append(rules, RateLimitRule{
+			Name:              RateLimitKeyGlobal,
+			Key:               RateLimitKeyGlobal,
+			RequestsPerSecond: global.RequestsPerSecond,
+			Burst:             global.Burst,
+		})
+	}
+	return append(rules, c.RateLimit.Rules...)
+}
+
+// burstOrDefault 未配置突发容量时使用每秒请求数（至少为 1）
+func burstOrDefault(burst int, rps float64) int {
+	if burst > 0 {
+		return burst
+	}
+	if rps < 1 {
+		return 1
+	}
+	return int(rps)
+}
+
+// validateRateLimitConfig 验证限流配置
+func validateRateLimitConfig(cfg RateLimitConfig) error {
+	if cfg.MaxKeys < 0 {
+		return fmt.Errorf("invalid max keys: %d", cfg.MaxKeys)
+	}
+	if cfg.Store.PoolSize < 0 {
+		return fmt.Errorf("invalid store pool size: %d", cfg.Store.PoolSize)
+	}
+	switch cfg.Store.Type {
+	case "", RateLimitStoreMemory:
+	case RateLimitStoreRedis:
+		if _, _, err := net.SplitHostPort(cfg.Store.Addr); err != nil {
+			return fmt.Errorf("invalid redis addr %q: %w", cfg.Store.Addr, err)
+		}
+	default:
+		return fmt.Errorf("unknown store type: %s", cfg.Store.Type)
+	}
+	switch cfg.Store.FailureMode {
+	case "", RateLimitFailOpen, RateLimitFailClosed:
+	default:
+		return fmt.Errorf("unknown failure mode: %s", cfg.Store.FailureMode)
+	}
+	names := make(map[string]bool)
+	for _, rule := range cfg.Rules {
+		if rule.Name == "" || rule.Name == RateLimitKeyGlobal {
+			return fmt.Errorf("rule name is required and must not be %q", RateLimitKeyGlobal)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate rule %q", rule.Name)
+		}
+		names[rule.Name] = true
+		for _, part := range rule.KeyParts() {
+			switch part {
+			case RateLimitKeyGlobal, RateLimitKeyIP, RateLimitKeyClient, RateLimitKeyService:
+			default:
+				return fmt.Errorf("rule %q: unknown key %q", rule.Name, part)
+			}
+		}
+		if rule.RequestsPerSecond <= 0 || rule.Burst < 0 {
+			return fmt.Errorf("rule %q: invalid rate %v/%d", rule.Name, rule.RequestsPerSecond, rule.Burst)
+		}
+		for _, o := range rule.Overrides {
+			if o.Match == "" || o.RequestsPerSecond <= 0 || o.Burst < 0 {
+				return fmt.Errorf("rule %q: invalid override %q", rule.Name, o.Match)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"net/url"
+
+	"sub-router/pkg/transform"
+)
+
+// redacted 脱敏后的占位符
+const redacted = "******"
+
+// Redacted 返回隐藏了密码、令牌等敏感信息的配置副本
+func (c *Config) Redacted() *Config {
+	cfg := *c
+
+	if c.APIMappings != nil {
+		cfg.APIMappings = make(map[string]APIMapping, len(c.APIMappings))
+		for name, mapping := range c.APIMappings {
+			mapping.URL = redactURL(mapping.URL)
+			if mapping.Backends != nil {
+				backends := make([]BackendConfig, len(mapping.Backends))
+				for i, backend := range mapping.Backends {
+					backend.URL = redactURL(backend.URL)
+					backends[i] = backend
+				}
+				mapping.Backends = backends
+			}
+			mapping.Transforms = redactTransforms(mapping.Transforms)
+			if creds := mapping.Credentials; creds != nil {
+				redactedCreds := *creds
+				redactedCreds.Keys = make([]CredentialKey, len(creds.Keys))
+				for i, key := range creds.Keys {
+					key.Value = redactString(key.Value)
+					redactedCreds.Keys[i] = key
+				}
+				mapping.Credentials = &redactedCreds
+			}
+			cfg.APIMappings[name] = mapping
+		}
+	}
+
+	cfg.Proxy.URL = redactURL(c.Proxy.URL)
+	cfg.Proxy.Fallback = redactProxies(c.Proxy.Fallback)
+	if c.Proxy.Egress != nil {
+		cfg.Proxy.Egress = make(map[string]EgressConfig, len(c.Proxy.Egress))
+		for name, egress := range c.Proxy.Egress {
+			cfg.Proxy.Egress[name] = EgressConfig{Proxies: redactProxies(egress.Proxies)}
+		}
+	}
+
+	if creds := c.Security.BasicAuth.Credentials; creds != nil {
+		cfg.Security.BasicAuth.Credentials = make([]BasicAuthCredentials, len(creds))
+		for i, cred := range creds {
+			cred.Password = redactString(cred.Password)
+			cfg.Security.BasicAuth.Credentials[i] = cred
+		}
+	}
+
+	if keys := c.Security.APIKeys.Keys; keys != nil {
+		cfg.Security.APIKeys.Keys = make([]APIKeyConfig, len(keys))
+		for i, key := range keys {
+			key.Key = redactString(key.Key)
+			key.KeySHA256 = redactString(key.KeySHA256)
+			cfg.Security.APIKeys.Keys[i] = key
+		}
+	}
+
+	cfg.RateLimit.Store.Password = redactString(c.RateLimit.Store.Password)
+	cfg.Admin.Token = redactString(c.Admin.Token)
+	return &cfg
+}
+
+// redactTransforms 隐藏转换规则设置的请求头和查询参数取值，这些值通常是上游凭证
+func redactTransforms(specs []transform.Spec) []transform.Spec {
+	if specs == nil {
+		return nil
+	}
+	result := make([]transform.Spec, len(specs))
+	for i, spec := range specs {
+		spec.Request.Headers.Set = redactValues(spec.Request.Headers.Set)
+		spec.Request.Headers.Add = redactValues(spec.Request.Headers.Add)
+		spec.Request.Query.Set = redactValues(spec.Request.Query.Set)
+		result[i] = spec
+	}
+	return result
+}
+
+// redactValues 隐藏名称和值列表中的值
+func redactValues(values []transform.NameValue) []transform.NameValue {
+	if values == nil {
+		return nil
+	}
+	result := make([]transform.NameValue, len(values))
+	for i, v := range values {
+		v.Value = redactString(v.Value)
+		result[i] = v
+	}
+	return result
+}
+
+// redactProxies 隐藏代理列表中的认证信息
+func redactProxies(proxies []EgressProxy) []EgressProxy {
+	if proxies == nil {
+		return nil
+	}
+	result := make([]EgressProxy, len(proxies))
+	for i, p := range proxies {
+		p.URL = redactURL(p.URL)
+		p.Password = redactString(p.Password)
+		result[i] = p
+	}
+	return result
+}
+
+// redactURL 隐藏 URL 中的密码，只有用户名时用户名通常就是令牌，同样隐藏
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	} else {
+		u.User = url.User(redacted)
+	}
+	return u.String()
+}
+
+// redactString 隐藏非空的敏感字符串
+func redactString(s string) string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"sub-router/pkg/metrics"
+
+	"github.com/spf13/viper"
+)
+
+// ReloadStatus 配置热加载状态
+type ReloadStatus struct {
+	File        string    `json:"file"`
+	Reloads     int       `json:"reloads"`
+	Failures    int       `json:"failures"`
+	LastReload  time.Time `json:"last_reload,omitempty"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+var (
+	// reloadMu 保证同一时间只有一次热加载
+	reloadMu sync.Mutex
+	status   ReloadStatus
+
+	hooksMu sync.RWMutex
+	hooks   []func(old, cfg *Config)
+)
+
+// OnReload 注册配置热加载成功后的回调，回调按注册顺序执行
+func OnReload(fn func(old, cfg *Config)) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	hooks = append(hooks, fn)
+}
+
+// Reload 重新读取配置文件，校验通过后原子替换当前配置；
+// 校验失败时保留原配置并返回错误
+func Reload() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	file := ConfigFile()
+	status.File = file
+	status.Reloads++
+	status.LastReload = time.Now()
+
+	cfg, err := reload(file)
+	if err != nil {
+		status.Failures++
+		status.LastError = err.Error()
+		metrics.ConfigReloads.WithLabelValues("failure").Inc()
+		metrics.ConfigLastReloadSuccess.Set(0)
+		log.Printf("config reload failed, keeping previous config: file=%s error=%v", file, err)
+		return err
+	}
+
+	old := swap(cfg)
+
+	status.LastSuccess = status.LastReload
+	status.LastError = ""
+	metrics.ConfigReloads.WithLabelValues("success").Inc()
+	metrics.ConfigLastReloadSuccess.Set(1)
+	metrics.ConfigLastReloadTimestamp.Set(float64(status.LastSuccess.Unix()))
+
+	// 端口和运行模式在启动后无法变更
+	if old.Server.Port != cfg.Server.Port || old.Server.GinMode != cfg.Server.GinMode {
+		log.Printf("config reload: server.port and server.gin_mode changes require a restart")
+	}
+	log.Printf("config reloaded: file=%s services=%d", file, len(cfg.APIMappings))
+
+	runHooks(old, cfg)
+	return nil
+}
+
+// Update 在当前配置的副本上修改并原子替换，校验失败时不生效。
+// fn 只能替换 APIMappings 中的条目，其他嵌套切片和映射与当前配置共享。
+// 运行时修改不会写回配置文件，下次从文件加载时会被覆盖
+func Update(fn func(cfg *Config)) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	current := Get()
+	cfg := *current
+	cfg.generation = 0
+	cfg.APIMappings = make(map[string]APIMapping, len(current.APIMappings))
+	for name, mapping := range current.APIMappings {
+		cfg.APIMappings[name] = mapping
+	}
+	fn(&cfg)
+
+	if err := ValidateConfig(&cfg); err != nil {
+		return err
+	}
+	old := swap(&cfg)
+	log.Printf("config updated at runtime: services=%d", len(cfg.APIMappings))
+
+	runHooks(old, &cfg)
+	return nil
+}
+
+// swap 替换当前配置并返回原配置
+func swap(cfg *Config) *Config {
+	old := Get()
+	Set(cfg)
+	return old
+}
+
+// runHooks 依次执行热加载回调
+func runHooks(old, cfg *Config) {
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+	for _, hook := range hooks {
+		hook(old, cfg)
+	}
+}
+
+// reload 从指定文件加载新配置
+func reload(file string) (*Config, error) {
+	if file == "" {
+		return nil, fmt.Errorf("no config file loaded")
+	}
+	v := viper.New()
+	v.SetConfigFile(file)
+	return load(v)
+}
+
+// GetReloadStatus 获取配置热加载状态
+func GetReloadStatus() ReloadStatus {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	s := status
+	s.File = ConfigFile()
+	return s
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLoadBundledConfig(t *testing.T) {
+	if err := LoadFile("../../configs/config.yaml"); err != nil {
+		t.Fatalf("Bundled config should be valid: %v", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, file, "http://a.example.com", 10)
+	if err := LoadFile(file); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	// 用量日志需要显式启用
+	if Get().Usage.Enabled {
+		t.Error("Expected usage to be disabled by default")
+	}
+	// 熔断和重试需要显式启用
+	if cfg := Get(); cfg.CircuitBreaker.Enabled || cfg.Retry.MaxAttempts != 1 || cfg.CircuitBreaker.ErrorThreshold != 5 {
+		t.Errorf("Expected circuit breaker and retry to be disabled by default, got %+v %+v", cfg.CircuitBreaker, cfg.Retry)
+	}
+
+	var calls int
+	OnReload(func(old, cfg *Config) {
+		if old.Server.RateLimit.RequestsPerSecond == cfg.Server.RateLimit.RequestsPerSecond {
+			t.Errorf("Expected old and new snapshots to differ")
+		}
+		calls++
+	})
+
+	// 合法修改立即生效
+	writeConfig(t, file, "http://b.example.com", 20)
+	if err := Reload(); err != nil {
+		t.Fatalf("Failed to reload: %v", err)
+	}
+	if m, _ := GetAPIMapping("test"); m.URL != "http://b.example.com" || Get().Server.RateLimit.RequestsPerSecond != 20 {
+		t.Errorf("Config not reloaded: %+v", m)
+	}
+	if calls != 1 {
+		t.Errorf("Expected reload hook to be called once, got %d", calls)
+	}
+
+	// 非法修改被拒绝，保留原配置
+	writeConfig(t, file, "ftp://c.example.com", 30)
+	if err := Reload(); err == nil {
+		t.Fatal("Expected reload to fail validation")
+	}
+	if m, _ := GetAPIMapping("test"); m.URL != "http://b.example.com" {
+		t.Errorf("Expected previous config to be kept, got %s", m.URL)
+	}
+	if status := GetReloadStatus(); status.LastError == "" || status.Failures != 1 {
+		t.Errorf("Unexpected reload status: %+v", status)
+	}
+	if calls != 1 {
+		t.Errorf("Hook should not run on failed reload")
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, file, "http://a.example.com", 10)
+
+	changed := make(chan struct{}, 1)
+	w, err := NewWatcher(file, func() error {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer w.Stop()
+
+	writeConfig(t, file, "http://b.example.com", 20)
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected change callback")
+	}
+}
+
+func writeConfig(t *testing.T, file, url string, rps int) {
+	t.Helper()
+	content := "server:\n" +
+		"  port: 8080\n" +
+		"  rate_limit:\n" +
+		"    requests_per_second: " + strconv.Itoa(rps) + "\n" +
+		"api_mappings:\n" +
+		"  test: " + url + "\n"
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
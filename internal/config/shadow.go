@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultShadowMaxBodySize 默认镜像的请求体和比较的响应体大小上限
+	DefaultShadowMaxBodySize = 1 << 20
+
+	// DefaultShadowTimeout 默认影子请求超时
+	DefaultShadowTimeout = 60 * time.Second
+
+	// DefaultShadowMaxConcurrent 默认每个影子服务同时进行的请求上限
+	DefaultShadowMaxConcurrent = 32
+)
+
+// ShadowConfig 请求镜像配置：按比例将请求异步复制到影子服务，丢弃影子响应，只记录与主响应的对比结果
+type ShadowConfig struct {
+	Service       string        `mapstructure:"service"`        // 影子服务：api_mappings 中的名称
+	Percent       float64       `mapstructure:"percent"`        // 镜像的请求比例，0-100
+	CompareBody   bool          `mapstructure:"compare_body"`   // 比较响应体，JSON 按字段比较，流式响应不比较
+	IgnoreFields  []string      `mapstructure:"ignore_fields"`  // 比较时忽略的 JSON 字段，如 id、usage、choices.*.message.content
+	MaxBodySize   int64         `mapstructure:"max_body_size"`  // 超过该大小的请求不镜像，超过的响应体不比较，默认 1MB
+	Timeout       time.Duration `mapstructure:"timeout"`        // 影子请求超时，默认 60s
+	MaxConcurrent int           `mapstructure:"max_concurrent"` // 同时进行的影子请求上限，超过时不镜像，默认 32
+}
+
+// BodyLimit 获取请求体和响应体大小上限
+func (c ShadowConfig) BodyLimit() int64 {
+	if c.MaxBodySize > 0 {
+		return c.MaxBodySize
+	}
+	return DefaultShadowMaxBodySize
+}
+
+// RequestTimeout 获取影子请求超时
+func (c ShadowConfig) RequestTimeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return DefaultShadowTimeout
+}
+
+// ConcurrencyLimit 获取同时进行的影子请求上限
+func (c ShadowConfig) ConcurrencyLimit() int {
+	if c.MaxConcurrent > 0 {
+		return c.MaxConcurrent
+	}
+	return DefaultShadowMaxConcurrent
+}
+
+// Ignored 判断 JSON 字段（以 . 分隔，数组下标为数字）是否忽略比较，忽略的字段包含其所有子字段。
+// 每一段按通配符匹配
+func (c ShadowConfig) Ignored(field string) bool {
+	segments := strings.Split(field, ".")
+	for _, pattern := range c.IgnoreFields {
+		parts := strings.Split(pattern, ".")
+		if len(parts) > len(segments) {
+			continue
+		}
+		matched := true
+		for i, part := range parts {
+			if ok, _ := path.Match(part, segments[i]); !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// validateShadows 验证请求镜像配置，影子服务必须是配置了上游凭证的其他 API 映射
+// （客户端凭证不会转发给影子服务）
+func validateShadows(mappings map[string]APIMapping) error {
+	for service, mapping := range mappings {
+		cfg := mapping.Shadow
+		if cfg == nil {
+			continue
+		}
+		shadow, ok := mappings[cfg.Service]
+		if !ok || cfg.Service == service {
+			return fmt.Errorf("api mapping %q: invalid shadow service %q", service, cfg.Service)
+		}
+		if shadow.Credentials == nil {
+			return fmt.Errorf("api mapping %q: shadow service %q requires credentials", service, cfg.Service)
+		}
+		if cfg.Percent < 0 || cfg.Percent > 100 {
+			return fmt.Errorf("api mapping %q: invalid shadow percent: %v", service, cfg.Percent)
+		}
+		if cfg.MaxBodySize < 0 || cfg.Timeout < 0 || cfg.MaxConcurrent < 0 {
+			return fmt.Errorf("api mapping %q: invalid shadow limits", service)
+		}
+		for _, field := range cfg.IgnoreFields {
+			for _, part := range strings.Split(field, ".") {
+				if _, err := path.Match(part, ""); part == "" || err != nil {
+					return fmt.Errorf("api mapping %q: invalid shadow ignore field %q", service, field)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -4,7 +4,7 @@ import "time"
 
 // LoadTestConfig 加载测试配置
 func LoadTestConfig() {
-	GlobalConfig = Config{
+	Set(&Config{
 		Server: ServerConfig{
 			Port:    8080,
 			Timeout: 30 * time.Second,
@@ -32,5 +32,5 @@ func LoadTestConfig() {
 				},
 			},
 		},
-	}
+	})
 }
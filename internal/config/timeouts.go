@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeoutsConfig 上游请求超时配置，0 表示不限制。
+//
+// 服务中的配置按项覆盖全局配置：0 表示使用全局值，负数（如 -1）表示该服务不限制
+type TimeoutsConfig struct {
+	Connect        time.Duration `mapstructure:"connect"`         // 建立 TCP 连接（含出口代理握手）
+	TLSHandshake   time.Duration `mapstructure:"tls_handshake"`   // TLS 握手
+	ResponseHeader time.Duration `mapstructure:"response_header"` // 请求发出后等待响应头
+	Total          time.Duration `mapstructure:"total"`           // 整个请求（含重试和流式响应），默认不限制
+	IdleStream     time.Duration `mapstructure:"idle_stream"`     // 流式响应两次收到数据之间的最大间隔
+}
+
+// Merge 用 override 中非零的项覆盖当前配置，负数覆盖为 0（不限制）
+func (t TimeoutsConfig) Merge(override *TimeoutsConfig) TimeoutsConfig {
+	if override == nil {
+		return t
+	}
+	merge := func(d *time.Duration, o time.Duration) {
+		switch {
+		case o > 0:
+			*d = o
+		case o < 0:
+			*d = 0
+		}
+	}
+	merge(&t.Connect, override.Connect)
+	merge(&t.TLSHandshake, override.TLSHandshake)
+	merge(&t.ResponseHeader, override.ResponseHeader)
+	merge(&t.Total, override.Total)
+	merge(&t.IdleStream, override.IdleStream)
+	return t
+}
+
+// validateTimeouts 验证全局超时配置
+func validateTimeouts(cfg TimeoutsConfig) error {
+	for name, d := range map[string]time.Duration{
+		"connect":         cfg.Connect,
+		"tls_handshake":   cfg.TLSHandshake,
+		"response_header": cfg.ResponseHeader,
+		"total":           cfg.Total,
+		"idle_stream":     cfg.IdleStream,
+	} {
+		if d < 0 {
+			return fmt.Errorf("invalid %s timeout: %v", name, d)
+		}
+	}
+	if cfg.Total > 0 && cfg.ResponseHeader > cfg.Total {
+		return fmt.Errorf("response_header timeout %v exceeds total timeout %v", cfg.ResponseHeader, cfg.Total)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package config
+
+import "fmt"
+
+// DefaultUsageMaxBodySize 默认非流式响应最多缓存多少字节用于解析
+const DefaultUsageMaxBodySize = 4 << 20
+
+// UsageConfig LLM 用量统计配置
+type UsageConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	LogFile     string `mapstructure:"log_file"`      // 追加写入的用量日志，为空时只记录指标
+	MaxBodySize int64  `mapstructure:"max_body_size"` // 非流式响应最多缓存多少字节用于解析
+}
+
+// BodyLimit 获取非流式响应的缓存上限
+func (c UsageConfig) BodyLimit() int64 {
+	if c.MaxBodySize > 0 {
+		return c.MaxBodySize
+	}
+	return DefaultUsageMaxBodySize
+}
+
+// validateUsageConfig 验证用量统计配置
+func validateUsageConfig(cfg UsageConfig) error {
+	if cfg.Enabled && cfg.MaxBodySize < 0 {
+		return fmt.Errorf("invalid max_body_size: %d", cfg.MaxBodySize)
+	}
+	return nil
+}
@@ -2,6 +2,11 @@ package config
 
 import (
 	"fmt"
+	"net/url"
+
+	"sub-router/pkg/loadbalance"
+	"sub-router/pkg/router"
+	"sub-router/pkg/transform"
 )
 
 // ValidateConfig 验证配置的合法性
@@ -11,6 +16,52 @@ func ValidateConfig(cfg *Config) error {
 		return fmt.Errorf("server config: %w", err)
 	}
 
+	// 验证 API 映射
+	for service, mapping := range cfg.APIMappings {
+		if err := validateAPIMapping(mapping); err != nil {
+			return fmt.Errorf("api mapping %q: %w", service, err)
+		}
+		if _, ok := cfg.Proxy.Egress[mapping.Egress]; !ok &&
+			mapping.Egress != "" && mapping.Egress != EgressDirect && mapping.Egress != EgressDefault {
+			return fmt.Errorf("api mapping %q: unknown egress %q", service, mapping.Egress)
+		}
+	}
+
+	// 验证请求镜像
+	if err := validateShadows(cfg.APIMappings); err != nil {
+		return err
+	}
+
+	// 验证模型路由
+	if err := validateModelRouting(cfg.ModelRouting, cfg.APIMappings); err != nil {
+		return fmt.Errorf("model routing: %w", err)
+	}
+
+	// 验证路由表
+	if err := validateRoutes(cfg); err != nil {
+		return fmt.Errorf("routes: %w", err)
+	}
+
+	// 验证熔断器配置
+	if err := validateCircuitBreakerConfig(cfg.CircuitBreaker); err != nil {
+		return fmt.Errorf("circuit breaker config: %w", err)
+	}
+
+	// 验证重试配置
+	if err := validateRetryConfig(cfg.Retry); err != nil {
+		return fmt.Errorf("retry config: %w", err)
+	}
+
+	// 验证超时配置
+	if err := validateTimeouts(cfg.Timeouts); err != nil {
+		return fmt.Errorf("timeouts config: %w", err)
+	}
+
+	// 验证传输配置
+	if err := validateTransportConfig(cfg.Transport); err != nil {
+		return fmt.Errorf("transport config: %w", err)
+	}
+
 	// 验证代理配置
 	if err := validateProxyConfig(cfg.Proxy); err != nil {
 		return fmt.Errorf("proxy config: %w", err)
@@ -21,6 +72,55 @@ func ValidateConfig(cfg *Config) error {
 		return fmt.Errorf("monitoring config: %w", err)
 	}
 
+	// 验证限流配置
+	if err := validateRateLimitConfig(cfg.RateLimit); err != nil {
+		return fmt.Errorf("rate limit config: %w", err)
+	}
+
+	// 验证用量统计配置
+	if err := validateUsageConfig(cfg.Usage); err != nil {
+		return fmt.Errorf("usage config: %w", err)
+	}
+
+	// 验证配额配置
+	if err := validateQuotaConfig(cfg.Quotas); err != nil {
+		return fmt.Errorf("quota config: %w", err)
+	}
+
+	// 验证认证配置
+	if err := validateAuthConfig(cfg.Security); err != nil {
+		return fmt.Errorf("security config: %w", err)
+	}
+
+	// 验证管理接口配置
+	if err := validateAdminConfig(cfg.Admin, cfg.Server.Port); err != nil {
+		return fmt.Errorf("admin config: %w", err)
+	}
+
+	return nil
+}
+
+// validateRoutes 验证路由表，目标服务必须是 API 映射或模型路由虚拟服务
+func validateRoutes(cfg *Config) error {
+	if _, err := router.Compile(cfg.Routes); err != nil {
+		return err
+	}
+	for i, spec := range cfg.Routes {
+		services := []string{spec.Service}
+		if len(spec.Splits) > 0 {
+			services = services[:0]
+			for _, split := range spec.Splits {
+				services = append(services, split.Service)
+			}
+		}
+		for _, service := range services {
+			_, mapped := cfg.APIMappings[service]
+			_, routed := cfg.ModelRouting[service]
+			if !mapped && !routed {
+				return fmt.Errorf("routes[%d]: unknown service %q", i, service)
+			}
+		}
+	}
 	return nil
 }
 
@@ -35,12 +135,166 @@ func validateServerConfig(cfg ServerConfig) error {
 	return nil
 }
 
+// validateTransportConfig 验证传输配置
+func validateTransportConfig(cfg TransportConfig) error {
+	if cfg.MaxConnsPerHost < 0 {
+		return fmt.Errorf("invalid max_conns_per_host: %d", cfg.MaxConnsPerHost)
+	}
+	if cfg.MaxResponseHeaderBytes < 0 {
+		return fmt.Errorf("invalid max_response_header_bytes: %d", cfg.MaxResponseHeaderBytes)
+	}
+	return nil
+}
+
+// validateAdminConfig 验证管理接口配置
+func validateAdminConfig(cfg AdminConfig, serverPort int) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Port <= 0 || cfg.Port > 65535 || cfg.Port == serverPort {
+		return fmt.Errorf("invalid port: %d", cfg.Port)
+	}
+	if cfg.Token == "" {
+		return fmt.Errorf("token is required")
+	}
+	return nil
+}
+
+// validateAPIMapping 验证 API 映射配置
+func validateAPIMapping(mapping APIMapping) error {
+	if _, err := loadbalance.ParseStrategy(mapping.Strategy); err != nil {
+		return err
+	}
+	if mapping.CircuitBreaker != nil {
+		if err := validateCircuitBreakerConfig(*mapping.CircuitBreaker); err != nil {
+			return fmt.Errorf("circuit breaker: %w", err)
+		}
+	}
+	if mapping.Retry != nil {
+		if err := validateRetryConfig(*mapping.Retry); err != nil {
+			return fmt.Errorf("retry: %w", err)
+		}
+	}
+	if hc := mapping.HealthCheck; hc != nil && hc.Enabled {
+		if hc.Interval <= 0 {
+			return fmt.Errorf("invalid health check interval: %v", hc.Interval)
+		}
+		if hc.Timeout <= 0 || hc.Timeout > hc.Interval {
+			return fmt.Errorf("invalid health check timeout: %v", hc.Timeout)
+		}
+		if hc.HealthyThreshold < 0 || hc.UnhealthyThreshold < 0 {
+			return fmt.Errorf("invalid health check thresholds")
+		}
+	}
+	if mapping.Timeouts != nil {
+		// 服务中的负数表示不限制
+		if err := validateTimeouts(TimeoutsConfig{}.Merge(mapping.Timeouts)); err != nil {
+			return fmt.Errorf("timeouts: %w", err)
+		}
+	}
+	if mapping.Cache != nil {
+		if err := validateCacheConfig(*mapping.Cache); err != nil {
+			return fmt.Errorf("cache: %w", err)
+		}
+	}
+	if mapping.Coalesce != nil {
+		if err := validateCoalesceConfig(*mapping.Coalesce); err != nil {
+			return fmt.Errorf("coalesce: %w", err)
+		}
+	}
+	if _, err := transform.Compile(mapping.Transforms); err != nil {
+		return err
+	}
+	if mapping.Translate != "" {
+		if _, err := transform.NewTranslator(mapping.Translate); err != nil {
+			return fmt.Errorf("translate: %w", err)
+		}
+	}
+	if mapping.Credentials != nil {
+		if err := validateCredentials(mapping.Credentials); err != nil {
+			return fmt.Errorf("credentials: %w", err)
+		}
+	}
+	targets := mapping.Targets()
+	if len(targets) == 0 {
+		return fmt.Errorf("no backend configured")
+	}
+	for _, target := range targets {
+		u, err := url.Parse(target.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid backend url: %q", target.URL)
+		}
+		if target.Weight < 0 {
+			return fmt.Errorf("invalid backend weight: %d", target.Weight)
+		}
+	}
+	return nil
+}
+
+// validateCircuitBreakerConfig 验证熔断器配置
+func validateCircuitBreakerConfig(cfg CircuitBreakerConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.ErrorThreshold <= 0 {
+		return fmt.Errorf("invalid error threshold: %d", cfg.ErrorThreshold)
+	}
+	if cfg.SuccessThreshold <= 0 {
+		return fmt.Errorf("invalid success threshold: %d", cfg.SuccessThreshold)
+	}
+	if cfg.MaxRequests < cfg.SuccessThreshold {
+		return fmt.Errorf("max requests %d is less than success threshold %d", cfg.MaxRequests, cfg.SuccessThreshold)
+	}
+	if cfg.Timeout <= 0 {
+		return fmt.Errorf("invalid timeout: %v", cfg.Timeout)
+	}
+	return nil
+}
+
+// validateRetryConfig 验证重试配置
+func validateRetryConfig(cfg RetryConfig) error {
+	if cfg.MaxAttempts < 0 {
+		return fmt.Errorf("invalid max attempts: %d", cfg.MaxAttempts)
+	}
+	if cfg.BaseDelay < 0 || cfg.MaxDelay < 0 || cfg.MaxRetryAfter < 0 {
+		return fmt.Errorf("invalid retry delay")
+	}
+	if cfg.MaxBodySize < 0 {
+		return fmt.Errorf("invalid max body size: %d", cfg.MaxBodySize)
+	}
+	return nil
+}
+
 // validateProxyConfig 验证代理配置
 func validateProxyConfig(cfg ProxyConfig) error {
 	if cfg.Enabled {
 		if cfg.URL == "" {
 			return fmt.Errorf("proxy enabled but URL is empty")
 		}
+		if err := validateProxyURL(cfg.URL); err != nil {
+			return err
+		}
+		for _, p := range cfg.Fallback {
+			if err := validateProxyURL(p.URL); err != nil {
+				return fmt.Errorf("fallback: %w", err)
+			}
+		}
+	}
+	for name, egress := range cfg.Egress {
+		if name == EgressDirect || name == EgressDefault {
+			return fmt.Errorf("egress name %q is reserved", name)
+		}
+		if len(egress.Proxies) == 0 {
+			return fmt.Errorf("egress %q has no proxies", name)
+		}
+		for _, p := range egress.Proxies {
+			if err := validateProxyURL(p.URL); err != nil {
+				return fmt.Errorf("egress %q: %w", name, err)
+			}
+		}
+	}
+	if hc := cfg.HealthCheck; hc.Enabled && (hc.Target == "" || hc.Interval <= 0 || hc.Timeout <= 0) {
+		return fmt.Errorf("invalid proxy health check config")
 	}
 	return nil
 }
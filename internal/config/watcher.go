@@ -1,36 +1,55 @@
 package config
 
 import (
+	"log"
+	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
-	"github.com/spf13/viper"
 )
 
+// watchDebounce 合并短时间内的多次文件事件
+const watchDebounce = 100 * time.Millisecond
+
 // Watcher 配置监控器
 type Watcher struct {
-	viper    *viper.Viper
+	file     string
+	watcher  *fsnotify.Watcher
 	onChange func() error
-	mu       sync.RWMutex
+	mu       sync.Mutex
 	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
 }
 
-// NewWatcher 创建配置监控器
+// NewWatcher 创建配置监控器，配置文件变化时调用 onChange；
+// onChange 返回错误时保留原配置（由 onChange 负责回滚），继续监控
 func NewWatcher(configFile string, onChange func() error) (*Watcher, error) {
-	v := viper.New()
-	v.SetConfigFile(configFile)
-	if err := v.ReadInConfig(); err != nil {
+	file, err := filepath.Abs(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// 监控所在目录，编辑器和 k8s ConfigMap 常以替换文件的方式写入
+	if err := fw.Add(filepath.Dir(file)); err != nil {
+		fw.Close()
 		return nil, err
 	}
 
 	w := &Watcher{
-		viper:    v,
+		file:     file,
+		watcher:  fw,
 		onChange: onChange,
 		done:     make(chan struct{}),
 	}
 
 	// 启动文件监控
+	w.wg.Add(1)
 	go w.watch()
 
 	return w, nil
@@ -38,22 +57,60 @@ func NewWatcher(configFile string, onChange func() error) (*Watcher, error) {
 
 // watch 监控配置文件变化
 func (w *Watcher) watch() {
-	w.viper.WatchConfig()
-	w.viper.OnConfigChange(func(e fsnotify.Event) {
-		// 延迟处理，避免文件系统事件风暴
-		time.Sleep(100 * time.Millisecond)
+	defer w.wg.Done()
 
-		w.mu.Lock()
-		defer w.mu.Unlock()
+	// 延迟处理，避免文件系统事件风暴
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	defer timer.Stop()
 
-		if err := w.onChange(); err != nil {
-			// TODO: 处理错误，可能需要回滚配置
+	for {
+		select {
+		case <-w.done:
 			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if !w.relevant(event) {
+				continue
+			}
+			timer.Reset(watchDebounce)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("config watcher error: %v", err)
+		case <-timer.C:
+			w.reload()
 		}
-	})
+	}
+}
+
+// relevant 判断事件是否涉及配置文件
+func (w *Watcher) relevant(event fsnotify.Event) bool {
+	if filepath.Clean(event.Name) != w.file {
+		// ConfigMap 通过替换 ..data 符号链接更新
+		return filepath.Base(event.Name) == "..data"
+	}
+	return event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0
+}
+
+// reload 执行配置变更回调
+func (w *Watcher) reload() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.onChange(); err != nil {
+		log.Printf("config change rejected: %v", err)
+	}
 }
 
 // Stop 停止监控
 func (w *Watcher) Stop() {
-	close(w.done)
+	w.stopOnce.Do(func() {
+		close(w.done)
+		w.watcher.Close()
+	})
+	w.wg.Wait()
 }
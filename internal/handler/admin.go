@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+
+	"sub-router/internal/config"
+	"sub-router/internal/upstream"
+	"sub-router/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServiceInfo 服务运行时状态
+type ServiceInfo struct {
+	Name     string        `json:"name"`
+	Strategy string        `json:"strategy"`
+	Egress   string        `json:"egress"`
+	Breaker  string        `json:"breaker"`
+	Backends []BackendInfo `json:"backends"`
+}
+
+// BackendInfo 后端运行时状态
+type BackendInfo struct {
+	URL       string `json:"url"`
+	Weight    int    `json:"weight"`
+	Priority  int    `json:"priority"`
+	Available bool   `json:"available"` // 是否参与负载均衡
+	Healthy   bool   `json:"healthy"`   // 健康检查结果
+	Drained   bool   `json:"drained"`   // 是否被手动下线
+	Breaker   string `json:"breaker,omitempty"`
+	Active    int64  `json:"active"`
+	Requests  int64  `json:"requests"`
+	Message   string `json:"message,omitempty"`
+}
+
+// backendRequest 后端上下线请求
+type backendRequest struct {
+	URL string `json:"url" binding:"required"`
+}
+
+// RegisterAdminRoutes 注册管理接口路由
+func RegisterAdminRoutes(r gin.IRouter) {
+	r.GET("/services", AdminListServices)
+	r.GET("/services/:service", AdminGetService)
+	r.PUT("/services/:service", AdminPutService)
+	r.DELETE("/services/:service", AdminDeleteService)
+	r.POST("/services/:service/backends/down", AdminSetBackend(true))
+	r.POST("/services/:service/backends/up", AdminSetBackend(false))
+	r.GET("/reload", AdminReloadStatus)
+	r.POST("/reload", AdminReload)
+	r.GET("/config", AdminConfig)
+}
+
+// AdminListServices 列出所有服务及其后端状态
+func AdminListServices(c *gin.Context) {
+	cfg := config.Get()
+	names := make([]string, 0, len(cfg.APIMappings))
+	for name := range cfg.APIMappings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	services := make([]ServiceInfo, 0, len(names))
+	for _, name := range names {
+		mapping, _ := cfg.APIMapping(name)
+		services = append(services, serviceInfo(name, mapping))
+	}
+	c.JSON(http.StatusOK, services)
+}
+
+// AdminGetService 获取单个服务状态
+func AdminGetService(c *gin.Context) {
+	name := c.Param("service")
+	mapping, ok := config.GetAPIMapping(name)
+	if !ok {
+		abortAdmin(c, http.StatusNotFound, errors.ErrorTypeValidation, "service not found: "+name)
+		return
+	}
+	c.JSON(http.StatusOK, serviceInfo(name, mapping))
+}
+
+// AdminPutService 新增或替换服务映射，请求体格式与配置文件中的 api_mappings 条目相同
+func AdminPutService(c *gin.Context) {
+	name := c.Param("service")
+	var raw interface{}
+	if err := c.ShouldBindJSON(&raw); err != nil {
+		abortAdmin(c, http.StatusBadRequest, errors.ErrorTypeValidation, "invalid request body: "+err.Error())
+		return
+	}
+	mapping, err := config.DecodeAPIMapping(raw)
+	if err != nil {
+		abortAdmin(c, http.StatusBadRequest, errors.ErrorTypeValidation, "invalid mapping: "+err.Error())
+		return
+	}
+	if err := config.Update(func(cfg *config.Config) {
+		cfg.APIMappings[name] = mapping
+	}); err != nil {
+		abortAdmin(c, http.StatusBadRequest, errors.ErrorTypeConfig, err.Error())
+		return
+	}
+
+	mapping, _ = config.GetAPIMapping(name)
+	c.JSON(http.StatusOK, serviceInfo(name, mapping))
+}
+
+// AdminDeleteService 删除服务映射
+func AdminDeleteService(c *gin.Context) {
+	name := c.Param("service")
+	if _, ok := config.GetAPIMapping(name); !ok {
+		abortAdmin(c, http.StatusNotFound, errors.ErrorTypeValidation, "service not found: "+name)
+		return
+	}
+	if err := config.Update(func(cfg *config.Config) {
+		delete(cfg.APIMappings, name)
+	}); err != nil {
+		abortAdmin(c, http.StatusBadRequest, errors.ErrorTypeConfig, err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// AdminSetBackend 手动下线或恢复后端
+func AdminSetBackend(drained bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("service")
+		mapping, ok := config.GetAPIMapping(name)
+		if !ok {
+			abortAdmin(c, http.StatusNotFound, errors.ErrorTypeValidation, "service not found: "+name)
+			return
+		}
+		var req backendRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortAdmin(c, http.StatusBadRequest, errors.ErrorTypeValidation, "invalid request body: "+err.Error())
+			return
+		}
+		if err := upstream.GlobalRegistry.SetDrained(name, mapping, req.URL, drained); err != nil {
+			abortAdmin(c, http.StatusNotFound, errors.ErrorTypeValidation, err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, serviceInfo(name, mapping))
+	}
+}
+
+// AdminReloadStatus 获取配置热加载状态
+func AdminReloadStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, config.GetReloadStatus())
+}
+
+// AdminReload 从配置文件重新加载配置
+func AdminReload(c *gin.Context) {
+	if err := config.Reload(); err != nil {
+		abortAdmin(c, http.StatusUnprocessableEntity, errors.ErrorTypeConfig, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, config.GetReloadStatus())
+}
+
+// AdminConfig 输出脱敏后的生效配置，键名与配置文件一致
+func AdminConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, config.Get().Redacted().Export())
+}
+
+// serviceInfo 汇总服务的负载均衡、熔断和健康状态
+func serviceInfo(name string, mapping config.APIMapping) ServiceInfo {
+	svc := upstream.GlobalRegistry.Resolve(name, mapping)
+	strategy := mapping.Strategy
+	if strategy == "" {
+		strategy = "round_robin"
+	}
+	egress, _ := config.EgressFor(mapping)
+	info := ServiceInfo{
+		Name:     name,
+		Strategy: strategy,
+		Egress:   egress,
+		Breaker:  svc.BreakerState().String(),
+		Backends: make([]BackendInfo, 0),
+	}
+
+	health := make(map[string]upstream.BackendStatus)
+	for _, status := range svc.Health() {
+		health[status.URL] = status
+	}
+	for _, backend := range svc.Balancer.Backends() {
+		status := health[backend.URL]
+		item := BackendInfo{
+			URL:       backend.URL,
+			Weight:    backend.Weight,
+			Priority:  backend.Priority,
+			Available: backend.Healthy,
+			Healthy:   status.Healthy,
+			Drained:   status.Drained,
+			Active:    backend.Active,
+			Requests:  backend.Requests,
+			Message:   status.Message,
+		}
+		if state, ok := svc.BackendBreakerState(backend.URL); ok {
+			item.Breaker = state.String()
+		}
+		info.Backends = append(info.Backends, item)
+	}
+	return info
+}
+
+// abortAdmin 返回管理接口错误
+func abortAdmin(c *gin.Context, status int, errType errors.ErrorType, message string) {
+	c.AbortWithStatusJSON(status,
+		errors.New(errType, message, status).ToResponse(c.GetString("trace_id")))
+}
@@ -0,0 +1,297 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sub-router/internal/config"
+	"sub-router/internal/middleware"
+	"sub-router/internal/upstream"
+	"sub-router/pkg/cache"
+	"sub-router/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// responseCache 所有服务共享的响应缓存，按 cache.max_bytes 限制总大小
+	responseCache     *cache.LRU
+	responseCacheOnce sync.Once
+)
+
+// cacheStore 获取响应缓存，预算随配置热加载调整
+func cacheStore() *cache.LRU {
+	maxBytes := config.Get().Cache.MaxBytes
+	responseCacheOnce.Do(func() {
+		responseCache = cache.NewLRU(maxBytes)
+	})
+	responseCache.Resize(maxBytes)
+	return responseCache
+}
+
+// cacheLookup 一次可缓存请求的缓存状态
+type cacheLookup struct {
+	store   *cache.LRU
+	key     string
+	service string
+	cfg     config.CacheConfig
+
+	stale        *cache.Entry // 已过期、等待重新验证的条目
+	revalidating bool         // 是否向上游附加了校验器
+}
+
+// newCacheLookup 判断请求能否使用缓存，返回 nil 表示不使用缓存；path 为改写后的相对路径
+func newCacheLookup(c *gin.Context, service string, mapping config.APIMapping, path string) *cacheLookup {
+	cfg := mapping.Cache
+	if cfg == nil || !cfg.Enabled || !cfg.AllowsMethod(c.Request.Method) {
+		return nil
+	}
+	if cache.RequestBypass(c.Request.Header) {
+		markCache(c, service, "bypass")
+		return nil
+	}
+	var bodyLimit int64
+	if cfg.BodyHash {
+		bodyLimit = cfg.EntryLimit()
+	}
+	key, ok := requestKey(c, service, path, cfg.KeyHeaders, cfg.KeyClient, bodyLimit)
+	if !ok {
+		markCache(c, service, "bypass")
+		return nil
+	}
+	return &cacheLookup{store: cacheStore(), key: key, service: service, cfg: *cfg}
+}
+
+// requestKey 计算请求键：服务、方法、改写后的相对路径、排序后的查询参数、客户端自带的凭证和指定的请求头，
+// 以及可选的客户端名称和请求体哈希（bodyLimit 大于 0 时）。请求体超过 bodyLimit 时返回 false
+func requestKey(c *gin.Context, service, path string, keyHeaders []string, keyClient bool, bodyLimit int64) (string, bool) {
+	h := sha256.New()
+	write := func(parts ...string) {
+		for _, part := range parts {
+			io.WriteString(h, part)
+			h.Write([]byte{0})
+		}
+	}
+
+	query := c.Request.URL.RawQuery
+	if values, err := url.ParseQuery(query); err == nil {
+		query = values.Encode()
+	}
+	write(service, c.Request.Method, path, query)
+	// 透传客户端凭证时不同凭证的响应不能共用
+	for _, name := range upstream.ClientCredentialHeaders {
+		write(strings.Join(c.Request.Header.Values(name), ","))
+	}
+	for _, name := range keyHeaders {
+		write(http.CanonicalHeaderKey(name), strings.Join(c.Request.Header.Values(name), ","))
+	}
+	if keyClient {
+		write(middleware.ClientName(c))
+	}
+
+	if bodyLimit > 0 && c.Request.Body != nil && c.Request.Body != http.NoBody {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, bodyLimit+1))
+		c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+		if err != nil || int64(len(body)) > bodyLimit {
+			return "", false
+		}
+		sum := sha256.Sum256(body)
+		write(hex.EncodeToString(sum[:]))
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// serve 命中新鲜缓存时直接返回；已过期但有校验器时为上游请求附加条件请求头
+func (l *cacheLookup) serve(c *gin.Context) bool {
+	entry, ok := l.store.Get(l.key)
+	if !ok || !entry.Matches(c.Request.Header) {
+		return false
+	}
+	now := time.Now()
+	if entry.Fresh(now) && !cache.RequestRevalidate(c.Request.Header) {
+		writeCached(c, l.service, entry, "hit", now)
+		return true
+	}
+
+	etag, lastModified := entry.Validators()
+	if etag == "" && lastModified == "" {
+		return false
+	}
+	l.stale = entry
+	// 客户端自带条件请求头时由上游直接响应客户端
+	if c.Request.Header.Get("If-None-Match") == "" && c.Request.Header.Get("If-Modified-Since") == "" {
+		if etag != "" {
+			c.Request.Header.Set("If-None-Match", etag)
+		}
+		if lastModified != "" {
+			c.Request.Header.Set("If-Modified-Since", lastModified)
+		}
+		l.revalidating = true
+	}
+	return false
+}
+
+// handle 处理上游响应：304 时刷新并返回缓存，可缓存的响应在转发完成后存入缓存。
+// 返回 true 表示响应已经写出
+func (l *cacheLookup) handle(c *gin.Context, resp *http.Response) bool {
+	now := time.Now()
+	if l.revalidating && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		// 移除代为附加的条件请求头，避免把 304 返回给没有缓存的客户端
+		c.Request.Header.Del("If-None-Match")
+		c.Request.Header.Del("If-Modified-Since")
+		entry := l.refresh(resp, now)
+		writeCached(c, l.service, entry, "revalidated", now)
+		return true
+	}
+
+	markCache(c, l.service, "miss")
+	policy := cache.Policy{TTL: l.cfg.TTL, ForceTTL: l.cfg.ForceTTL}
+	ttl, ok := policy.Freshness(resp, now)
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); streamingTypes[mediaType] {
+		ok = false
+	}
+	if !ok || resp.ContentLength > l.cfg.EntryLimit() {
+		// 上游不再允许缓存时删除旧条目；客户端自己的条件请求返回 304 时保留
+		if l.stale != nil && resp.StatusCode != http.StatusNotModified {
+			l.store.Delete(l.key)
+		}
+		return false
+	}
+
+	entry := &cache.Entry{
+		Status:  resp.StatusCode,
+		Header:  storedHeader(resp.Header),
+		Vary:    make(map[string]string),
+		Stored:  now,
+		Expires: now.Add(ttl),
+	}
+	for _, name := range cache.VaryHeaders(resp.Header) {
+		entry.Vary[name] = c.Request.Header.Get(name)
+	}
+	resp.Body = &cacheBody{
+		ReadCloser: resp.Body,
+		limit:      l.cfg.EntryLimit(),
+		complete: func(body []byte) {
+			entry.Body = body
+			l.store.Set(l.key, entry)
+			updateCacheMetrics(l.store)
+		},
+	}
+	return false
+}
+
+// refresh 使用 304 响应的头更新过期条目的新鲜度
+func (l *cacheLookup) refresh(resp *http.Response, now time.Time) *cache.Entry {
+	header := l.stale.Header.Clone()
+	for _, name := range []string{"Cache-Control", "Date", "Expires", "ETag", "Last-Modified", "Age"} {
+		if values := resp.Header.Values(name); len(values) > 0 {
+			header.Del(name)
+			for _, v := range values {
+				header.Add(name, v)
+			}
+		}
+	}
+	entry := *l.stale
+	entry.Header = header
+	entry.Stored = now
+	policy := cache.Policy{TTL: l.cfg.TTL, ForceTTL: l.cfg.ForceTTL}
+	ttl, _ := policy.Freshness(&http.Response{StatusCode: entry.Status, Header: header}, now)
+	entry.Expires = now.Add(ttl)
+	l.store.Set(l.key, &entry)
+	updateCacheMetrics(l.store)
+	return &entry
+}
+
+// writeCached 返回缓存的响应；客户端条件请求命中时返回 304
+func writeCached(c *gin.Context, service string, entry *cache.Entry, result string, now time.Time) {
+	markCache(c, service, result)
+	copyHeaders(entry.Header, c.Writer.Header())
+	c.Header("Age", strconv.Itoa(int(now.Sub(entry.Stored).Seconds())))
+
+	if etag, _ := entry.Validators(); etag != "" && etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	c.Header("Content-Length", strconv.Itoa(len(entry.Body)))
+	c.Status(entry.Status)
+	if c.Request.Method == http.MethodHead {
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	c.Writer.Write(entry.Body)
+}
+
+// markCache 设置 X-Cache 响应头并记录指标
+func markCache(c *gin.Context, service, result string) {
+	c.Header("X-Cache", strings.ToUpper(result))
+	metrics.CacheRequests.WithLabelValues(service, result).Inc()
+}
+
+// updateCacheMetrics 更新缓存大小指标
+func updateCacheMetrics(store *cache.LRU) {
+	entries, bytes := store.Stats()
+	metrics.CacheEntries.Set(float64(entries))
+	metrics.CacheBytes.Set(float64(bytes))
+}
+
+// storedHeader 复制需要缓存的响应头，不保存逐跳头和上游缓存状态
+func storedHeader(src http.Header) http.Header {
+	dst := make(http.Header)
+	copyHeaders(src, dst)
+	dst.Del("X-Cache")
+	dst.Del("Age")
+	return dst
+}
+
+// etagMatches 判断 If-None-Match 是否包含指定 ETag（弱比较）
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheBody 转发时收集响应体，完整读到结尾且未超过上限时存入缓存
+type cacheBody struct {
+	io.ReadCloser
+	buf      bytes.Buffer
+	limit    int64
+	over     bool
+	done     bool
+	complete func(body []byte)
+}
+
+// Read 读取响应体并收集副本
+func (b *cacheBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && !b.over {
+		if int64(b.buf.Len()+n) > b.limit {
+			b.over, b.buf = true, bytes.Buffer{}
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF && !b.over && !b.done {
+		b.done = true
+		b.complete(bytes.Clone(b.buf.Bytes()))
+	}
+	return n, err
+}
@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sub-router/internal/config"
+	"sub-router/pkg/transform"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newCacheRouter 创建启用了响应缓存的代理路由
+func newCacheRouter(service string, backend *httptest.Server, cache config.CacheConfig) *gin.Engine {
+	cache.Enabled = true
+	config.Set(&config.Config{
+		Cache: config.ResponseCacheConfig{MaxBytes: 1 << 20},
+		APIMappings: map[string]config.APIMapping{
+			service: {URL: backend.URL, Cache: &cache},
+		},
+	})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/:service/*path", ProxyHandler)
+	return r
+}
+
+// cacheRequest 发送请求并返回响应
+func cacheRequest(r http.Handler, method, path, body string, header http.Header) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestProxyHandlerCacheHit(t *testing.T) {
+	var hits int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		io.WriteString(w, "models "+r.URL.Query().Get("page"))
+	}))
+	defer backend.Close()
+	r := newCacheRouter("cache-hit", backend, config.CacheConfig{})
+
+	for i, want := range []string{"MISS", "HIT"} {
+		w := cacheRequest(r, "GET", "/cache-hit/v1/models?page=1&limit=2", "", nil)
+		if w.Code != http.StatusOK || w.Body.String() != "models 1" {
+			t.Fatalf("Request %d: unexpected response %d %q", i, w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("X-Cache"); got != want {
+			t.Errorf("Request %d: expected X-Cache %s, got %s", i, want, got)
+		}
+	}
+	// 查询参数顺序不同也命中同一条缓存
+	if w := cacheRequest(r, "GET", "/cache-hit/v1/models?limit=2&page=1", "", nil); w.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Expected reordered query to hit, got %s", w.Header().Get("X-Cache"))
+	}
+	if w := cacheRequest(r, "GET", "/cache-hit/v1/models?page=2", "", nil); w.Body.String() != "models 2" {
+		t.Errorf("Expected different query to miss, got %q", w.Body.String())
+	}
+	// 请求要求 no-store 时跳过缓存
+	if w := cacheRequest(r, "GET", "/cache-hit/v1/models?page=1&limit=2", "", http.Header{"Cache-Control": {"no-store"}}); w.Header().Get("X-Cache") != "BYPASS" {
+		t.Errorf("Expected no-store request to bypass, got %s", w.Header().Get("X-Cache"))
+	}
+	if n := atomic.LoadInt32(&hits); n != 3 {
+		t.Errorf("Expected 3 upstream requests, got %d", n)
+	}
+}
+
+func TestProxyHandlerCacheKeyUsesRewrittenPath(t *testing.T) {
+	var hits int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		io.WriteString(w, r.URL.Path)
+	}))
+	defer backend.Close()
+	r := newCacheRouter("cache-rewrite", backend, config.CacheConfig{})
+	mapping, _ := config.GetAPIMapping("cache-rewrite")
+	mapping.Transforms = []transform.Spec{{Request: transform.RequestSpec{
+		Path: []transform.PathRewrite{{Pattern: "^/v1/", Replace: "/v2/"}},
+	}}}
+	config.Set(&config.Config{
+		Cache:       config.Get().Cache,
+		APIMappings: map[string]config.APIMapping{"cache-rewrite": mapping},
+	})
+
+	// 改写后路径相同的请求命中同一条缓存
+	for i, path := range []string{"/cache-rewrite/v1/models", "/cache-rewrite/v2/models"} {
+		w := cacheRequest(r, "GET", path, "", nil)
+		if w.Body.String() != "/v2/models" {
+			t.Fatalf("Request %d: unexpected response %q", i, w.Body.String())
+		}
+		if want := []string{"MISS", "HIT"}[i]; w.Header().Get("X-Cache") != want {
+			t.Errorf("Request %d: expected X-Cache %s, got %s", i, want, w.Header().Get("X-Cache"))
+		}
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Errorf("Expected 1 upstream request, got %d", n)
+	}
+}
+
+func TestProxyHandlerCacheRevalidate(t *testing.T) {
+	var hits, notModified int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		io.WriteString(w, "payload")
+	}))
+	defer backend.Close()
+	r := newCacheRouter("cache-revalidate", backend, config.CacheConfig{})
+
+	if w := cacheRequest(r, "GET", "/cache-revalidate/data", "", nil); w.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("Expected MISS, got %s", w.Header().Get("X-Cache"))
+	}
+	w := cacheRequest(r, "GET", "/cache-revalidate/data", "", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "payload" || w.Header().Get("X-Cache") != "REVALIDATED" {
+		t.Fatalf("Unexpected revalidated response: %d %q %s", w.Code, w.Body.String(), w.Header().Get("X-Cache"))
+	}
+	if n := atomic.LoadInt32(&notModified); n != 1 {
+		t.Errorf("Expected 1 conditional request, got %d", n)
+	}
+
+	// 客户端自带的条件请求由上游直接响应
+	w = cacheRequest(r, "GET", "/cache-revalidate/data", "", http.Header{"If-None-Match": {`"v1"`}})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for client conditional request, got %d", w.Code)
+	}
+	if n := atomic.LoadInt32(&hits); n != 3 {
+		t.Errorf("Expected 3 upstream requests, got %d", n)
+	}
+}
+
+func TestProxyHandlerCacheForceTTL(t *testing.T) {
+	var hits int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "no-store")
+		io.WriteString(w, "static")
+	}))
+	defer backend.Close()
+
+	r := newCacheRouter("cache-plain", backend, config.CacheConfig{TTL: time.Minute})
+	cacheRequest(r, "GET", "/cache-plain/file", "", nil)
+	cacheRequest(r, "GET", "/cache-plain/file", "", nil)
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("Expected no-store response not to be cached, got %d upstream requests", n)
+	}
+
+	atomic.StoreInt32(&hits, 0)
+	r = newCacheRouter("cache-force", backend, config.CacheConfig{TTL: time.Minute, ForceTTL: true})
+	cacheRequest(r, "GET", "/cache-force/file", "", nil)
+	if w := cacheRequest(r, "GET", "/cache-force/file", "", nil); w.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Expected force_ttl to cache response, got %s", w.Header().Get("X-Cache"))
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Errorf("Expected 1 upstream request, got %d", n)
+	}
+}
+
+func TestProxyHandlerCacheBodyHash(t *testing.T) {
+	var hits int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"echo":`+string(body)+`}`)
+	}))
+	defer backend.Close()
+	r := newCacheRouter("cache-post", backend, config.CacheConfig{
+		TTL:        time.Minute,
+		Methods:    []string{"POST"},
+		BodyHash:   true,
+		KeyHeaders: []string{"X-Tenant"},
+	})
+
+	tenant := http.Header{"X-Tenant": {"a"}}
+	cacheRequest(r, "POST", "/cache-post/embeddings", `{"input":"a"}`, tenant)
+	w := cacheRequest(r, "POST", "/cache-post/embeddings", `{"input":"a"}`, tenant)
+	if w.Header().Get("X-Cache") != "HIT" || w.Body.String() != `{"echo":{"input":"a"}}` {
+		t.Errorf("Expected identical body to hit, got %s %q", w.Header().Get("X-Cache"), w.Body.String())
+	}
+	w = cacheRequest(r, "POST", "/cache-post/embeddings", `{"input":"b"}`, tenant)
+	if w.Header().Get("X-Cache") != "MISS" || w.Body.String() != `{"echo":{"input":"b"}}` {
+		t.Errorf("Expected different body to miss, got %s %q", w.Header().Get("X-Cache"), w.Body.String())
+	}
+	if w := cacheRequest(r, "POST", "/cache-post/embeddings", `{"input":"a"}`, http.Header{"X-Tenant": {"b"}}); w.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("Expected different key header to miss, got %s", w.Header().Get("X-Cache"))
+	}
+	if n := atomic.LoadInt32(&hits); n != 3 {
+		t.Errorf("Expected 3 upstream requests, got %d", n)
+	}
+}
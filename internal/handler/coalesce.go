@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+
+	"sub-router/internal/config"
+	"sub-router/internal/upstream"
+	"sub-router/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// flights 进行中的可合并请求，按请求键索引
+var flights = &flightGroup{calls: make(map[string]*flightCall)}
+
+// flightGroup 进行中的上游请求表
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall 一次进行中的上游请求，完成后 done 关闭
+type flightCall struct {
+	done    chan struct{}
+	waiters int
+
+	// 以下字段在 done 关闭前由首个请求写入
+	resp *sharedResponse // 为空表示响应不可共享，等待者各自请求上游
+	err  error
+}
+
+// sharedResponse 缓存在内存中、可以发给所有等待者的响应
+type sharedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// coalescer 一次可合并请求的状态
+type coalescer struct {
+	key     string
+	service string
+	cfg     config.CoalesceConfig
+	call    *flightCall // 作为首个请求时发起的上游请求
+}
+
+// newCoalescer 判断请求能否与相同的进行中请求合并，返回 nil 表示不合并。
+//
+// 只合并 GET、HEAD；带条件请求头或 Range 的请求响应因客户端而异，不参与合并。path 为改写后的相对路径
+func newCoalescer(c *gin.Context, service string, mapping config.APIMapping, path string) *coalescer {
+	cfg := mapping.Coalesce
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return nil
+	}
+	for _, name := range []string{"If-None-Match", "If-Modified-Since", "Range"} {
+		if c.Request.Header.Get(name) != "" {
+			return nil
+		}
+	}
+	key, _ := requestKey(c, service, path, cfg.KeyHeaders, cfg.KeyClient, 0)
+	return &coalescer{key: key, service: service, cfg: *cfg}
+}
+
+// wait 加入相同的进行中请求。
+//
+// 返回 true 表示已经使用共享的结果响应了客户端；返回 false 时由调用方请求上游：
+// 没有进行中的请求时成为首个请求，等待者超过上限或共享的响应不可用时单独请求
+func (co *coalescer) wait(c *gin.Context, svc *upstream.Service) bool {
+	flights.mu.Lock()
+	call, ok := flights.calls[co.key]
+	if !ok {
+		co.call = &flightCall{done: make(chan struct{})}
+		flights.calls[co.key] = co.call
+		flights.mu.Unlock()
+		return false
+	}
+	if call.waiters >= co.cfg.WaiterLimit() {
+		flights.mu.Unlock()
+		metrics.CoalescedRequests.WithLabelValues(co.service, "overflow").Inc()
+		return false
+	}
+	call.waiters++
+	flights.mu.Unlock()
+
+	select {
+	case <-call.done:
+	case <-c.Request.Context().Done():
+		// 客户端已断开，无需响应
+		c.Abort()
+		return true
+	}
+
+	switch {
+	case call.err != nil:
+		metrics.CoalescedRequests.WithLabelValues(co.service, "shared").Inc()
+		abortUpstreamError(c, svc, call.err)
+	case call.resp != nil:
+		metrics.CoalescedRequests.WithLabelValues(co.service, "shared").Inc()
+		copyHeaders(call.resp.header, c.Writer.Header())
+		c.Status(call.resp.status)
+		if c.Request.Method == http.MethodHead {
+			c.Writer.WriteHeaderNow()
+		} else {
+			c.Writer.Write(call.resp.body)
+		}
+	default:
+		metrics.CoalescedRequests.WithLabelValues(co.service, "fallback").Inc()
+		return false
+	}
+	return true
+}
+
+// fail 将上游错误共享给等待者；首个请求的客户端断开或超时导致的错误不共享
+func (co *coalescer) fail(c *gin.Context, err error) {
+	if c.Request.Context().Err() == nil {
+		co.publish(nil, err)
+	}
+	co.release()
+}
+
+// share 读取完整响应体并共享给等待者，响应体替换为内存中的副本。
+//
+// 流式响应和超过 max_body_size 的响应不共享，等待者各自请求上游
+func (co *coalescer) share(c *gin.Context, resp *http.Response) error {
+	if co.call == nil {
+		return nil
+	}
+	defer co.release()
+
+	limit := co.cfg.BodyLimit()
+	if c.Request.Method != http.MethodHead && (isStreaming(resp) || resp.ContentLength > limit) {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		resp.Body.Close()
+		if c.Request.Context().Err() == nil {
+			co.publish(nil, err)
+		}
+		return err
+	}
+	if int64(len(body)) > limit {
+		// 实际长度超过上限：已读出的部分与剩余响应体拼接后继续转发
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	co.publish(&sharedResponse{status: resp.StatusCode, header: resp.Header.Clone(), body: body}, nil)
+	return nil
+}
+
+// publish 写入首个请求的结果
+func (co *coalescer) publish(resp *sharedResponse, err error) {
+	if co.call == nil {
+		return
+	}
+	co.call.resp, co.call.err = resp, err
+}
+
+// release 结束进行中的请求并唤醒等待者，可重复调用
+func (co *coalescer) release() {
+	if co.call == nil {
+		return
+	}
+	flights.mu.Lock()
+	if flights.calls[co.key] == co.call {
+		delete(flights.calls, co.key)
+	}
+	flights.mu.Unlock()
+	close(co.call.done)
+	co.call = nil
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sub-router/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newCoalesceRouter 创建启用了请求合并的代理路由
+func newCoalesceRouter(service string, backend *httptest.Server, coalesce config.CoalesceConfig) *gin.Engine {
+	coalesce.Enabled = true
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			service: {URL: backend.URL, Coalesce: &coalesce},
+		},
+	})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/:service/*path", ProxyHandler)
+	return r
+}
+
+// newGate 创建阻塞上游响应的闸门，返回的打开函数可以重复调用
+func newGate() (chan struct{}, func()) {
+	gate := make(chan struct{})
+	var once sync.Once
+	return gate, func() { once.Do(func() { close(gate) }) }
+}
+
+// waitUntil 等待条件成立，超时后测试失败
+func waitUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %s", what)
+}
+
+// waitForWaiters 等待进行中的请求累计到指定的等待者数量
+func waitForWaiters(t *testing.T, n int) {
+	t.Helper()
+	waitUntil(t, fmt.Sprintf("%d waiters", n), func() bool {
+		flights.mu.Lock()
+		defer flights.mu.Unlock()
+		waiters := 0
+		for _, call := range flights.calls {
+			waiters += call.waiters
+		}
+		return waiters >= n
+	})
+}
+
+// concurrentGets 并发发送相同请求，返回所有响应
+func concurrentGets(r http.Handler, path string, n int) []*httptest.ResponseRecorder {
+	results := make([]*httptest.ResponseRecorder, n)
+	var wg sync.WaitGroup
+	for i := range results {
+		results[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(w *httptest.ResponseRecorder) {
+			defer wg.Done()
+			r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		}(results[i])
+	}
+	wg.Wait()
+	return results
+}
+
+func TestProxyHandlerCoalesce(t *testing.T) {
+	var hits int32
+	release, open := newGate()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"data":[]}`)
+	}))
+	defer backend.Close()
+	defer open() // 先于关闭上游执行，测试失败时不会阻塞在 backend.Close
+	r := newCoalesceRouter("coalesce", backend, config.CoalesceConfig{})
+
+	done := make(chan []*httptest.ResponseRecorder, 1)
+	go func() { done <- concurrentGets(r, "/coalesce/v1/models", 5) }()
+	waitForWaiters(t, 4)
+	open()
+
+	for i, w := range <-done {
+		if w.Code != http.StatusOK || w.Body.String() != `{"data":[]}` || w.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("Request %d: unexpected response %d %q", i, w.Code, w.Body.String())
+		}
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Errorf("Expected 1 upstream request, got %d", n)
+	}
+}
+
+func TestProxyHandlerCoalesceStreamFallback(t *testing.T) {
+	var hits int32
+	release, open := newGate()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {}\n\n")
+	}))
+	defer backend.Close()
+	defer open()
+	r := newCoalesceRouter("coalesce-stream", backend, config.CoalesceConfig{})
+
+	done := make(chan []*httptest.ResponseRecorder, 1)
+	go func() { done <- concurrentGets(r, "/coalesce-stream/events", 3) }()
+	waitForWaiters(t, 2)
+	open()
+
+	// 流式响应不共享，等待者各自请求上游
+	for i, w := range <-done {
+		if w.Code != http.StatusOK || w.Body.String() != "data: {}\n\n" {
+			t.Errorf("Request %d: unexpected response %d %q", i, w.Code, w.Body.String())
+		}
+	}
+	if n := atomic.LoadInt32(&hits); n != 3 {
+		t.Errorf("Expected 3 upstream requests, got %d", n)
+	}
+}
+
+func TestProxyHandlerCoalesceMaxWaiters(t *testing.T) {
+	var hits int32
+	release, open := newGate()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			<-release
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer backend.Close()
+	defer open()
+	r := newCoalesceRouter("coalesce-limit", backend, config.CoalesceConfig{MaxWaiters: 1})
+
+	// 依次发出首个请求和等待者，确保阻塞在上游的是首个请求
+	leader := make(chan []*httptest.ResponseRecorder, 1)
+	go func() { leader <- concurrentGets(r, "/coalesce-limit/items", 1) }()
+	waitUntil(t, "leader request", func() bool { return atomic.LoadInt32(&hits) == 1 })
+	waiter := make(chan []*httptest.ResponseRecorder, 1)
+	go func() { waiter <- concurrentGets(r, "/coalesce-limit/items", 1) }()
+	waitForWaiters(t, 1)
+
+	// 第三个请求超过等待者上限，不等待首个请求
+	w := concurrentGets(r, "/coalesce-limit/items", 1)[0]
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("Over-limit request: unexpected response %d %q", w.Code, w.Body.String())
+	}
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("Expected over-limit request to reach upstream, got %d upstream requests", n)
+	}
+	open()
+
+	for i, w := range append(<-leader, <-waiter...) {
+		if w.Code != http.StatusOK || w.Body.String() != "ok" {
+			t.Errorf("Request %d: unexpected response %d %q", i, w.Code, w.Body.String())
+		}
+	}
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("Expected 2 upstream requests, got %d", n)
+	}
+}
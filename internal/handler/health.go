@@ -2,10 +2,15 @@ package handler
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"sub-router/internal/config"
+	"sub-router/internal/upstream"
+	"sub-router/pkg/transport"
 
 	"github.com/gin-gonic/gin"
 )
@@ -36,23 +41,89 @@ func RobotsHandler(c *gin.Context) {
 	c.String(200, "User-agent: *\nDisallow: /")
 }
 
-// DetailedHealthCheck returns the health status of the service
+// Version 服务版本，可在构建时通过 -ldflags 注入
+var Version = "dev"
+
+// DetailedHealthCheck 返回服务及各上游的详细健康状态
 func DetailedHealthCheck(c *gin.Context) {
-	healthStatus := make(map[string]interface{})
+	status := HealthStatus{
+		Status:  "ok",
+		Checks:  make(map[string]CheckResult),
+		Version: Version,
+	}
+
+	// 配置的基础检查项
+	for _, check := range config.Get().Monitoring.Health.Checks {
+		status.Checks[check.Name] = performHealthCheck(check.Name, check.Timeout)
+	}
+
+	// 启用了主动健康检查的上游服务
+	for name, result := range upstreamHealth() {
+		status.Checks["upstream:"+name] = result
+	}
+
+	for _, result := range status.Checks {
+		if result.Status == "error" {
+			status.Status = "degraded"
+			break
+		}
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// upstreamHealth 汇总各上游服务的主动健康检查结果
+func upstreamHealth() map[string]CheckResult {
+	results := make(map[string]CheckResult)
+	for name := range config.Get().APIMappings {
+		mapping, _ := config.GetAPIMapping(name)
+		if mapping.HealthCheck == nil || !mapping.HealthCheck.Enabled {
+			continue
+		}
+		svc, ok := upstream.GlobalRegistry.Get(name)
+		if !ok {
+			continue
+		}
+		results[name] = serviceCheckResult(svc.Health())
+	}
+	return results
+}
 
-	// Check API health
-	healthStatus["api"] = "up" // Assuming API is always up for this example
+// serviceCheckResult 将后端状态汇总为单个检查结果
+func serviceCheckResult(backends []upstream.BackendStatus) CheckResult {
+	result := CheckResult{Status: "ok"}
 
-	// Check proxy health only if enabled
-	if config.GlobalConfig.Proxy.Enabled {
-		// Perform proxy health check logic here
-		// For example, you might want to ping the proxy server
-		healthStatus["proxy"] = "up" // Replace with actual check
-	} else {
-		healthStatus["proxy"] = "not enabled"
+	var healthy int
+	var messages []string
+	for _, backend := range backends {
+		if backend.Healthy {
+			healthy++
+		}
+		if backend.Message != "" {
+			messages = append(messages, backend.URL+": "+backend.Message)
+		}
+		if backend.Latency > result.Duration {
+			result.Duration = backend.Latency
+		}
+		if backend.CheckedAt.After(result.Timestamp) {
+			result.Timestamp = backend.CheckedAt
+		}
+	}
+	if result.Timestamp.IsZero() {
+		result.Timestamp = time.Now()
 	}
 
-	c.JSON(http.StatusOK, healthStatus)
+	switch {
+	case healthy == 0:
+		result.Status = "error"
+	case healthy < len(backends):
+		result.Status = "warning"
+	}
+	result.Message = fmt.Sprintf("%d/%d backends healthy", healthy, len(backends))
+	if len(messages) > 0 {
+		result.Message += "; " + strings.Join(messages, "; ")
+	}
+	return result
 }
 
 // performHealthCheck 执行健康检查
@@ -66,7 +137,7 @@ func performHealthCheck(name string, timeout time.Duration) CheckResult {
 	// 根据不同的检查类型执行不同的检查
 	switch name {
 	case "proxy":
-		return checkProxy(ctx)
+		return checkProxy()
 	case "api":
 		return checkAPI(ctx)
 	default:
@@ -79,16 +150,15 @@ func performHealthCheck(name string, timeout time.Duration) CheckResult {
 	}
 }
 
-// checkProxy 检查代理服务是否正常
-func checkProxy(ctx context.Context) CheckResult {
+// checkProxy 汇总出口代理的健康状态。
+//
+// 只读取后台健康检查和实际拨号记录的状态，不在请求中连接代理，避免 /health 被用来放大代理流量；
+// 尚未检查过的代理视为健康
+func checkProxy() CheckResult {
 	start := time.Now()
 
-	// 创建 HTTP 客户端
-	client := &http.Client{}
-
-	// 检查代理配置
-	enabled, proxyURL := config.GetProxyConfig()
-	if !enabled || proxyURL == "" {
+	proxies := config.AllEgressProxies()
+	if len(proxies) == 0 {
 		return CheckResult{
 			Status:    "warning",
 			Message:   "Proxy not configured",
@@ -97,55 +167,70 @@ func checkProxy(ctx context.Context) CheckResult {
 		}
 	}
 
-	// 尝试连接代理
-	req, err := http.NewRequestWithContext(ctx, "HEAD", proxyURL, nil)
-	if err != nil {
-		return CheckResult{
-			Status:    "error",
-			Message:   "Failed to create request: " + err.Error(),
-			Duration:  time.Since(start),
-			Timestamp: time.Now(),
+	result := CheckResult{Status: "ok"}
+	var failed []string
+	for _, proxy := range proxies {
+		status, ok := transport.GlobalProxyHealth.Status(proxy)
+		if !ok {
+			continue
 		}
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return CheckResult{
-			Status:    "error",
-			Message:   "Failed to connect to proxy: " + err.Error(),
-			Duration:  time.Since(start),
-			Timestamp: time.Now(),
+		if !status.Healthy {
+			failed = append(failed, status.URL+": "+status.Message)
+		}
+		if status.Latency > result.Duration {
+			result.Duration = status.Latency
+		}
+		if status.CheckedAt.After(result.Timestamp) {
+			result.Timestamp = status.CheckedAt
 		}
 	}
-	defer resp.Body.Close()
+	if result.Timestamp.IsZero() {
+		result.Timestamp = time.Now()
+	}
 
-	return CheckResult{
-		Status:    "ok",
-		Duration:  time.Since(start),
-		Timestamp: time.Now(),
+	result.Message = fmt.Sprintf("%d/%d proxies healthy", len(proxies)-len(failed), len(proxies))
+	switch {
+	case len(failed) == len(proxies):
+		result.Status = "error"
+	case len(failed) > 0:
+		result.Status = "warning"
 	}
+	if len(failed) > 0 {
+		result.Message += "; " + strings.Join(failed, "; ")
+	}
+	return result
 }
 
-// checkAPI 检查 API 服务是否正常
+// checkAPI 检查 API 服务是否正常，即所有上游服务都至少有一个可用后端
 func checkAPI(ctx context.Context) CheckResult {
 	start := time.Now()
 
-	// 这里可以添加具体的 API 检查逻辑
-	// 例如：检查一些关键的 API 端点是否可访问
-
-	select {
-	case <-ctx.Done():
+	var unavailable []string
+	for name, result := range upstreamHealth() {
+		if result.Status == "error" {
+			unavailable = append(unavailable, name)
+		}
+	}
+	if ctx.Err() != nil {
 		return CheckResult{
 			Status:    "error",
 			Message:   "API check timeout",
 			Duration:  time.Since(start),
 			Timestamp: time.Now(),
 		}
-	case <-time.After(100 * time.Millisecond): // 模拟检查耗时
+	}
+	if len(unavailable) > 0 {
+		sort.Strings(unavailable)
 		return CheckResult{
-			Status:    "ok",
+			Status:    "error",
+			Message:   "No healthy backend for: " + strings.Join(unavailable, ", "),
 			Duration:  time.Since(start),
 			Timestamp: time.Now(),
 		}
 	}
+	return CheckResult{
+		Status:    "ok",
+		Duration:  time.Since(start),
+		Timestamp: time.Now(),
+	}
 }
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"sub-router/internal/config"
+	"sub-router/internal/upstream"
+	"sub-router/pkg/transport"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDetailedHealthCheck(t *testing.T) {
+	// 设置测试配置
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"down": {
+				URL: "http://127.0.0.1:1",
+				HealthCheck: &config.UpstreamHealthCheck{
+					Enabled:            true,
+					Interval:           time.Minute,
+					Timeout:            time.Second,
+					UnhealthyThreshold: 1,
+				},
+			},
+		},
+	})
+
+	// 模拟健康检查失败
+	mapping, _ := config.GetAPIMapping("down")
+	svc := upstream.GlobalRegistry.Resolve("down", mapping)
+	svc.ReportHealth("http://127.0.0.1:1", time.Millisecond, http.ErrHandlerTimeout)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/health", nil)
+	DetailedHealthCheck(c)
+
+	var status HealthStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Invalid health response: %v", err)
+	}
+	if status.Status != "degraded" {
+		t.Errorf("Expected degraded status, got %s", status.Status)
+	}
+	if result := status.Checks["upstream:down"]; result.Status != "error" {
+		t.Errorf("Expected upstream check error, got %+v", result)
+	}
+}
+
+func TestCheckProxy(t *testing.T) {
+	// 代理地址不可达，/health 只读取已记录的状态而不连接代理
+	config.Set(&config.Config{Proxy: config.ProxyConfig{Enabled: true, URL: "socks5://127.0.0.1:1", Fallback: []config.EgressProxy{{URL: "http://127.0.0.1:2"}}}})
+	defer config.Set(&config.Config{})
+
+	if result := checkProxy(); result.Status != "ok" || result.Message != "2/2 proxies healthy" {
+		t.Errorf("Expected unchecked proxies to be healthy, got %+v", result)
+	}
+	transport.GlobalProxyHealth.Report("http://127.0.0.1:2", time.Millisecond, http.ErrHandlerTimeout)
+	if result := checkProxy(); result.Status != "warning" || !strings.HasPrefix(result.Message, "1/2 proxies healthy") {
+		t.Errorf("Expected recorded failure to be reported, got %+v", result)
+	}
+}
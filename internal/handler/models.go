@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"sub-router/internal/config"
+	"sub-router/internal/middleware"
+	"sub-router/pkg/errors"
+	"sub-router/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeModel 按请求体中的 model 字段将虚拟服务的请求转发到目标服务。
+//
+// 目标按顺序尝试，前一个目标返回 fallback_on_status 中的状态码（含熔断、无可用后端、
+// 上游超时产生的 503/502/504）时丢弃其响应并尝试下一个，最后一个目标的响应直接返回
+func routeModel(c *gin.Context, name string, router config.ModelRouterConfig, start time.Time) {
+	body, ok, err := readModelBody(c.Request, router.BodyLimit())
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			errors.Wrap(err, errors.ErrorTypeValidation, "failed to read request body", http.StatusBadRequest).
+				ToResponse(c.GetString("trace_id")))
+		return
+	}
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge,
+			errors.New(errors.ErrorTypeValidation, "request body too large", http.StatusRequestEntityTooLarge).
+				ToResponse(c.GetString("trace_id")))
+		return
+	}
+
+	model := requestModel(body)
+	var targets []config.ModelTarget
+	forbidden := false
+	for _, target := range router.TargetsFor(model) {
+		// 热加载删除的服务在校验时会被拒绝，这里只是防御
+		if _, exists := config.GetAPIMapping(target.Service); !exists {
+			continue
+		}
+		// 认证只检查了虚拟服务，客户端无权访问的目标不参与回退
+		if !middleware.ClientAllows(c, target.Service) {
+			forbidden = true
+			continue
+		}
+		targets = append(targets, target)
+	}
+	if len(targets) == 0 && forbidden {
+		c.AbortWithStatusJSON(http.StatusForbidden,
+			errors.New(errors.ErrorTypeAuth, "client "+middleware.ClientName(c)+" is not allowed to access any target of model "+model, http.StatusForbidden).
+				ToResponse(c.GetString("trace_id")))
+		return
+	}
+	if len(targets) == 0 {
+		message := "no route for model " + model
+		if model == "" {
+			message = "model is required"
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			errors.New(errors.ErrorTypeValidation, message, http.StatusBadRequest).
+				ToResponse(c.GetString("trace_id")))
+		return
+	}
+
+	path := router.Prefix(name) + c.Param("path")
+	base, writer := c.Request, c.Writer
+	for i, target := range targets {
+		mapping, _ := config.GetAPIMapping(target.Service)
+		data := body
+		if rewritten := target.Rewrite(model); model != "" && rewritten != model {
+			data = rewriteModel(body, rewritten)
+		}
+
+		// 每次尝试使用原始请求的副本，前一次尝试对请求头和上下文的修改不会保留
+		c.Request = base.Clone(base.Context())
+		c.Request.Body = io.NopCloser(bytes.NewReader(data))
+		c.Request.ContentLength = int64(len(data))
+		if i == len(targets)-1 {
+			proxy(c, target.Service, mapping, path, start)
+			metrics.ModelRouteRequests.WithLabelValues(name, target.Service, "served").Inc()
+			return
+		}
+
+		attempt := &fallbackWriter{ResponseWriter: writer, header: make(http.Header), fallback: router.Fallback}
+		c.Writer = attempt
+		proxy(c, target.Service, mapping, path, start)
+		c.Writer = writer
+		// 没有写入响应体时在这里决定，例如 HEAD 请求
+		attempt.WriteHeaderNow()
+		if !attempt.rejected {
+			metrics.ModelRouteRequests.WithLabelValues(name, target.Service, "served").Inc()
+			return
+		}
+		metrics.ModelRouteRequests.WithLabelValues(name, target.Service, "fallback").Inc()
+	}
+}
+
+// readModelBody 读取请求体，超过上限时返回 ok=false
+func readModelBody(req *http.Request, limit int64) (body []byte, ok bool, err error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, true, nil
+	}
+	if req.ContentLength > limit {
+		return nil, false, nil
+	}
+	body, err = io.ReadAll(io.LimitReader(req.Body, limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+	return body, int64(len(body)) <= limit, nil
+}
+
+// requestModel 获取 JSON 请求体中的 model 字段
+func requestModel(body []byte) string {
+	var req struct {
+		Model string `json:"model"`
+	}
+	if json.Unmarshal(body, &req) != nil {
+		return ""
+	}
+	return req.Model
+}
+
+// rewriteModel 替换请求体中的 model 字段，其他字段保持原样
+func rewriteModel(body []byte, model string) []byte {
+	var doc map[string]json.RawMessage
+	if json.Unmarshal(body, &doc) != nil {
+		return body
+	}
+	doc["model"], _ = json.Marshal(model)
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+// fallbackWriter 暂存一次尝试的响应状态和响应头，直到确定是否切换到下一个目标。
+//
+// 状态码需要切换时丢弃整个响应，否则在首次写入时将响应头和状态码提交给客户端
+type fallbackWriter struct {
+	gin.ResponseWriter
+	header   http.Header
+	fallback func(status int) bool
+
+	status    int
+	rejected  bool
+	committed bool
+}
+
+// Header 提交前返回暂存的响应头
+func (w *fallbackWriter) Header() http.Header {
+	if w.committed {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+// WriteHeader 记录状态码，与 gin 一样延迟到首次写入时发送
+func (w *fallbackWriter) WriteHeader(code int) {
+	if code > 0 && !w.committed && !w.rejected {
+		w.status = code
+	}
+}
+
+// WriteHeaderNow 决定切换或提交响应
+func (w *fallbackWriter) WriteHeaderNow() {
+	if w.committed || w.rejected {
+		return
+	}
+	if w.fallback(w.Status()) {
+		w.rejected = true
+		return
+	}
+	w.commit()
+}
+
+// commit 提交暂存的响应头和状态码
+func (w *fallbackWriter) commit() {
+	if w.committed || w.rejected {
+		return
+	}
+	status := w.Status()
+	w.committed = true
+	dst := w.ResponseWriter.Header()
+	for key, values := range w.header {
+		dst[key] = values
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *fallbackWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	if w.rejected {
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *fallbackWriter) WriteString(s string) (int, error) {
+	w.WriteHeaderNow()
+	if w.rejected {
+		return len(s), nil
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *fallbackWriter) Flush() {
+	w.WriteHeaderNow()
+	if w.committed {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *fallbackWriter) Status() int {
+	if w.committed {
+		return w.ResponseWriter.Status()
+	}
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *fallbackWriter) Written() bool {
+	return w.committed || w.rejected
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"sub-router/internal/config"
+	"sub-router/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProxyHandlerModelRouting(t *testing.T) {
+	var limited int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&limited) == 1 {
+			w.Header().Set("X-Primary", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, `{"error":"rate limited"}`)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"upstream": "primary", "path": r.URL.Path, "body": string(body)})
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"upstream": "secondary", "path": r.URL.Path, "body": string(body)})
+	}))
+	defer secondary.Close()
+
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"primary":   {URL: primary.URL},
+			"secondary": {URL: secondary.URL},
+		},
+		ModelRouting: map[string]config.ModelRouterConfig{
+			"v1": {
+				Routes: []config.ModelRoute{{
+					Models: []string{"llama-*"},
+					Targets: []config.ModelTarget{
+						{Service: "primary"},
+						{Service: "secondary", Model: "meta/{model}"},
+					},
+				}},
+			},
+		},
+	})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/:service/*path", ProxyHandler)
+
+	call := func(body string) (*httptest.ResponseRecorder, map[string]string) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body)))
+		var got map[string]string
+		json.Unmarshal(w.Body.Bytes(), &got)
+		return w, got
+	}
+
+	w, got := call(`{"model":"llama-3","stream":false}`)
+	if w.Code != http.StatusOK || got["upstream"] != "primary" || got["path"] != "/v1/chat/completions" ||
+		got["body"] != `{"model":"llama-3","stream":false}` {
+		t.Errorf("Unexpected primary response %d %v", w.Code, got)
+	}
+
+	// 主服务返回 429 时切换到后备服务并改写模型名，丢弃主服务的响应头
+	atomic.StoreInt32(&limited, 1)
+	w, got = call(`{"model":"llama-3","stream":false}`)
+	if w.Code != http.StatusOK || got["upstream"] != "secondary" || got["body"] != `{"model":"meta/llama-3","stream":false}` {
+		t.Errorf("Unexpected fallback response %d %v", w.Code, got)
+	}
+	if w.Header().Get("X-Primary") != "" {
+		t.Errorf("Expected primary headers to be discarded, got %v", w.Header())
+	}
+
+	// 没有匹配的路由
+	w, _ = call(`{"model":"gpt-4o"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unrouted model, got %d", w.Code)
+	}
+	w, _ = call(`not json`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "model is required") {
+		t.Errorf("Expected 400 for missing model, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestFallbackWriterHead(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	// 没有写入响应体的尝试在结束时决定是否切换
+	attempt := &fallbackWriter{ResponseWriter: c.Writer, header: make(http.Header), fallback: func(status int) bool { return status == 503 }}
+	attempt.Header().Set("X-Test", "1")
+	attempt.WriteHeader(http.StatusServiceUnavailable)
+	attempt.WriteHeaderNow()
+	if !attempt.rejected || c.Writer.Written() {
+		t.Errorf("Expected 503 to be rejected without writing")
+	}
+
+	attempt = &fallbackWriter{ResponseWriter: c.Writer, header: make(http.Header), fallback: func(status int) bool { return status == 503 }}
+	attempt.Header().Set("X-Test", "1")
+	attempt.WriteHeader(http.StatusNoContent)
+	attempt.WriteHeaderNow()
+	if attempt.rejected || c.Writer.Status() != http.StatusNoContent || c.Writer.Header().Get("X-Test") != "1" {
+		t.Errorf("Expected 204 to be committed, got %d %v", c.Writer.Status(), c.Writer.Header())
+	}
+}
+
+func TestProxyHandlerModelRoutingAllowedTargets(t *testing.T) {
+	var primaryHits int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&primaryHits, 1)
+		io.WriteString(w, "primary")
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "secondary")
+	}))
+	defer secondary.Close()
+
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"primary":   {URL: primary.URL},
+			"secondary": {URL: secondary.URL},
+		},
+		ModelRouting: map[string]config.ModelRouterConfig{
+			"v1": {Default: "primary", Routes: []config.ModelRoute{{
+				Models:  []string{"llama-*"},
+				Targets: []config.ModelTarget{{Service: "primary"}, {Service: "secondary"}},
+			}}},
+		},
+	})
+	gin.SetMode(gin.TestMode)
+
+	call := func(services []string, body string) *httptest.ResponseRecorder {
+		r := gin.New()
+		r.Any("/:service/*path", func(c *gin.Context) {
+			c.Set(middleware.ClientKey, "team-a")
+			c.Set(middleware.ClientServicesKey, services)
+		}, ProxyHandler)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body)))
+		return w
+	}
+
+	// 客户端无权访问的目标被跳过
+	w := call([]string{"v1", "secondary"}, `{"model":"llama-3"}`)
+	if w.Code != http.StatusOK || w.Body.String() != "secondary" || atomic.LoadInt32(&primaryHits) != 0 {
+		t.Errorf("Expected request to skip forbidden target, got %d %q", w.Code, w.Body.String())
+	}
+
+	// 所有目标都无权访问时返回 403
+	w = call([]string{"v1", "secondary"}, `{"model":"gpt-4o"}`)
+	if w.Code != http.StatusForbidden || atomic.LoadInt32(&primaryHits) != 0 {
+		t.Errorf("Expected 403 when no target is allowed, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -1,77 +1,389 @@
 package handler
 
 import (
+	"bytes"
+	"context"
+	stderrors "errors"
 	"io"
 	"net/http"
-	"net/url"
 	"strings"
+	"sync/atomic"
+	"time"
+
 	"sub-router/internal/config"
+	"sub-router/internal/upstream"
+	"sub-router/pkg/errors"
+	"sub-router/pkg/loadbalance"
+	"sub-router/pkg/transport"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/net/proxy"
+)
+
+var (
+	// errNoBackend 没有可用后端
+	errNoBackend = stderrors.New("no available backend")
+
+	// errNoCredential 服务配置了上游凭证但没有可用密钥
+	errNoCredential = stderrors.New("no upstream credential available")
 )
 
 // ProxyHandler 处理代理请求
 func ProxyHandler(c *gin.Context) {
 	// 获取目标服务和路径
+	start := time.Now()
 	service := c.Param("service")
 	path := c.Param("path")
 
-	// 获取目标基础URL
-	baseURL, exists := config.GetAPIMapping(service)
+	// 获取服务映射，不存在时检查按模型路由的虚拟服务
+	mapping, exists := config.GetAPIMapping(service)
 	if !exists {
+		if router, ok := config.GetModelRouter(service); ok {
+			routeModel(c, service, router, start)
+			return
+		}
 		c.AbortWithStatus(http.StatusNotFound)
 		return
 	}
+	proxy(c, service, mapping, path, start)
+}
 
-	// 构建目标URL
-	targetURL := baseURL
-	if path != "" {
-		// 确保path不以/开头
-		if strings.HasPrefix(path, "/") {
-			path = path[1:]
+// proxy 将请求转发到服务，path 为服务内的相对路径
+func proxy(c *gin.Context, service string, mapping config.APIMapping, path string, start time.Time) {
+	// 按服务的转换规则改写请求，缓存键和合并键使用改写后的请求
+	svc := upstream.GlobalRegistry.Resolve(service, mapping)
+
+	// 抽样镜像的请求在转换之前复制，影子请求按影子服务自身的规则转换
+	mirror := newShadowMirror(c, service, mapping, path)
+	defer mirror.finish(c, start)
+
+	path, orig, err := transformRequest(c, svc, path)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			errors.Wrap(err, errors.ErrorTypeValidation, "failed to read request body", http.StatusBadRequest).
+				ToResponse(c.GetString("trace_id")))
+		return
+	}
+	// 翻译为上游接口格式，在转换规则之后执行
+	path, translation, err := translateRequest(c, svc, path)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			errors.New(errors.ErrorTypeValidation, err.Error(), http.StatusBadRequest).
+				ToResponse(c.GetString("trace_id")))
+		return
+	}
+
+	// 命中响应缓存时直接返回，不占用上游和熔断配额。
+	// 合并键在缓存附加条件请求头之前计算
+	lookup := newCacheLookup(c, service, mapping, path)
+	flight := newCoalescer(c, service, mapping, path)
+	if lookup != nil && lookup.serve(c) {
+		return
+	}
+
+	// 相同的请求正在进行时等待并共享其响应
+	if flight != nil {
+		if flight.wait(c, svc) {
+			return
 		}
-		// 确保baseURL以/结尾
-		if !strings.HasSuffix(baseURL, "/") {
-			targetURL += "/"
+		// 共享的响应不可用时重新检查缓存，首个请求可能已经刷新了缓存
+		if flight.call == nil && lookup != nil && lookup.serve(c) {
+			return
 		}
-		targetURL += path
+		defer flight.release()
 	}
 
-	// 添加查询参数
-	if c.Request.URL.RawQuery != "" {
-		targetURL += "?" + c.Request.URL.RawQuery
+	// 熔断中的服务直接失败，避免持续请求已降级的上游
+	if !svc.Allow() {
+		abortCircuitOpen(c, service)
+		return
 	}
 
-	// 创建新的请求
-	req, err := http.NewRequestWithContext(c.Request.Context(), c.Request.Method, targetURL, c.Request.Body)
-	if err != nil {
-		c.AbortWithStatus(http.StatusBadRequest)
-		return
+	// 总超时覆盖重试和流式响应，通过请求上下文传递给上游请求
+	if mapping.Timeouts != nil && mapping.Timeouts.Total > 0 {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), mapping.Timeouts.Total)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
 	}
 
-	// 复制请求头
-	copyHeaders(c.Request.Header, req.Header)
+	// 只有可重试的方法且请求体能够缓存时才启用重试
+	policy := newRetryPolicy(mapping.Retry)
+	if !policy.allowMethod(c.Request.Method) {
+		policy.MaxAttempts = 1
+	}
+	var body []byte
+	stream := c.Request.Body
+	if policy.MaxAttempts > 1 {
+		buffered, rest, ok := replayableBody(c.Request, policy.MaxBodySize)
+		if ok {
+			body, stream = buffered, nil
+		} else {
+			policy.MaxAttempts, stream = 1, rest
+		}
+	}
 
-	// 获取HTTP客户端
-	client := getHTTPClient()
+	// 影子请求与主请求同时发出，主请求不等待其结果
+	mirror.start()
 
-	// 发送请求
-	resp, err := client.Do(req)
+	// 发送请求（失败时按策略重试并切换后端）
+	resp, err := forward(c, svc, path, policy, body, stream)
 	if err != nil {
-		c.AbortWithStatus(http.StatusBadGateway)
+		if flight != nil {
+			flight.fail(c, err)
+		}
+		abortUpstreamError(c, svc, err)
+		return
+	}
+	if err := translateResponse(translation, resp); err != nil {
+		resp.Body.Close()
+		abortUpstreamError(c, svc, err)
+		return
+	}
+	if err := transformResponse(svc, orig, resp); err != nil {
+		resp.Body.Close()
+		abortUpstreamError(c, svc, err)
+		return
+	}
+	if lookup != nil && lookup.handle(c, resp) {
 		return
 	}
+	if flight != nil {
+		if err := flight.share(c, resp); err != nil {
+			abortUpstreamError(c, svc, err)
+			return
+		}
+	}
+	meterUsage(c, service, resp, start)
+	mirror.capture(resp)
 	defer resp.Body.Close()
 
 	// 设置响应头
 	copyHeaders(resp.Header, c.Writer.Header())
 
+	// 流式响应（SSE、NDJSON、分块）逐块刷新，并禁止反向代理缓冲
+	streaming := isStreaming(resp)
+	if streaming && isEventStream(resp) {
+		c.Header("X-Accel-Buffering", "no")
+	}
+
 	// 设置状态码
 	c.Status(resp.StatusCode)
 
-	// 转发响应体
-	io.Copy(c.Writer, resp.Body)
+	// 转发响应体，响应头已发出，出错时只能中断连接
+	if streaming {
+		c.Writer.WriteHeaderNow()
+		c.Writer.Flush()
+		err = copyStream(c.Writer, resp.Body)
+	} else {
+		_, err = io.Copy(c.Writer, resp.Body)
+	}
+	if err != nil {
+		c.Error(err)
+	}
+}
+
+// abortUpstreamError 返回上游请求失败的错误响应，此时尚未写入任何响应
+func abortUpstreamError(c *gin.Context, svc *upstream.Service, err error) {
+	switch {
+	case err == errNoBackend && svc.HasOpenBackend():
+		abortCircuitOpen(c, svc.Name)
+	case err == errUpstreamTimeout || stderrors.Is(err, context.DeadlineExceeded):
+		c.AbortWithStatusJSON(http.StatusGatewayTimeout,
+			errors.New(errors.ErrorTypeTimeout, "upstream response timeout", http.StatusGatewayTimeout).
+				ToResponse(c.GetString("trace_id")))
+	case err == errNoBackend:
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable,
+			errors.New(errors.ErrorTypeProxy, "no available backend", http.StatusServiceUnavailable).
+				ToResponse(c.GetString("trace_id")))
+	default:
+		c.AbortWithStatusJSON(http.StatusBadGateway,
+			errors.Wrap(err, errors.ErrorTypeProxy, "upstream request failed", http.StatusBadGateway).
+				ToResponse(c.GetString("trace_id")))
+	}
+}
+
+// forward 将请求发送到上游，按重试策略在失败时退避并切换到其他后端
+func forward(c *gin.Context, svc *upstream.Service, path string, policy retryPolicy, body []byte, stream io.ReadCloser) (*http.Response, error) {
+	ctx := c.Request.Context()
+	tried := make(map[string]bool)
+	timeouts := timeoutsFor(svc.Mapping)
+
+	for attempt := 1; ; attempt++ {
+		backend := svc.Next(tried)
+		if backend == nil {
+			return nil, errNoBackend
+		}
+		tried[backend.URL] = true
+
+		// 构建请求体：可重放时每次使用缓存的副本
+		reqBody := stream
+		if body != nil {
+			reqBody = io.NopCloser(bytes.NewReader(body))
+		}
+
+		resp, err := send(ctx, c.Request, svc, backend, path, reqBody, timeouts)
+		if err == errNoCredential {
+			svc.Cancel(backend)
+			return nil, err
+		}
+
+		// 记录请求结果到熔断器，客户端主动断开不计入上游失败，但需要归还试探名额
+		switch {
+		case err != nil && stderrors.Is(err, context.Canceled):
+			svc.Cancel(backend)
+		case err != nil:
+			svc.Failure(backend)
+		case err == nil && isUpstreamFailure(resp.StatusCode):
+			svc.Failure(backend)
+		case err == nil:
+			svc.Success(backend)
+		}
+
+		if attempt >= policy.MaxAttempts {
+			return resp, err
+		}
+		if err != nil && !retriableError(ctx, err) {
+			return nil, err
+		}
+		if err == nil && !policy.retriableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+
+		delay, ok := policy.retryDelay(attempt, resp)
+		if !ok {
+			return resp, nil
+		}
+		if resp != nil {
+			// 丢弃响应体以便连接复用
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+			resp.Body.Close()
+		}
+		if !sleepContext(ctx, delay) {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// send 向指定后端发送一次请求。
+//
+// 每次请求使用独立的可取消上下文：客户端断开时随请求上下文取消，响应头超时后由计时器取消，
+// 返回后由响应体的空闲计时器和 Close 负责取消，因此流式响应不受总时长限制
+func send(ctx context.Context, src *http.Request, svc *upstream.Service, backend *loadbalance.Backend, path string, body io.ReadCloser, timeouts upstreamTimeouts) (*http.Response, error) {
+	// 构建目标URL，配置了上游凭证时移除客户端在查询参数中携带的凭证
+	rawQuery := src.URL.RawQuery
+	if svc.Credentials != nil {
+		rawQuery = svc.Credentials.StripQuery(rawQuery)
+	}
+	targetURL := buildTargetURL(backend.URL, path, rawQuery)
+
+	// 创建新的请求
+	ctx, cancel := context.WithCancel(ctx)
+	req, err := http.NewRequestWithContext(ctx, src.Method, targetURL, body)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if body != nil && src.ContentLength > 0 {
+		req.ContentLength = src.ContentLength
+	}
+
+	// 复制请求头
+	copyHeaders(src.Header, req.Header)
+
+	// 注入上游凭证，每次尝试重新选择密钥以便避开被限流的密钥
+	var key *upstream.Key
+	if svc.Credentials != nil {
+		if key = svc.Credentials.Next(); key == nil {
+			cancel()
+			return nil, errNoCredential
+		}
+		svc.Credentials.Inject(req, key)
+	}
+
+	// 获取HTTP客户端
+	client, err := transport.ClientForService(svc.Name)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// 响应头超时：计时器触发时取消请求
+	var headerTimer *time.Timer
+	if timeouts.Header > 0 {
+		headerTimer = time.AfterFunc(timeouts.Header, cancel)
+	}
+
+	// 发送请求，活跃连接数在响应体关闭时减少
+	atomic.AddInt64(&backend.Requests, 1)
+	atomic.AddInt64(&backend.Active, 1)
+	resp, err := client.Do(req)
+	timedOut := headerTimer != nil && !headerTimer.Stop()
+	if err == nil && timedOut {
+		// 响应头与超时同时到达，上下文已取消，响应体不可用
+		resp.Body.Close()
+	}
+	if err != nil || timedOut {
+		atomic.AddInt64(&backend.Active, -1)
+		cancel()
+		if timedOut {
+			if clientErr := src.Context().Err(); clientErr != nil {
+				return nil, clientErr
+			}
+			return nil, errUpstreamTimeout
+		}
+		return nil, err
+	}
+	if key != nil {
+		svc.Credentials.Observe(key, resp)
+	}
+	resp.Body = newStreamBody(&activeBody{ReadCloser: resp.Body, backend: backend}, cancel, timeouts.Idle)
+	return resp, nil
+}
+
+// activeBody 在关闭时减少后端活跃连接数
+type activeBody struct {
+	io.ReadCloser
+	backend *loadbalance.Backend
+	closed  int32
+}
+
+// Close 关闭响应体
+func (b *activeBody) Close() error {
+	if atomic.CompareAndSwapInt32(&b.closed, 0, 1) {
+		atomic.AddInt64(&b.backend.Active, -1)
+	}
+	return b.ReadCloser.Close()
+}
+
+// abortCircuitOpen 返回熔断响应
+func abortCircuitOpen(c *gin.Context, service string) {
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable,
+		errors.New(errors.ErrorTypeThirdParty, "circuit breaker is open for service "+service, http.StatusServiceUnavailable).
+			ToResponse(c.GetString("trace_id")))
+}
+
+// isUpstreamFailure 判断上游响应是否计为失败
+func isUpstreamFailure(status int) bool {
+	return status >= http.StatusInternalServerError
+}
+
+// buildTargetURL 构建目标URL
+func buildTargetURL(baseURL, path, rawQuery string) string {
+	targetURL := baseURL
+	if path != "" {
+		// 确保path不以/开头
+		path = strings.TrimPrefix(path, "/")
+		// 确保baseURL以/结尾
+		if !strings.HasSuffix(baseURL, "/") {
+			targetURL += "/"
+		}
+		targetURL += path
+	}
+
+	// 添加查询参数
+	if rawQuery != "" {
+		targetURL += "?" + rawQuery
+	}
+	return targetURL
 }
 
 // copyHeaders 复制HTTP头
@@ -94,35 +406,3 @@ func isAllowedHeader(header string) bool {
 	}
 	return true
 }
-
-// getHTTPClient 获取HTTP客户端
-func getHTTPClient() *http.Client {
-	// 检查是否启用代理
-	enabled, proxyURL := config.GetProxyConfig()
-	if !enabled || proxyURL == "" {
-		return http.DefaultClient
-	}
-
-	// 解析代理URL
-	parsedURL, err := url.Parse(proxyURL)
-	if err != nil {
-		return http.DefaultClient
-	}
-
-	// 根据代理类型创建Transport
-	transport := &http.Transport{}
-
-	if parsedURL.Scheme == "socks5" {
-		// SOCKS5代理
-		dialer, err := proxy.SOCKS5("tcp", parsedURL.Host, nil, proxy.Direct)
-		if err != nil {
-			return http.DefaultClient
-		}
-		transport.Dial = dialer.Dial
-	} else {
-		// HTTP/HTTPS代理
-		transport.Proxy = http.ProxyURL(parsedURL)
-	}
-
-	return &http.Client{Transport: transport}
-}
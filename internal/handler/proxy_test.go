@@ -5,19 +5,22 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sub-router/internal/config"
+	"sub-router/pkg/errors"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func TestProxyHandler(t *testing.T) {
 	// 设置测试配置
-	config.GlobalConfig = config.Config{
-		APIMappings: map[string]string{
-			"test": "http://localhost:8888",
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"test": {URL: "http://localhost:8888"},
 		},
-	}
+	})
 
 	// 创建测试服务器
 	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -28,7 +31,11 @@ func TestProxyHandler(t *testing.T) {
 	defer backend.Close()
 
 	// 更新测试配置
-	config.GlobalConfig.APIMappings["test"] = backend.URL
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"test": {URL: backend.URL},
+		},
+	})
 
 	// 创建测试请求
 	gin.SetMode(gin.TestMode)
@@ -67,11 +74,11 @@ func TestProxyHandler(t *testing.T) {
 
 func TestProxyHandlerWithBody(t *testing.T) {
 	// 设置测试配置
-	config.GlobalConfig = config.Config{
-		APIMappings: map[string]string{
-			"test": "http://localhost:8888",
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"test": {URL: "http://localhost:8888"},
 		},
-	}
+	})
 
 	// 创建测试服务器
 	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -81,7 +88,11 @@ func TestProxyHandlerWithBody(t *testing.T) {
 	defer backend.Close()
 
 	// 更新测试配置
-	config.GlobalConfig.APIMappings["test"] = backend.URL
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"test": {URL: backend.URL},
+		},
+	})
 
 	// 创建测试请求
 	gin.SetMode(gin.TestMode)
@@ -116,3 +127,129 @@ func TestProxyHandlerWithBody(t *testing.T) {
 		t.Errorf("Expected body %q, got %q", string(requestBody), body)
 	}
 }
+
+func TestProxyHandlerBackendPool(t *testing.T) {
+	// 创建两个后端服务器
+	newBackend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}))
+	}
+	primary := newBackend("primary")
+	defer primary.Close()
+	mirror := newBackend("mirror")
+	defer mirror.Close()
+	backup := newBackend("backup")
+	defer backup.Close()
+
+	// 设置测试配置
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"pool": {
+				Strategy: "weighted_round_robin",
+				Backends: []config.BackendConfig{
+					{URL: primary.URL, Weight: 2},
+					{URL: mirror.URL, Weight: 1},
+					{URL: backup.URL, Weight: 1, Priority: 1},
+				},
+			},
+		},
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Any("/:service/*path", ProxyHandler)
+
+	// 验证按权重分发且不使用低优先级后端
+	counts := make(map[string]int)
+	for i := 0; i < 6; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/pool/v1/models", nil))
+		counts[w.Body.String()]++
+	}
+	if counts["primary"] != 4 || counts["mirror"] != 2 || counts["backup"] != 0 {
+		t.Errorf("Unexpected distribution: %v", counts)
+	}
+}
+
+func TestProxyHandlerCircuitBreaker(t *testing.T) {
+	// 创建总是返回 503 的后端
+	var hits int
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer backend.Close()
+
+	// 设置测试配置
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"flaky": {URL: backend.URL},
+		},
+		CircuitBreaker: config.CircuitBreakerConfig{
+			Enabled:          true,
+			ErrorThreshold:   2,
+			SuccessThreshold: 1,
+			Timeout:          time.Minute,
+			MaxRequests:      1,
+		},
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Any("/:service/*path", ProxyHandler)
+
+	// 连续失败达到阈值后熔断
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/flaky/v1", nil))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("Expected upstream status %d, got %d", http.StatusServiceUnavailable, w.Code)
+		}
+	}
+
+	// 熔断后不再请求上游，直接返回结构化错误
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/flaky/v1", nil))
+	if hits != 2 {
+		t.Errorf("Expected upstream to be hit 2 times, got %d", hits)
+	}
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), string(errors.ErrorTypeThirdParty)) {
+		t.Errorf("Expected circuit open response, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestProxyHandlerCredentials(t *testing.T) {
+	// 后端回显收到的凭证
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Api-Key") + "|" + r.Header.Get("Authorization") + "|" + r.URL.RawQuery))
+	}))
+	defer backend.Close()
+
+	// 设置测试配置
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"claude": {
+				URL: backend.URL,
+				Credentials: &config.CredentialConfig{
+					Type: config.CredentialAPIKey,
+					Keys: []config.CredentialKey{{Value: "sk-upstream"}},
+				},
+			},
+		},
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Any("/:service/*path", ProxyHandler)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/claude/v1/messages?beta=true", nil)
+	req.Header.Set("Authorization", "Bearer client-key")
+	req.Header.Set("X-Api-Key", "client-key")
+	router.ServeHTTP(w, req)
+
+	if body := w.Body.String(); body != "sk-upstream||beta=true" {
+		t.Errorf("Unexpected upstream credentials: %s", body)
+	}
+}
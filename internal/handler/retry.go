@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sub-router/internal/config"
+)
+
+// retryPolicy 单次请求的重试策略
+type retryPolicy struct {
+	config.RetryConfig
+}
+
+// newRetryPolicy 创建重试策略，未配置时不重试
+func newRetryPolicy(cfg *config.RetryConfig) retryPolicy {
+	if cfg == nil {
+		return retryPolicy{config.RetryConfig{MaxAttempts: 1}}
+	}
+	policy := retryPolicy{*cfg}
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	return policy
+}
+
+// allowMethod 判断请求方法是否允许重试
+func (p retryPolicy) allowMethod(method string) bool {
+	for _, m := range p.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// retriableStatus 判断响应状态码是否可重试
+func (p retryPolicy) retriableStatus(status int) bool {
+	for _, code := range p.RetryOnStatus {
+		if code == status {
+			return true
+		}
+	}
+	return false
+}
+
+// retriableError 判断请求错误是否可重试，客户端取消或超时不重试
+func retriableError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return !stderrors.Is(err, context.Canceled)
+}
+
+// backoff 计算第 attempt 次失败后的等待时间（带抖动的指数退避）
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay << uint(attempt-1)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	// full jitter：在 [delay/2, delay] 之间随机
+	half := int64(delay / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// retryDelay 计算下次重试前的等待时间，返回 false 表示 Retry-After 过长不应重试
+func (p retryPolicy) retryDelay(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil && p.RespectRetryAfter {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			if p.MaxRetryAfter > 0 && wait > p.MaxRetryAfter {
+				return 0, false
+			}
+			return wait, true
+		}
+	}
+	return p.backoff(attempt), true
+}
+
+// parseRetryAfter 解析 Retry-After 头，支持秒数和 HTTP 日期两种格式
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		wait := time.Until(t)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// replayableBody 缓存请求体以便重试时重放，超过上限时返回 ok=false 并保持原始流可读
+func replayableBody(req *http.Request, limit int64) (body []byte, rest io.ReadCloser, ok bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil, true
+	}
+	if req.ContentLength > limit {
+		return nil, req.Body, false
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(req.Body, limit+1))
+	if err != nil || int64(len(buf)) > limit {
+		// 已读取的部分与剩余数据拼接后继续作为流转发
+		return nil, readCloser{io.MultiReader(bytes.NewReader(buf), req.Body), req.Body}, false
+	}
+	req.Body.Close()
+	return buf, nil, true
+}
+
+// readCloser 组合 Reader 与原始 Closer
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// sleepContext 等待指定时间，上下文结束时提前返回 false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
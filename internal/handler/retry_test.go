@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sub-router/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProxyHandlerRetryFailover(t *testing.T) {
+	// 第一个后端总是返回 503，第二个后端回显请求体
+	var failingHits, healthyHits int32
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&failingHits, 1)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&healthyHits, 1)
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer healthy.Close()
+
+	// 设置测试配置
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"retry": {
+				Backends: []config.BackendConfig{
+					{URL: failing.URL},
+					{URL: healthy.URL},
+				},
+			},
+		},
+		Retry: config.RetryConfig{
+			MaxAttempts:       2,
+			RetryOnStatus:     []int{http.StatusServiceUnavailable},
+			Methods:           []string{"PUT"},
+			BaseDelay:         time.Millisecond,
+			MaxDelay:          10 * time.Millisecond,
+			RespectRetryAfter: true,
+			MaxRetryAfter:     time.Second,
+			MaxBodySize:       1024,
+		},
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Any("/:service/*path", ProxyHandler)
+
+	// 无论首次选中哪个后端，都应最终由健康后端返回并重放请求体
+	for i := 0; i < 4; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("PUT", "/retry/v1", bytes.NewBufferString("payload")))
+		if w.Code != http.StatusOK || w.Body.String() != "payload" {
+			t.Fatalf("Expected replayed body from healthy backend, got %d %q", w.Code, w.Body.String())
+		}
+	}
+	if healthyHits != 4 || failingHits == 0 || failingHits > 4 {
+		t.Errorf("Unexpected hits: healthy=%d failing=%d", healthyHits, failingHits)
+	}
+
+	// 不可重试的方法只请求一次
+	failingHits, healthyHits = 0, 0
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/retry/v1", bytes.NewBufferString("payload")))
+	}
+	if failingHits+healthyHits != 2 {
+		t.Errorf("Expected POST requests not to be retried, got %d upstream hits", failingHits+healthyHits)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := newRetryPolicy(&config.RetryConfig{
+		MaxAttempts:       3,
+		BaseDelay:         100 * time.Millisecond,
+		MaxDelay:          time.Second,
+		RespectRetryAfter: true,
+		MaxRetryAfter:     5 * time.Second,
+	})
+
+	// 指数退避不超过上限
+	for attempt := 1; attempt <= 10; attempt++ {
+		if delay := policy.backoff(attempt); delay > time.Second {
+			t.Errorf("Backoff for attempt %d exceeds max delay: %v", attempt, delay)
+		}
+	}
+
+	// 遵循 Retry-After
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"2"}}}
+	if delay, ok := policy.retryDelay(1, resp); !ok || delay != 2*time.Second {
+		t.Errorf("Expected Retry-After delay of 2s, got %v %v", delay, ok)
+	}
+
+	// Retry-After 过长时放弃重试
+	resp.Header.Set("Retry-After", "60")
+	if _, ok := policy.retryDelay(1, resp); ok {
+		t.Error("Expected retry to be skipped for long Retry-After")
+	}
+}
@@ -0,0 +1,342 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sub-router/internal/config"
+	"sub-router/internal/upstream"
+	"sub-router/pkg/metrics"
+	"sub-router/pkg/transform"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxShadowDiffs 每次对比最多记录的不同字段数
+const maxShadowDiffs = 10
+
+// errShadowCircuitOpen 影子服务熔断中
+var errShadowCircuitOpen = stderrors.New("circuit breaker is open")
+
+// shadowInflight 每个影子服务进行中的请求数
+var shadowInflight sync.Map // map[string]*int64
+
+// shadowMirror 一次被抽样镜像的请求。
+//
+// 影子请求在主请求发往上游时异步发出，主请求结束后把结果交给影子请求的 goroutine 对比，
+// 主请求从不等待影子请求
+type shadowMirror struct {
+	service string
+	cfg     config.ShadowConfig
+	traceID string
+	req     *http.Request // 转换前的客户端请求副本，路径为服务内相对路径
+	body    []byte
+	slot    *int64
+
+	started bool
+	primary chan shadowResult
+	tee     *captureBody // 比较响应体时旁路缓存主响应体
+}
+
+// shadowResult 主请求或影子请求的结果
+type shadowResult struct {
+	status   int
+	latency  time.Duration
+	body     []byte
+	captured bool // 完整读取了可比较的响应体
+	err      error
+}
+
+// newShadowMirror 按配置的比例抽样请求，返回 nil 表示不镜像。
+//
+// 抽中的请求缓存请求体并复制转换前的请求；超过并发上限或请求体过大时放弃镜像，主请求照常转发
+func newShadowMirror(c *gin.Context, service string, mapping config.APIMapping, path string) *shadowMirror {
+	cfg := mapping.Shadow
+	if cfg == nil || cfg.Percent <= 0 || rand.Float64()*100 >= cfg.Percent {
+		return nil
+	}
+	v, _ := shadowInflight.LoadOrStore(cfg.Service, new(int64))
+	slot := v.(*int64)
+	if atomic.AddInt64(slot, 1) > int64(cfg.ConcurrencyLimit()) {
+		atomic.AddInt64(slot, -1)
+		metrics.ShadowRequests.WithLabelValues(service, cfg.Service, "dropped").Inc()
+		return nil
+	}
+	body, rest, ok := replayableBody(c.Request, cfg.BodyLimit())
+	if !ok {
+		c.Request.Body = rest
+		atomic.AddInt64(slot, -1)
+		metrics.ShadowRequests.WithLabelValues(service, cfg.Service, "dropped").Inc()
+		return nil
+	}
+	if body != nil {
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	// 影子请求不随客户端断开而取消
+	req := c.Request.Clone(context.WithoutCancel(c.Request.Context()))
+	req.URL.Path = path
+	req.Body = nil
+	// 客户端凭证属于主服务，不能发给影子服务；影子服务使用自己配置的凭证
+	upstream.StripClientCredentials(req)
+	return &shadowMirror{
+		service: service,
+		cfg:     *cfg,
+		traceID: c.GetString("trace_id"),
+		req:     req,
+		body:    body,
+		slot:    slot,
+		primary: make(chan shadowResult, 1),
+	}
+}
+
+// start 发出影子请求，只在主请求发往上游时调用；命中缓存或熔断的请求不镜像
+func (m *shadowMirror) start() {
+	if m == nil {
+		return
+	}
+	m.started = true
+	go m.run()
+}
+
+// capture 比较响应体时旁路缓存主响应体，流式响应不比较
+func (m *shadowMirror) capture(resp *http.Response) {
+	if m == nil || !m.started || !m.cfg.CompareBody || isStreaming(resp) {
+		return
+	}
+	m.tee = &captureBody{ReadCloser: resp.Body, limit: m.cfg.BodyLimit()}
+	resp.Body = m.tee
+}
+
+// finish 在主请求结束时调用，把主请求的结果交给影子请求
+func (m *shadowMirror) finish(c *gin.Context, start time.Time) {
+	if m == nil {
+		return
+	}
+	if !m.started {
+		atomic.AddInt64(m.slot, -1)
+		return
+	}
+	result := shadowResult{status: c.Writer.Status(), latency: time.Since(start)}
+	if m.tee != nil && m.tee.eof && !m.tee.over {
+		result.body, result.captured = m.tee.buf.Bytes(), true
+	}
+	m.primary <- result
+}
+
+// run 发送影子请求，等待主请求结束后记录对比结果
+func (m *shadowMirror) run() {
+	defer atomic.AddInt64(m.slot, -1)
+	ctx, cancel := context.WithTimeout(m.req.Context(), m.cfg.RequestTimeout())
+	defer cancel()
+	shadow := m.send(ctx)
+	m.record(<-m.primary, shadow)
+}
+
+// send 按影子服务自身的转换、翻译规则发送请求并读取响应
+func (m *shadowMirror) send(ctx context.Context) shadowResult {
+	start := time.Now()
+	mapping, ok := config.GetAPIMapping(m.cfg.Service)
+	if !ok {
+		return shadowResult{err: fmt.Errorf("unknown shadow service %s", m.cfg.Service)}
+	}
+	svc := upstream.GlobalRegistry.Resolve(m.cfg.Service, mapping)
+
+	req := m.req.Clone(ctx)
+	orig := m.req.Clone(ctx)
+	if m.body != nil {
+		req.Body = io.NopCloser(bytes.NewReader(m.body))
+	}
+	if svc.Transformer != nil {
+		if err := svc.Transformer.TransformRequest(req, svc.Name); err != nil {
+			return shadowResult{err: err}
+		}
+	}
+	var translation *transform.Translation
+	if svc.Translator != nil {
+		x, err := svc.Translator.Request(req)
+		if err != nil {
+			return shadowResult{err: err}
+		}
+		translation = x
+	}
+
+	if !svc.Allow() {
+		return shadowResult{err: errShadowCircuitOpen}
+	}
+	backend := svc.Next(nil)
+	if backend == nil {
+		return shadowResult{err: errNoBackend}
+	}
+	resp, err := send(ctx, req, svc, backend, req.URL.Path, req.Body, timeoutsFor(mapping))
+	switch {
+	case err == errNoCredential:
+		svc.Cancel(backend)
+		return shadowResult{err: err}
+	case err != nil:
+		svc.Failure(backend)
+		return shadowResult{err: err}
+	case isUpstreamFailure(resp.StatusCode):
+		svc.Failure(backend)
+	default:
+		svc.Success(backend)
+	}
+	defer resp.Body.Close()
+	if err := translateResponse(translation, resp); err != nil {
+		return shadowResult{err: err}
+	}
+	if err := transformResponse(svc, orig, resp); err != nil {
+		return shadowResult{err: err}
+	}
+
+	// 读完响应体，耗时与主请求一样包含响应体传输时间
+	result := shadowResult{status: resp.StatusCode}
+	if m.cfg.CompareBody && !isStreaming(resp) {
+		body := &captureBody{ReadCloser: resp.Body, limit: m.cfg.BodyLimit()}
+		_, err = io.Copy(io.Discard, body)
+		result.body, result.captured = body.buf.Bytes(), err == nil && !body.over
+	} else {
+		_, err = io.Copy(io.Discard, resp.Body)
+	}
+	result.latency = time.Since(start)
+	result.err = err
+	return result
+}
+
+// record 记录主请求和影子请求的对比结果
+func (m *shadowMirror) record(primary, shadow shadowResult) {
+	if shadow.err != nil {
+		metrics.ShadowRequests.WithLabelValues(m.service, m.cfg.Service, "error").Inc()
+		log.Printf("shadow request failed: service=%s shadow=%s trace_id=%s error=%v", m.service, m.cfg.Service, m.traceID, shadow.err)
+		return
+	}
+	metrics.ShadowRequests.WithLabelValues(m.service, m.cfg.Service, "completed").Inc()
+	metrics.ShadowLatency.WithLabelValues(m.service, m.cfg.Service, "primary").Observe(primary.latency.Seconds())
+	metrics.ShadowLatency.WithLabelValues(m.service, m.cfg.Service, "shadow").Observe(shadow.latency.Seconds())
+
+	status := "match"
+	if primary.status != shadow.status {
+		status = "mismatch"
+	}
+	body, diffs := m.compareBody(primary, shadow)
+	metrics.ShadowComparisons.WithLabelValues(m.service, m.cfg.Service, status, body).Inc()
+
+	diff := "-"
+	if len(diffs) > 0 {
+		diff = strings.Join(diffs, ",")
+	}
+	log.Printf("shadow comparison: service=%s shadow=%s trace_id=%s status=%d/%d latency=%s/%s body=%s diff=%s",
+		m.service, m.cfg.Service, m.traceID, primary.status, shadow.status,
+		primary.latency.Round(time.Millisecond), shadow.latency.Round(time.Millisecond), body, diff)
+}
+
+// compareBody 比较响应体，返回 match、mismatch 或 skipped 以及不同的 JSON 字段
+func (m *shadowMirror) compareBody(primary, shadow shadowResult) (string, []string) {
+	if !m.cfg.CompareBody || !primary.captured || !shadow.captured {
+		return "skipped", nil
+	}
+	var a, b any
+	if json.Unmarshal(primary.body, &a) != nil || json.Unmarshal(shadow.body, &b) != nil {
+		if bytes.Equal(primary.body, shadow.body) {
+			return "match", nil
+		}
+		return "mismatch", nil
+	}
+	if diffs := jsonDiff(a, b, "", m.cfg, nil); len(diffs) > 0 {
+		return "mismatch", diffs
+	}
+	return "match", nil
+}
+
+// jsonDiff 比较两个 JSON 值，返回取值不同的字段（如 choices.0.message.content），根为 $
+func jsonDiff(a, b any, field string, cfg config.ShadowConfig, diffs []string) []string {
+	if len(diffs) >= maxShadowDiffs || (field != "" && cfg.Ignored(field)) {
+		return diffs
+	}
+	name := field
+	if name == "" {
+		name = "$"
+	}
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok {
+			return append(diffs, name)
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			diffs = jsonDiff(av[k], bv[k], joinField(field, k), cfg, diffs)
+		}
+		return diffs
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return append(diffs, name)
+		}
+		for i := range av {
+			diffs = jsonDiff(av[i], bv[i], joinField(field, strconv.Itoa(i)), cfg, diffs)
+		}
+		return diffs
+	}
+	// 标量类型不同时不相等，不会比较不可比较的类型
+	if a != b {
+		return append(diffs, name)
+	}
+	return diffs
+}
+
+// joinField 拼接 JSON 字段路径
+func joinField(field, key string) string {
+	if field == "" {
+		return key
+	}
+	return field + "." + key
+}
+
+// captureBody 读取时缓存响应体，超过上限后停止缓存
+type captureBody struct {
+	io.ReadCloser
+	buf   bytes.Buffer
+	limit int64
+	over  bool // 超过上限
+	eof   bool // 已读完
+}
+
+// Read 读取响应体并缓存
+func (b *captureBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && !b.over {
+		if int64(b.buf.Len()+n) > b.limit {
+			b.over = true
+			b.buf.Reset()
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF {
+		b.eof = true
+	}
+	return n, err
+}
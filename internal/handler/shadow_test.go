@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"sub-router/internal/config"
+	"sub-router/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestProxyHandlerShadow(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"a","model":"gpt-4o","answer":42}`)
+	}))
+	defer primary.Close()
+
+	// 影子服务在主请求返回后才响应，主请求不应等待
+	release := make(chan struct{})
+	received := make(chan string, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r.URL.RequestURI() + " " + r.Header.Get("Authorization") + " " + string(body)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"b","model":"gpt-4o","answer":41}`)
+	}))
+	defer shadow.Close()
+
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"primary": {URL: primary.URL, Shadow: &config.ShadowConfig{
+				Service: "shadow", Percent: 100, CompareBody: true, IgnoreFields: []string{"id"},
+			}},
+			"shadow": {URL: shadow.URL, Credentials: &config.CredentialConfig{
+				Keys: []config.CredentialKey{{Value: "sk-shadow"}},
+			}},
+		},
+	})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/:service/*path", ProxyHandler)
+
+	comparisons := metrics.ShadowComparisons.WithLabelValues("primary", "shadow", "match", "mismatch")
+	before := testutil.ToFloat64(comparisons)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/primary/v1/chat/completions?key=sk-query&v=1", strings.NewReader(`{"q":1}`))
+	req.Header.Set("Authorization", "Bearer sk-client")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"answer":42`) {
+		t.Fatalf("Unexpected primary response %d %s", w.Code, w.Body.String())
+	}
+	select {
+	case got := <-received:
+		// 客户端凭证不会发给影子服务
+		if got != `/v1/chat/completions?v=1 Bearer sk-shadow {"q":1}` {
+			t.Errorf("Unexpected shadow request %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected request to be mirrored")
+	}
+	close(release)
+
+	// id 被忽略，answer 不同
+	deadline := time.Now().Add(2 * time.Second)
+	for testutil.ToFloat64(comparisons) == before {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected comparison to be recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestJSONDiff(t *testing.T) {
+	decode := func(s string) any {
+		var v any
+		json.Unmarshal([]byte(s), &v)
+		return v
+	}
+	cfg := config.ShadowConfig{IgnoreFields: []string{"id", "usage"}}
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{`{"id":"a","usage":{"total_tokens":1},"x":1}`, `{"id":"b","usage":{"total_tokens":2},"x":1}`, ""},
+		{`{"choices":[{"text":"a"},{"text":"b"}]}`, `{"choices":[{"text":"a"},{"text":"c"}]}`, "choices.1.text"},
+		{`{"choices":[1]}`, `{"choices":[1,2]}`, "choices"},
+		{`{"a":1,"b":{"c":true}}`, `{"b":"x","d":null,"e":1}`, "a,b,e"},
+		{`[1]`, `{"a":1}`, "$"},
+	}
+	for _, tt := range tests {
+		got := strings.Join(jsonDiff(decode(tt.a), decode(tt.b), "", cfg, nil), ",")
+		if got != tt.want {
+			t.Errorf("jsonDiff(%s, %s) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sub-router/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// errUpstreamTimeout 上游在超时时间内没有返回响应头
+	errUpstreamTimeout = stderrors.New("upstream response header timeout")
+
+	// errStreamIdle 流式响应超过空闲超时时间没有新数据
+	errStreamIdle = stderrors.New("upstream stream idle timeout")
+)
+
+// streamBufferSize 流式转发的缓冲区大小
+const streamBufferSize = 32 * 1024
+
+// streamingTypes 按事件推送的响应类型，每次写入后立即刷新
+var streamingTypes = map[string]bool{
+	"text/event-stream":    true,
+	"application/x-ndjson": true,
+	"application/ndjson":   true,
+	"application/jsonl":    true,
+}
+
+// isStreaming 判断响应是否为流式响应：SSE、NDJSON 或长度未知的分块响应
+func isStreaming(resp *http.Response) bool {
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && streamingTypes[mediaType] {
+		return true
+	}
+	return resp.ContentLength < 0
+}
+
+// isEventStream 判断响应是否为 SSE
+func isEventStream(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
+
+// copyStream 逐块转发流式响应，每次写入后立即刷新；客户端断开时停止
+func copyStream(w gin.ResponseWriter, body io.Reader) error {
+	buf := make([]byte, streamBufferSize)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			w.Flush()
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// streamBody 上游响应体，在两次读取之间超过空闲超时时取消上游请求，关闭时释放请求上下文
+type streamBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+	idle   time.Duration
+
+	mu       sync.Mutex
+	timer    *time.Timer
+	timedOut atomic.Bool
+}
+
+// newStreamBody 包装响应体，idle 为 0 时不限制空闲时间
+func newStreamBody(body io.ReadCloser, cancel context.CancelFunc, idle time.Duration) *streamBody {
+	b := &streamBody{ReadCloser: body, cancel: cancel, idle: idle}
+	if idle > 0 {
+		b.timer = time.AfterFunc(idle, b.expire)
+	}
+	return b
+}
+
+// expire 空闲超时，取消上游请求使阻塞的读取返回
+func (b *streamBody) expire() {
+	b.timedOut.Store(true)
+	b.cancel()
+}
+
+// Read 读取响应体，每次读到数据后重置空闲计时
+func (b *streamBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.timedOut.Load() {
+		if err != io.EOF {
+			err = errStreamIdle
+		}
+		return n, err
+	}
+	if n > 0 && b.timer != nil {
+		b.mu.Lock()
+		b.timer.Reset(b.idle)
+		b.mu.Unlock()
+	}
+	return n, err
+}
+
+// Close 关闭响应体并取消上游请求
+func (b *streamBody) Close() error {
+	if b.timer != nil {
+		b.mu.Lock()
+		b.timer.Stop()
+		b.mu.Unlock()
+	}
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// upstreamTimeouts 单次上游请求的超时设置，0 表示不限制
+type upstreamTimeouts struct {
+	Header time.Duration // 等待响应头的时间
+	Idle   time.Duration // 流式响应两次读取之间的最大间隔
+}
+
+// timeoutsFor 获取服务的上游请求超时；连接和 TLS 握手超时由 Transport 控制，总时长由请求上下文控制
+func timeoutsFor(mapping config.APIMapping) upstreamTimeouts {
+	if mapping.Timeouts == nil {
+		return upstreamTimeouts{}
+	}
+	return upstreamTimeouts{Header: mapping.Timeouts.ResponseHeader, Idle: mapping.Timeouts.IdleStream}
+}
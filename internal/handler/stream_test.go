@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"sub-router/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newStreamProxy 启动指向 backend 的代理服务
+func newStreamProxy(t *testing.T, backend *httptest.Server, timeouts config.TimeoutsConfig) *httptest.Server {
+	config.Set(&config.Config{
+		Timeouts: timeouts,
+		APIMappings: map[string]config.APIMapping{
+			"stream": {URL: backend.URL},
+		},
+	})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/:service/*path", ProxyHandler)
+	proxy := httptest.NewServer(r)
+	t.Cleanup(proxy.Close)
+	return proxy
+}
+
+func TestProxyHandlerStreamsEvents(t *testing.T) {
+	next := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			io.WriteString(w, "data: event\n\n")
+			w.(http.Flusher).Flush()
+			select {
+			case <-next:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}))
+	defer backend.Close()
+	proxy := newStreamProxy(t, backend, config.TimeoutsConfig{ResponseHeader: time.Second, IdleStream: time.Second})
+
+	resp, err := http.Get(proxy.URL + "/stream/events")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("X-Accel-Buffering") != "no" {
+		t.Error("Expected X-Accel-Buffering: no for SSE")
+	}
+
+	// 每个事件在上游发送下一个事件之前就应到达客户端
+	reader := bufio.NewReader(resp.Body)
+	for i := 0; i < 3; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil || line != "data: event\n" {
+			t.Fatalf("Event %d: got %q, %v", i, line, err)
+		}
+		reader.ReadString('\n')
+		next <- struct{}{}
+	}
+}
+
+func TestProxyHandlerStreamIdleTimeout(t *testing.T) {
+	canceled := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(canceled)
+	}))
+	defer backend.Close()
+	// 响应头超时短于整个流的持续时间，流式响应不应被截断
+	proxy := newStreamProxy(t, backend, config.TimeoutsConfig{ResponseHeader: 50 * time.Millisecond, IdleStream: 200 * time.Millisecond})
+
+	start := time.Now()
+	resp, err := http.Get(proxy.URL + "/stream/events")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "data: first") {
+		t.Errorf("Expected first event, got %q", body)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("Expected stream to end after idle timeout, took %v", elapsed)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("Expected upstream request to be canceled")
+	}
+}
+
+func TestProxyHandlerStreamClientDisconnect(t *testing.T) {
+	canceled := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(canceled)
+	}))
+	defer backend.Close()
+	proxy := newStreamProxy(t, backend, config.TimeoutsConfig{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", proxy.URL+"/stream/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	bufio.NewReader(resp.Body).ReadString('\n')
+	cancel()
+	resp.Body.Close()
+
+	select {
+	case <-canceled:
+	case <-time.After(2 * time.Second):
+		t.Error("Expected client disconnect to cancel upstream request")
+	}
+}
+
+func TestProxyHandlerResponseHeaderTimeout(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer backend.Close()
+	proxy := newStreamProxy(t, backend, config.TimeoutsConfig{ResponseHeader: 50 * time.Millisecond})
+
+	resp, err := http.Post(proxy.URL+"/stream/slow", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("Expected status 504, got %d", resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "TIMEOUT_ERROR") {
+		t.Errorf("Expected timeout error, got %s", body)
+	}
+}
+
+func TestProxyHandlerTotalTimeout(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for {
+			io.WriteString(w, "data: tick\n\n")
+			w.(http.Flusher).Flush()
+			select {
+			case <-time.After(20 * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}))
+	defer backend.Close()
+
+	// 持续有数据的流不会触发空闲超时，但受总时长限制
+	config.Set(&config.Config{
+		Timeouts: config.TimeoutsConfig{IdleStream: time.Second},
+		APIMappings: map[string]config.APIMapping{
+			"stream": {URL: backend.URL, Timeouts: &config.TimeoutsConfig{Total: 200 * time.Millisecond}},
+		},
+	})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/:service/*path", ProxyHandler)
+	proxy := httptest.NewServer(r)
+	defer proxy.Close()
+
+	start := time.Now()
+	resp, err := http.Get(proxy.URL + "/stream/events")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 once streaming started, got %d", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("Expected stream to end at total timeout, took %v", elapsed)
+	}
+}
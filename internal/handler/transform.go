@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"net/http"
+
+	"sub-router/internal/upstream"
+	"sub-router/pkg/transform"
+
+	"github.com/gin-gonic/gin"
+)
+
+// transformRequest 按服务的转换规则改写请求，返回改写后的相对路径。
+//
+// 同时返回改写前的请求副本（路径为服务内相对路径），响应规则按原始请求匹配
+func transformRequest(c *gin.Context, svc *upstream.Service, path string) (string, *http.Request, error) {
+	if svc.Transformer == nil {
+		return path, nil, nil
+	}
+	orig := c.Request.Clone(c.Request.Context())
+	orig.URL.Path = path
+	req := c.Request.Clone(c.Request.Context())
+	req.URL.Path = path
+	if err := svc.Transformer.TransformRequest(req, svc.Name); err != nil {
+		return "", nil, err
+	}
+
+	// 只替换转发时使用的部分，访问日志仍记录客户端的原始路径
+	c.Request.Header = req.Header
+	c.Request.URL.RawQuery = req.URL.RawQuery
+	c.Request.Body = req.Body
+	c.Request.ContentLength = req.ContentLength
+	return req.URL.Path, orig, nil
+}
+
+// transformResponse 按服务的转换规则改写上游响应
+func transformResponse(svc *upstream.Service, orig *http.Request, resp *http.Response) error {
+	if svc.Transformer == nil {
+		return nil
+	}
+	resp.Request = orig
+	return svc.Transformer.TransformResponse(resp, svc.Name)
+}
+
+// translateRequest 将 OpenAI Chat Completions 请求翻译为服务的上游格式，返回翻译后的相对路径。
+//
+// 不需要翻译的请求返回的 Translation 为空
+func translateRequest(c *gin.Context, svc *upstream.Service, path string) (string, *transform.Translation, error) {
+	if svc.Translator == nil {
+		return path, nil, nil
+	}
+	req := c.Request.Clone(c.Request.Context())
+	req.URL.Path = path
+	x, err := svc.Translator.Request(req)
+	if err != nil || x == nil {
+		return path, nil, err
+	}
+
+	c.Request.Header = req.Header
+	c.Request.URL.RawQuery = req.URL.RawQuery
+	c.Request.Body = req.Body
+	c.Request.ContentLength = req.ContentLength
+	return req.URL.Path, x, nil
+}
+
+// translateResponse 将上游响应翻译回 OpenAI 格式，在转换规则之前执行
+func translateResponse(x *transform.Translation, resp *http.Response) error {
+	if x == nil {
+		return nil
+	}
+	return x.Response(resp)
+}
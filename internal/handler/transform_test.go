@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sub-router/internal/config"
+	"sub-router/pkg/transform"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProxyHandlerTransforms(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Internal", "1")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"path":   r.URL.Path,
+			"query":  r.URL.RawQuery,
+			"header": r.Header.Get("X-Team"),
+			"body":   string(body),
+		})
+	}))
+	defer backend.Close()
+
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"transformed": {
+				URL: backend.URL,
+				Transforms: []transform.Spec{{
+					Match: transform.MatchSpec{Path: "^/v1/"},
+					Request: transform.RequestSpec{
+						Path:    []transform.PathRewrite{{Pattern: "^/v1/", Replace: "/v2/"}},
+						Query:   transform.QuerySpec{Set: []transform.NameValue{{Name: "version", Value: "2"}}},
+						Headers: transform.HeaderSpec{Rename: []transform.Rename{{From: "X-Client-Team", To: "X-Team"}}},
+						Body:    transform.BodySpec{Set: []transform.FieldValue{{Path: "$.stream", Value: false}}},
+					},
+					Response: transform.ResponseSpec{
+						Status:  []transform.StatusMap{{From: 404, To: 200}},
+						Headers: transform.HeaderSpec{Remove: []string{"X-Internal"}},
+						Body:    transform.BodySpec{Delete: []string{"$.query"}},
+					},
+				}},
+			},
+		},
+	})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/:service/*path", ProxyHandler)
+
+	req := httptest.NewRequest("POST", "/transformed/v1/chat", strings.NewReader(`{"model":"m"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Client-Team", "a")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Header().Get("X-Internal") != "" {
+		t.Fatalf("Unexpected response %d %v", w.Code, w.Header())
+	}
+	var got map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode response %q: %v", w.Body.String(), err)
+	}
+	want := map[string]string{"path": "/v2/chat", "header": "a", "body": `{"model":"m","stream":false}`}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("Expected %s %q, got %q", key, value, got[key])
+		}
+	}
+	if _, ok := got["query"]; ok {
+		t.Errorf("Expected query to be removed from response, got %v", got)
+	}
+}
+
+func TestProxyHandlerTransformsStream(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{`{"id":1,"internal":true}`, `{"id":2,"internal":true}`, "[DONE]"} {
+			io.WriteString(w, "data: "+event+"\n\n")
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer backend.Close()
+
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"transformed-stream": {
+				URL: backend.URL,
+				Transforms: []transform.Spec{{
+					Response: transform.ResponseSpec{Body: transform.BodySpec{Delete: []string{"$.internal"}}},
+				}},
+			},
+		},
+	})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/:service/*path", ProxyHandler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/transformed-stream/events", nil))
+	want := "data: {\"id\":1}\n\ndata: {\"id\":2}\n\ndata: [DONE]\n\n"
+	if w.Code != http.StatusOK || w.Body.String() != want {
+		t.Errorf("Unexpected stream %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestProxyHandlerTranslate(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path != "/v1/messages" || r.Header.Get("X-Api-Key") != "sk-test" || body["max_tokens"] == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"type":"error","error":{"type":"invalid_request_error","message":"unexpected request"}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"msg_1","type":"message","model":"claude","content":[{"type":"text","text":"hi"}],"stop_reason":"end_turn","usage":{"input_tokens":3,"output_tokens":1}}`)
+	}))
+	defer backend.Close()
+
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"translated": {URL: backend.URL, Translate: "anthropic"},
+		},
+	})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/:service/*path", ProxyHandler)
+
+	req := httptest.NewRequest("POST", "/translated/v1/chat/completions", strings.NewReader(`{"model":"claude","messages":[{"role":"user","content":"hello"}]}`))
+	req.Header.Set("Authorization", "Bearer sk-test")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var got struct {
+		Object  string `json:"object"`
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Unexpected response %d %q", w.Code, w.Body.String())
+	}
+	if got.Object != "chat.completion" || len(got.Choices) != 1 || got.Choices[0].Message.Content != "hi" ||
+		got.Choices[0].FinishReason != "stop" || got.Usage.TotalTokens != 4 {
+		t.Errorf("Unexpected translated response %s", w.Body.String())
+	}
+
+	// 无法解析的请求返回 400
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/translated/v1/chat/completions", strings.NewReader(`{"messages":[]}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid request, got %d", w.Code)
+	}
+}
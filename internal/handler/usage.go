@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"sub-router/internal/config"
+	"sub-router/internal/middleware"
+	"sub-router/pkg/usage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// meterUsage 为 LLM 接口的成功响应包装用量统计，响应体关闭时记录用量。
+//
+// SSE 和 NDJSON 在转发时逐行解析，其他 JSON 响应缓存至 usage.max_body_size 后整体解析。
+// 启用配额时 token 和费用配额依赖用量记录，即使 usage.enabled 为 false 也会解析
+func meterUsage(c *gin.Context, service string, resp *http.Response, start time.Time) {
+	snapshot := config.Get()
+	cfg := snapshot.Usage
+	if !cfg.Enabled && !snapshot.Quotas.Enabled {
+		return
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	stream := streamingTypes[mediaType]
+	if !stream && mediaType != "application/json" {
+		return
+	}
+	encoding := strings.ToLower(resp.Header.Get("Content-Encoding"))
+	if encoding != "" && encoding != "identity" && (stream || encoding != "gzip") {
+		return
+	}
+
+	body := &usageBody{
+		ReadCloser: resp.Body,
+		limit:      cfg.BodyLimit(),
+		gzip:       encoding == "gzip",
+		start:      start,
+		record: usage.Record{
+			TraceID: c.GetString("trace_id"),
+			Client:  middleware.ClientName(c),
+			Service: service,
+			Method:  c.Request.Method,
+			Path:    c.Param("path"),
+			Status:  resp.StatusCode,
+			Stream:  stream,
+		},
+	}
+	if stream {
+		body.parser = usage.NewStreamParser(0)
+	}
+	resp.Body = body
+}
+
+// usageBody 转发时旁路解析用量的响应体
+type usageBody struct {
+	io.ReadCloser
+	parser *usage.StreamParser // 流式响应按行解析
+	buf    bytes.Buffer        // 非流式响应缓存
+	limit  int64
+	over   bool // 超过缓存上限，放弃解析
+	gzip   bool
+
+	start  time.Time
+	record usage.Record
+	once   sync.Once
+}
+
+// Read 读取响应体并旁路给解析器
+func (b *usageBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		switch {
+		case b.parser != nil:
+			b.parser.Write(p[:n])
+		case !b.over && int64(b.buf.Len()+n) <= b.limit:
+			b.buf.Write(p[:n])
+		default:
+			b.over = true
+			b.buf = bytes.Buffer{}
+		}
+	}
+	return n, err
+}
+
+// Close 关闭响应体并记录用量；客户端提前断开时记录已收到的部分
+func (b *usageBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.finish)
+	return err
+}
+
+// finish 解析并记录用量
+func (b *usageBody) finish() {
+	var (
+		u  usage.Usage
+		ok bool
+	)
+	switch {
+	case b.parser != nil:
+		u, ok = b.parser.Usage()
+	case b.over || b.buf.Len() == 0:
+		return
+	case b.gzip:
+		r, err := gzip.NewReader(&b.buf)
+		if err != nil {
+			return
+		}
+		data, err := io.ReadAll(io.LimitReader(r, b.limit))
+		if err != nil {
+			return
+		}
+		u, ok = usage.Parse(data)
+	default:
+		u, ok = usage.Parse(b.buf.Bytes())
+	}
+	if !ok {
+		return
+	}
+	if u.Model == "" {
+		u.Model = modelFromPath(b.record.Path)
+	}
+
+	b.record.Time = time.Now()
+	b.record.Latency = float64(time.Since(b.start).Microseconds()) / 1000
+	b.record.Usage = u
+	usage.GlobalRecorder.Record(b.record)
+}
+
+// modelFromPath 从 Gemini 风格的路径（/v1beta/models/gemini-pro:generateContent）中提取模型名
+func modelFromPath(path string) string {
+	_, rest, ok := strings.Cut(path, "/models/")
+	if !ok {
+		return ""
+	}
+	model, _, _ := strings.Cut(rest, ":")
+	model, _, _ = strings.Cut(model, "/")
+	return model
+}
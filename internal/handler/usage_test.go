@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"sub-router/internal/config"
+	"sub-router/pkg/usage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// captureSink 收集用量记录
+type captureSink struct {
+	mu      sync.Mutex
+	records []usage.Record
+}
+
+func (s *captureSink) Record(rec usage.Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, rec)
+}
+
+func TestProxyHandlerUsage(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/stream") {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"model\":\"claude-sonnet-4\",\"usage\":{\"input_tokens\":20,\"output_tokens\":1}}}\n\n")
+			w.(http.Flusher).Flush()
+			io.WriteString(w, "event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":8}}\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"model":"gpt-4o","usage":{"prompt_tokens":5,"completion_tokens":3,"total_tokens":8}}`)
+	}))
+	defer backend.Close()
+
+	config.Set(&config.Config{
+		Usage: config.UsageConfig{Enabled: true, MaxBodySize: 1 << 20},
+		APIMappings: map[string]config.APIMapping{
+			"llm": {URL: backend.URL},
+		},
+	})
+	sink := &captureSink{}
+	usage.GlobalRecorder.Add(sink)
+	defer usage.GlobalRecorder.Remove(sink)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("client", "team-a")
+	})
+	r.Any("/:service/*path", ProxyHandler)
+
+	for _, path := range []string{"/llm/v1/chat/completions", "/llm/v1/messages/stream"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", path, strings.NewReader("{}")))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+	}
+
+	if len(sink.records) != 2 {
+		t.Fatalf("Expected 2 usage records, got %+v", sink.records)
+	}
+	if rec := sink.records[0]; rec.Client != "team-a" || rec.Service != "llm" || rec.Model != "gpt-4o" || rec.TotalTokens != 8 || rec.Stream {
+		t.Errorf("Unexpected JSON usage record: %+v", rec)
+	}
+	if rec := sink.records[1]; rec.Model != "claude-sonnet-4" || rec.PromptTokens != 20 || rec.CompletionTokens != 8 || !rec.Stream {
+		t.Errorf("Unexpected stream usage record: %+v", rec)
+	}
+}
+
+func TestProxyHandlerUsageForQuotas(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"model":"gpt-4o","usage":{"prompt_tokens":5,"completion_tokens":3,"total_tokens":8}}`)
+	}))
+	defer backend.Close()
+
+	// 未启用用量统计时，token 和费用配额仍需要用量记录
+	config.Set(&config.Config{
+		Quotas: config.QuotaConfig{Enabled: true},
+		APIMappings: map[string]config.APIMapping{
+			"llm": {URL: backend.URL},
+		},
+	})
+	sink := &captureSink{}
+	usage.GlobalRecorder.Add(sink)
+	defer usage.GlobalRecorder.Remove(sink)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/:service/*path", ProxyHandler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/llm/v1/chat/completions", strings.NewReader("{}")))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if len(sink.records) != 1 || sink.records[0].TotalTokens != 8 {
+		t.Fatalf("Expected 1 usage record with 8 tokens, got %+v", sink.records)
+	}
+
+	// 两者都未启用时不解析用量
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"llm": {URL: backend.URL},
+		},
+	})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/llm/v1/chat/completions", strings.NewReader("{}")))
+	if len(sink.records) != 1 {
+		t.Errorf("Expected no usage record when usage and quotas are disabled, got %+v", sink.records)
+	}
+}
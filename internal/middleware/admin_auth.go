@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"sub-router/internal/config"
+	"sub-router/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth 管理接口认证中间件，校验 Authorization: Bearer 令牌
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := config.Get().Admin.Token
+		auth := c.GetHeader("Authorization")
+		provided, ok := strings.CutPrefix(auth, "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			c.AbortWithStatusJSON(401,
+				errors.New(errors.ErrorTypeAuth, "invalid admin token", 401).
+					ToResponse(c.GetString("trace_id")))
+			return
+		}
+		c.Next()
+	}
+}
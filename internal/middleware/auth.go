@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+
+	"sub-router/internal/config"
+	"sub-router/pkg/errors"
+	"sub-router/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ClientKey 认证通过后客户端名称在上下文中的键
+	ClientKey = "client"
+
+	// ClientServicesKey 认证通过后客户端允许访问的服务在上下文中的键
+	ClientServicesKey = "client_services"
+)
+
+// ClientAllows 判断认证后的客户端能否访问服务，未启用认证时总是允许
+func ClientAllows(c *gin.Context, service string) bool {
+	services, _ := c.Get(ClientServicesKey)
+	allowed, _ := services.([]string)
+	return config.AllowsService(allowed, service)
+}
+
+// Auth 客户端认证中间件，支持 Basic 认证和 API 密钥（Authorization: Bearer 或 X-API-Key），
+// 并按客户端限制可访问的服务（匹配路由表时为规则声明的服务）。认证使用的请求头不会转发给上游
+func Auth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		security := config.Get().Security
+		if !security.BasicAuth.Enabled && !security.APIKeys.Enabled {
+			c.Next()
+			return
+		}
+
+		client, services, header, ok := authenticate(c, security)
+		if header == "" {
+			metrics.AuthFailures.WithLabelValues("missing").Inc()
+			abortAuth(c, security, 401, "authentication required")
+			return
+		}
+		if !ok {
+			metrics.AuthFailures.WithLabelValues("invalid").Inc()
+			abortAuth(c, security, 401, "invalid credentials")
+			return
+		}
+
+		service := c.Param("service")
+		authService := service
+		if declared := c.GetString(AuthServiceKey); declared != "" {
+			authService = declared
+		}
+		if !config.AllowsService(services, authService) {
+			metrics.AuthFailures.WithLabelValues("forbidden").Inc()
+			c.AbortWithStatusJSON(403,
+				errors.New(errors.ErrorTypeAuth, "client "+client+" is not allowed to access service "+authService, 403).
+					ToResponse(c.GetString("trace_id")))
+			return
+		}
+
+		c.Request.Header.Del(header)
+		c.Set(ClientKey, client)
+		c.Set(ClientServicesKey, services)
+		metrics.ClientRequests.WithLabelValues(client, service).Inc()
+		c.Next()
+	}
+}
+
+// authenticate 校验请求凭证，返回客户端名称、允许的服务和凭证所在的请求头；
+// 未携带凭证时 header 为空
+func authenticate(c *gin.Context, security config.SecurityConfig) (client string, services []string, header string, ok bool) {
+	if security.APIKeys.Enabled {
+		if key := c.GetHeader("X-API-Key"); key != "" {
+			client, services, ok = lookupAPIKey(security.APIKeys.Keys, key)
+			return client, services, "X-API-Key", ok
+		}
+		if key, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); found {
+			client, services, ok = lookupAPIKey(security.APIKeys.Keys, key)
+			return client, services, "Authorization", ok
+		}
+	}
+	if security.BasicAuth.Enabled {
+		if username, password, found := c.Request.BasicAuth(); found {
+			for _, cred := range security.BasicAuth.Credentials {
+				if cred.Username == username {
+					return username, cred.Services, "Authorization", verifyPassword(cred.Password, password)
+				}
+			}
+			return username, nil, "Authorization", false
+		}
+	}
+	return "", nil, "", false
+}
+
+// lookupAPIKey 查找 API 密钥对应的客户端
+func lookupAPIKey(keys []config.APIKeyConfig, key string) (string, []string, bool) {
+	sum := sha256.Sum256([]byte(key))
+	digest := hex.EncodeToString(sum[:])
+	for _, k := range keys {
+		switch {
+		case k.Key != "" && subtle.ConstantTimeCompare([]byte(k.Key), []byte(key)) == 1:
+			return k.Name, k.Services, true
+		case k.KeySHA256 != "" && subtle.ConstantTimeCompare([]byte(strings.ToLower(k.KeySHA256)), []byte(digest)) == 1:
+			return k.Name, k.Services, true
+		}
+	}
+	return "", nil, false
+}
+
+// abortAuth 返回认证失败响应
+func abortAuth(c *gin.Context, security config.SecurityConfig, code int, message string) {
+	if security.BasicAuth.Enabled {
+		c.Header("WWW-Authenticate", `Basic realm="sub-router"`)
+	} else {
+		c.Header("WWW-Authenticate", `Bearer realm="sub-router"`)
+	}
+	c.AbortWithStatusJSON(code,
+		errors.New(errors.ErrorTypeAuth, message, code).ToResponse(c.GetString("trace_id")))
+}
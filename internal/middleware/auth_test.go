@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sub-router/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuth(t *testing.T) {
+	bcryptHash, _ := bcrypt.GenerateFromPassword([]byte("alice-pass"), bcrypt.MinCost)
+	salt := []byte("0123456789abcdef")
+	argonHash := fmt.Sprintf("$argon2id$v=19$m=1024,t=1,p=1$%s$%s",
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(argon2.IDKey([]byte("bob-pass"), salt, 1, 1024, 1, 32)))
+	digest := sha256.Sum256([]byte("sk-team-b"))
+
+	cfg := &config.Config{
+		Server: config.ServerConfig{Port: 8080},
+		Security: config.SecurityConfig{
+			BasicAuth: config.BasicAuthConfig{
+				Enabled: true,
+				Credentials: []config.BasicAuthCredentials{
+					{Username: "alice", Password: string(bcryptHash)},
+					{Username: "bob", Password: argonHash, Services: []string{"claude"}},
+				},
+			},
+			APIKeys: config.APIKeysConfig{
+				Enabled: true,
+				Keys: []config.APIKeyConfig{
+					{Name: "team-a", Key: "sk-team-a", Services: []string{"openai"}},
+					{Name: "team-b", KeySHA256: hex.EncodeToString(digest[:])},
+				},
+			},
+		},
+	}
+	if err := config.ValidateConfig(cfg); err != nil {
+		t.Fatalf("Invalid test config: %v", err)
+	}
+	config.Set(cfg)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/:service/*path", Auth(), func(c *gin.Context) {
+		// 认证头不应转发给上游
+		if c.GetHeader("Authorization") != "" || c.GetHeader("X-API-Key") != "" {
+			t.Error("Credential header should be removed")
+		}
+		c.String(200, c.GetString(ClientKey))
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		header func(*http.Request)
+		status int
+		client string
+	}{
+		{"missing", "/openai/v1", func(*http.Request) {}, 401, ""},
+		{"bcrypt", "/openai/v1", func(r *http.Request) { r.SetBasicAuth("alice", "alice-pass") }, 200, "alice"},
+		{"wrong password", "/openai/v1", func(r *http.Request) { r.SetBasicAuth("alice", "wrong") }, 401, ""},
+		{"argon2id", "/claude/v1", func(r *http.Request) { r.SetBasicAuth("bob", "bob-pass") }, 200, "bob"},
+		{"basic forbidden", "/openai/v1", func(r *http.Request) { r.SetBasicAuth("bob", "bob-pass") }, 403, ""},
+		{"bearer", "/openai/v1", func(r *http.Request) { r.Header.Set("Authorization", "Bearer sk-team-a") }, 200, "team-a"},
+		{"bearer forbidden", "/claude/v1", func(r *http.Request) { r.Header.Set("Authorization", "Bearer sk-team-a") }, 403, ""},
+		{"x-api-key hashed", "/claude/v1", func(r *http.Request) { r.Header.Set("X-API-Key", "sk-team-b") }, 200, "team-b"},
+		{"unknown key", "/openai/v1", func(r *http.Request) { r.Header.Set("X-API-Key", "sk-unknown") }, 401, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", tt.path, nil)
+			tt.header(req)
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.status == 200 && w.Body.String() != tt.client {
+				t.Errorf("Expected client %s, got %s", tt.client, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestValidateAuthConfig(t *testing.T) {
+	cfg := &config.Config{
+		Server: config.ServerConfig{Port: 8080},
+		Security: config.SecurityConfig{
+			BasicAuth: config.BasicAuthConfig{
+				Enabled:     true,
+				Credentials: []config.BasicAuthCredentials{{Username: "alice", Password: "plaintext"}},
+			},
+		},
+	}
+	if err := config.ValidateConfig(cfg); err == nil {
+		t.Error("Expected plaintext password to be rejected")
+	}
+	// 参数无效的 argon2id 哈希在校验配置时拒绝，校验密码时不匹配
+	salt := base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	key := base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	for _, hash := range []string{
+		"$argon2id$v=19$m=1024,t=1,p=0$" + salt + "$" + key,
+		"$argon2id$v=19$m=1024,t=0,p=1$" + salt + "$" + key,
+		"$argon2id$v=19$m=4194304,t=1,p=1$" + salt + "$" + key,
+		"$argon2id$v=19$m=1024,t=1,p=1$$" + key,
+		"$argon2id$v=19$m=1024,t=1,p=1$" + salt + "$",
+	} {
+		cfg.Security.BasicAuth.Credentials[0].Password = hash
+		if err := config.ValidateConfig(cfg); err == nil {
+			t.Errorf("Expected %s to be rejected", hash)
+		}
+		if verifyPassword(hash, "secret") {
+			t.Errorf("Expected %s not to match", hash)
+		}
+	}
+}
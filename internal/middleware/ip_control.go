@@ -13,7 +13,9 @@ import (
 // IPControl IP 控制中间件
 func IPControl() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !config.GlobalConfig.Security.IPControl.Enabled {
+		// 每个请求使用同一份配置快照
+		ipControl := config.Get().Security.IPControl
+		if !ipControl.Enabled {
 			c.Next()
 			return
 		}
@@ -28,7 +30,7 @@ func IPControl() gin.HandlerFunc {
 		}
 
 		// 检查黑名单
-		for _, blackIP := range config.GlobalConfig.Security.IPControl.Blacklist {
+		for _, blackIP := range ipControl.Blacklist {
 			if isIPInRange(ip, blackIP) {
 				c.AbortWithStatusJSON(403,
 					errors.New(errors.ErrorTypePermission, "IP blocked", 403).
@@ -38,9 +40,9 @@ func IPControl() gin.HandlerFunc {
 		}
 
 		// 检查白名单
-		if len(config.GlobalConfig.Security.IPControl.Whitelist) > 0 {
+		if len(ipControl.Whitelist) > 0 {
 			allowed := false
-			for _, whiteIP := range config.GlobalConfig.Security.IPControl.Whitelist {
+			for _, whiteIP := range ipControl.Whitelist {
 				if isIPInRange(ip, whiteIP) {
 					allowed = true
 					break
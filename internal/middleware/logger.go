@@ -36,6 +36,7 @@ func Logger(logger *zap.Logger) gin.HandlerFunc {
 			zap.String("ip", c.ClientIP()),
 			zap.String("user-agent", c.Request.UserAgent()),
 			zap.String("trace_id", traceID),
+			zap.String("client", c.GetString(ClientKey)),
 		)
 	}
 }
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"strings"
+	"sync"
+
+	"sub-router/internal/config"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxVerifiedCache 密码校验缓存上限，超过后整体清空
+const maxVerifiedCache = 1024
+
+var (
+	// verified 缓存校验通过的哈希和密码组合，避免每个请求都执行慢哈希
+	verified      sync.Map
+	verifiedCount int
+	verifiedMu    sync.Mutex
+)
+
+// verifyPassword 校验密码与 bcrypt 或 argon2id 哈希是否匹配
+func verifyPassword(hash, password string) bool {
+	sum := sha256.Sum256([]byte(hash + "\x00" + password))
+	key := string(sum[:])
+	if _, ok := verified.Load(key); ok {
+		return true
+	}
+
+	var ok bool
+	if strings.HasPrefix(hash, "$argon2id$") {
+		ok = verifyArgon2id(hash, password)
+	} else {
+		ok = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	}
+	if ok {
+		verifiedMu.Lock()
+		if verifiedCount >= maxVerifiedCache {
+			verified.Range(func(k, _ interface{}) bool {
+				verified.Delete(k)
+				return true
+			})
+			verifiedCount = 0
+		}
+		verified.Store(key, struct{}{})
+		verifiedCount++
+		verifiedMu.Unlock()
+	}
+	return ok
+}
+
+// verifyArgon2id 校验 PHC 格式的 argon2id 哈希，参数无效的哈希一律不匹配
+func verifyArgon2id(encoded, password string) bool {
+	h, err := config.ParseArgon2id(encoded)
+	if err != nil {
+		return false
+	}
+	computed := argon2.IDKey([]byte(password), h.Salt, h.Time, h.Memory, h.Threads, uint32(len(h.Key)))
+	return subtle.ConstantTimeCompare(h.Key, computed) == 1
+}
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"sub-router/internal/config"
+	"sub-router/pkg/errors"
+	"sub-router/pkg/metrics"
+	"sub-router/pkg/quota"
+	"sub-router/pkg/usage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AnonymousClient 未通过认证识别的客户端名称
+const AnonymousClient = "anonymous"
+
+// quotaItems 配额项的输出顺序
+var quotaItems = []string{"requests", "tokens", "cost"}
+
+// ClientName 获取认证后的客户端名称，未认证时返回 anonymous
+func ClientName(c *gin.Context) string {
+	if client := c.GetString(ClientKey); client != "" {
+		return client
+	}
+	return AnonymousClient
+}
+
+// Quota 配额中间件，按客户端的每日、每月请求数、token 数和估算费用限制请求。
+//
+// 请求数在放行时计入，token 数和费用在响应完成后由 QuotaRecorder 计入，
+// 因此并发请求可能略微超出 token 和费用配额。需要放在 Auth 之后
+func Quota(store *quota.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := config.Get().Quotas
+		if !cfg.Enabled {
+			c.Next()
+			return
+		}
+		client := ClientName(c)
+		limits, ok := cfg.LimitFor(client)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		now := time.Now()
+		status, period, ok := store.Reserve(client, limits, now)
+		if !ok {
+			used, limit := status.Daily, limits.Daily
+			if period == "monthly" {
+				used, limit = status.Monthly, limits.Monthly
+			}
+			reset := quota.NextReset(period, now)
+			metrics.QuotaRejections.WithLabelValues(client, period).Inc()
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(reset.Sub(now))))
+			c.Header("X-Quota-Reset", reset.Format(time.RFC3339))
+			c.AbortWithStatusJSON(429,
+				errors.New(errors.ErrorTypeRateLimit,
+					fmt.Sprintf("%s quota exhausted: %s", period, strings.Join(used.Exhausted(limit), ", ")), 429).
+					ToResponse(c.GetString("trace_id")))
+			return
+		}
+
+		// 接近上限时返回警告，便于客户端提前降级
+		if warnings := quotaWarnings(status, limits, cfg.SoftLimit); len(warnings) > 0 {
+			c.Header("X-Quota-Warning", strings.Join(warnings, ", "))
+		}
+		c.Next()
+	}
+}
+
+// quotaWarnings 列出用量达到软上限的配额项，如 "daily tokens 85%"
+func quotaWarnings(status quota.Status, limits quota.Limits, soft float64) []string {
+	if soft <= 0 {
+		return nil
+	}
+	var warnings []string
+	for _, p := range []struct {
+		name  string
+		used  quota.Amount
+		limit quota.Amount
+	}{
+		{"daily", status.Daily, limits.Daily},
+		{"monthly", status.Monthly, limits.Monthly},
+	} {
+		ratios := p.used.Ratios(p.limit)
+		for _, item := range quotaItems {
+			if ratio, ok := ratios[item]; ok && ratio >= soft {
+				warnings = append(warnings, fmt.Sprintf("%s %s %d%%", p.name, item, int(ratio*100)))
+			}
+		}
+	}
+	return warnings
+}
+
+// QuotaRecorder 将响应中的 token 用量和估算费用计入配额
+type QuotaRecorder struct {
+	Store *quota.Store
+}
+
+// Record 实现 usage.Sink
+func (r QuotaRecorder) Record(rec usage.Record) {
+	cfg := config.Get().Quotas
+	if !cfg.Enabled {
+		return
+	}
+	if _, ok := cfg.LimitFor(rec.Client); !ok {
+		return
+	}
+	cost := cfg.Cost(rec.Model, rec.PromptTokens, rec.CompletionTokens, rec.CachedTokens)
+	r.Store.Add(rec.Client, quota.Amount{Tokens: rec.TotalTokens, Cost: cost}, rec.Time)
+}
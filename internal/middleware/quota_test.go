@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"sub-router/internal/config"
+	"sub-router/pkg/quota"
+	"sub-router/pkg/usage"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestQuota(t *testing.T) {
+	config.Set(&config.Config{
+		Quotas: config.QuotaConfig{
+			Enabled:   true,
+			SoftLimit: 0.5,
+			Prices: []config.ModelPrice{
+				{Model: "gpt-4o*", Prompt: 2.5, Completion: 10, Cached: 1.25},
+			},
+			Limits: []config.QuotaLimit{
+				{Client: "team-a", Daily: quota.Amount{Requests: 4}, Monthly: quota.Amount{Cost: 0.01}},
+			},
+		},
+	})
+	store, _ := quota.Open("")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/:service/*path", func(c *gin.Context) {
+		if client := c.GetHeader("X-Client"); client != "" {
+			c.Set(ClientKey, client)
+		}
+	}, Quota(store), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	send := func(client string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/openai/v1/chat/completions", nil)
+		req.Header.Set("X-Client", client)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// 第 1 次请求低于软上限，第 2 次开始警告
+	if w := send("team-a"); w.Code != http.StatusOK || w.Header().Get("X-Quota-Warning") != "" {
+		t.Fatalf("Unexpected first response: %d %q", w.Code, w.Header().Get("X-Quota-Warning"))
+	}
+	if w := send("team-a"); w.Header().Get("X-Quota-Warning") != "daily requests 50%" {
+		t.Errorf("Expected soft limit warning, got %q", w.Header().Get("X-Quota-Warning"))
+	}
+
+	// 按价格表计入费用：(1000-200)*2.5 + 200*1.25 + 500*10 = 7250 / 1e6
+	QuotaRecorder{Store: store}.Record(usage.Record{
+		Time:   time.Now(),
+		Client: "team-a",
+		Usage:  usage.Usage{Model: "gpt-4o-mini", PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500, CachedTokens: 200},
+	})
+	status := store.Get("team-a", time.Now())
+	if status.Daily.Tokens != 1500 || math.Abs(status.Monthly.Cost-0.00725) > 1e-9 {
+		t.Errorf("Unexpected usage: %+v", status)
+	}
+	QuotaRecorder{Store: store}.Record(usage.Record{
+		Time:   time.Now(),
+		Client: "team-a",
+		Usage:  usage.Usage{Model: "gpt-4o", PromptTokens: 2000, TotalTokens: 2000},
+	})
+
+	// 每月费用耗尽
+	w := send("team-a")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "RATE_LIMIT_ERROR") || !strings.Contains(w.Body.String(), "monthly quota exhausted: cost") {
+		t.Errorf("Unexpected error body: %s", w.Body.String())
+	}
+	if w.Header().Get("Retry-After") == "" || w.Header().Get("X-Quota-Reset") == "" {
+		t.Error("Expected Retry-After and X-Quota-Reset headers")
+	}
+
+	// 没有匹配规则的客户端不受限制
+	for i := 0; i < 5; i++ {
+		if w := send("team-b"); w.Code != http.StatusOK {
+			t.Fatalf("Expected unlimited client to pass, got %d", w.Code)
+		}
+	}
+}
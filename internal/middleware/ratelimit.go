@@ -1,21 +1,118 @@
 package middleware
 
 import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"sub-router/internal/config"
 	"sub-router/pkg/errors"
+	"sub-router/pkg/metrics"
+	"sub-router/pkg/ratelimit"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/time/rate"
 )
 
-// RateLimit 限流中间件
-func RateLimit(r rate.Limit, b int) gin.HandlerFunc {
-	limiter := rate.NewLimiter(r, b)
+// RateLimit 分键限流中间件，按配置的规则对 IP、客户端、服务或其组合分别限流，
+// 规则随配置热加载更新。需要放在 Auth 之后才能按客户端限流
+func RateLimit() gin.HandlerFunc {
+	return RateLimitWithStore(NewRateLimitStore(config.Get().RateLimit))
+}
+
+// NewRateLimitStore 根据配置创建限流存储
+func NewRateLimitStore(cfg config.RateLimitConfig) ratelimit.Store {
+	if cfg.Store.Type == config.RateLimitStoreRedis {
+		return ratelimit.NewRedisStore(ratelimit.RedisOptions{
+			Addr:     cfg.Store.Addr,
+			Password: cfg.Store.Password,
+			DB:       cfg.Store.DB,
+			Prefix:   cfg.Store.Prefix,
+			Timeout:  cfg.Store.Timeout,
+			PoolSize: cfg.Store.PoolSize,
+		})
+	}
+	return ratelimit.NewMemoryStore(cfg.MaxKeys)
+}
+
+// RateLimitWithStore 使用指定存储的分键限流中间件
+func RateLimitWithStore(store ratelimit.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !limiter.Allow() {
-			c.AbortWithError(429,
-				errors.New(errors.ErrorTypeRateLimit, "too many requests", 429))
-			return
+		service := c.Param("service")
+
+		// 所有匹配的规则都需要通过，响应头报告剩余请求最少的规则
+		var tightest *ratelimit.Result
+		cfg := config.Get()
+		for _, rule := range cfg.RateLimitRules() {
+			if !config.AllowsService(rule.Services, service) {
+				continue
+			}
+			value := rateLimitKey(c, rule, service)
+			rps, burst := rule.Limit(value)
+			result, err := store.Allow(c.Request.Context(), rule.Name+"|"+value, ratelimit.Limit{Rate: rps, Burst: burst})
+			if err != nil {
+				// 存储不可用时按配置放行或拒绝
+				metrics.RateLimitStoreErrors.Inc()
+				if cfg.RateLimit.Store.FailureMode == config.RateLimitFailClosed {
+					c.AbortWithStatusJSON(503,
+						errors.Wrap(err, errors.ErrorTypeRateLimit, "rate limit store unavailable", 503).
+							ToResponse(c.GetString("trace_id")))
+					return
+				}
+				continue
+			}
+
+			if !result.Allowed {
+				metrics.RateLimitRejections.WithLabelValues(rule.Class()).Inc()
+				setRateLimitHeaders(c, result)
+				c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				c.AbortWithStatusJSON(429,
+					errors.New(errors.ErrorTypeRateLimit, "too many requests", 429).
+						ToResponse(c.GetString("trace_id")))
+				return
+			}
+			if tightest == nil || result.Remaining < tightest.Remaining {
+				tightest = &result
+			}
+		}
+		if tightest != nil {
+			setRateLimitHeaders(c, *tightest)
 		}
 		c.Next()
 	}
 }
+
+// rateLimitKey 计算请求在规则下的键值，组合键用 + 连接
+func rateLimitKey(c *gin.Context, rule config.RateLimitRule, service string) string {
+	parts := rule.KeyParts()
+	values := make([]string, 0, len(parts))
+	for _, part := range parts {
+		switch part {
+		case config.RateLimitKeyIP:
+			values = append(values, c.ClientIP())
+		case config.RateLimitKeyClient:
+			client := c.GetString(ClientKey)
+			if client == "" {
+				client = "ip:" + c.ClientIP()
+			}
+			values = append(values, client)
+		case config.RateLimitKeyService:
+			values = append(values, service)
+		default:
+			values = append(values, config.RateLimitKeyGlobal)
+		}
+	}
+	return strings.Join(values, "+")
+}
+
+// setRateLimitHeaders 设置 RateLimit-* 响应头
+func setRateLimitHeaders(c *gin.Context, result ratelimit.Result) {
+	c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+}
+
+// ceilSeconds 向上取整到秒
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
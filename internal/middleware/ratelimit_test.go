@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"sub-router/internal/config"
+	"sub-router/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimit(t *testing.T) {
+	config.Set(&config.Config{
+		RateLimit: config.RateLimitConfig{
+			Rules: []config.RateLimitRule{
+				{
+					Name:              "per-client-service",
+					Key:               "client+service",
+					RequestsPerSecond: 0.001,
+					Burst:             2,
+					Overrides: []config.RateLimitOverride{
+						{Match: "team-a+openai", RequestsPerSecond: 0.001, Burst: 4},
+					},
+				},
+			},
+		},
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/:service/*path", func(c *gin.Context) {
+		if client := c.GetHeader("X-Client"); client != "" {
+			c.Set(ClientKey, client)
+		}
+	}, RateLimit(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	send := func(client, service string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/"+service+"/v1", nil)
+		req.Header.Set("X-Client", client)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// 默认突发容量为 2
+	for i := 0; i < 2; i++ {
+		if w := send("team-b", "openai"); w.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status 200, got %d", i, w.Code)
+		}
+	}
+	w := send("team-b", "openai")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" || w.Header().Get("RateLimit-Remaining") != "0" || w.Header().Get("RateLimit-Limit") != "2" {
+		t.Errorf("Unexpected rate limit headers: %v", w.Header())
+	}
+
+	// 其他服务和客户端不受影响
+	if w := send("team-b", "claude"); w.Code != http.StatusOK {
+		t.Errorf("Expected other service to be allowed, got %d", w.Code)
+	}
+
+	// 按键值覆盖的突发容量
+	for i := 0; i < 4; i++ {
+		if w := send("team-a", "openai"); w.Code != http.StatusOK {
+			t.Fatalf("Override request %d: expected status 200, got %d", i, w.Code)
+		}
+	}
+	if w := send("team-a", "openai"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429 after override burst, got %d", w.Code)
+	}
+}
+
+func TestRateLimitStoreFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := ratelimit.NewRedisStore(ratelimit.RedisOptions{Addr: "127.0.0.1:1", Timeout: 50 * time.Millisecond})
+
+	for mode, status := range map[string]int{
+		config.RateLimitFailOpen:   http.StatusOK,
+		config.RateLimitFailClosed: http.StatusServiceUnavailable,
+	} {
+		config.Set(&config.Config{
+			RateLimit: config.RateLimitConfig{
+				Store: config.RateLimitStoreConfig{Type: config.RateLimitStoreRedis, FailureMode: mode},
+				Rules: []config.RateLimitRule{{Name: "per-ip", Key: "ip", RequestsPerSecond: 1, Burst: 1}},
+			},
+		})
+
+		r := gin.New()
+		r.Any("/:service/*path", RateLimitWithStore(store), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/openai/v1", nil))
+		if w.Code != status {
+			t.Errorf("Failure mode %s: expected status %d, got %d", mode, status, w.Code)
+		}
+	}
+}
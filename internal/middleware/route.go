@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"sub-router/internal/config"
+	"sub-router/pkg/metrics"
+	"sub-router/pkg/router"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// RouteKey 上下文中匹配的路由名称
+	RouteKey = "route"
+
+	// SplitKey 上下文中选中的分流名称
+	SplitKey = "route_split"
+
+	// AuthServiceKey 上下文中用于检查客户端权限的服务，匹配路由表时为规则声明的服务
+	AuthServiceKey = "auth_service"
+)
+
+// compiledRoutes 按配置快照编译的路由表
+type compiledRoutes struct {
+	cfg   *config.Config
+	table *router.Table
+}
+
+// routeTable 当前配置快照的路由表，配置替换后在下一个请求时重新编译
+var routeTable atomic.Pointer[compiledRoutes]
+
+// currentRoutes 获取当前配置的路由表
+func currentRoutes() *router.Table {
+	cfg := config.Get()
+	if cached := routeTable.Load(); cached != nil && cached.cfg == cfg {
+		return cached.table
+	}
+	// 路由表已在配置校验时编译过，这里失败时只按 /:service/*path 处理
+	table, err := router.Compile(cfg.Routes)
+	if err != nil {
+		log.Printf("route table unavailable: %v", err)
+		table, _ = router.Compile(nil)
+	}
+	routeTable.Store(&compiledRoutes{cfg: cfg, table: table})
+	return table
+}
+
+// Route 路由中间件，按路由表选择服务并改写 service、path 参数，后续的认证、限流和代理都使用改写后的服务；
+// 未匹配任何规则时保持 /:service/*path 的解析结果。
+//
+// 同时挂载为 NoRoute 处理器，使不以服务名开头的路径（如 POST /、/v1）也能匹配路由表，
+// 未匹配的请求按第一个路径段选择服务
+func Route() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, path, ok := currentRoutes().Match(c.Request)
+		if !ok {
+			// NoRoute 中没有路由参数；/openai 的 path 参数为空，按 /openai/ 处理
+			if c.Param("service") == "" || c.Param("path") == "" {
+				service, path := splitServicePath(c.Request.URL.Path)
+				if service == "" {
+					c.AbortWithStatus(http.StatusNotFound)
+					return
+				}
+				c.Params = setParam(setParam(c.Params, "service", service), "path", path)
+			}
+			c.Next()
+			return
+		}
+
+		service := route.Service
+		split := route.Split(c.Request, c.ClientIP())
+		if split != nil {
+			service = split.Service
+			c.Set(SplitKey, split.Name)
+		}
+		c.Params = setParam(setParam(c.Params, "service", service), "path", path)
+		c.Set(RouteKey, route.Name)
+		// 按权重分配的分流按规则的主服务认证，只允许访问主服务的客户端也能被分配到其他分流；
+		// 通过请求头强制选择的分流按该分流的服务认证
+		authService := route.Service
+		if forced := route.Override(c.Request); forced != nil {
+			authService = forced.Service
+		}
+		c.Set(AuthServiceKey, authService)
+		if split == nil {
+			c.Next()
+			return
+		}
+
+		// 记录分流的请求结果和耗时（流式响应包含传输时间）
+		start := time.Now()
+		c.Next()
+		metrics.RouteSplitRequests.WithLabelValues(route.Name, split.Name, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.RouteSplitDuration.WithLabelValues(route.Name, split.Name).Observe(time.Since(start).Seconds())
+	}
+}
+
+// splitServicePath 按 /:service/*path 拆分路径，路径为空时 service 为空
+func splitServicePath(p string) (string, string) {
+	service, rest, _ := strings.Cut(strings.TrimPrefix(p, "/"), "/")
+	return service, "/" + rest
+}
+
+// setParam 设置路由参数
+func setParam(params gin.Params, key, value string) gin.Params {
+	for i := range params {
+		if params[i].Key == key {
+			params[i].Value = value
+			return params
+		}
+	}
+	return append(params, gin.Param{Key: key, Value: value})
+}
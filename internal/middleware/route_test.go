@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sub-router/internal/config"
+	"sub-router/pkg/router"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRoute(t *testing.T) {
+	config.Set(&config.Config{
+		Routes: []router.Spec{
+			{Name: "openai-host", Service: "openai", Hosts: []string{"openai.internal"}},
+			{Service: "openai", Hosts: []string{"api.internal"}, PathPrefix: "/oai", StripPrefix: "/oai"},
+		},
+	})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/:service/*path", Route(), func(c *gin.Context) {
+		c.String(http.StatusOK, "%s %s %s", c.Param("service"), c.Param("path"), c.GetString(RouteKey))
+	})
+
+	tests := []struct {
+		host, path, want string
+	}{
+		{"openai.internal", "/v1/chat/completions", "openai /v1/chat/completions openai-host"},
+		{"api.internal", "/oai/v1/models", "openai /v1/models openai"},
+		// 未匹配时保持按第一个路径段解析
+		{"api.internal", "/groq/v1/models", "groq /v1/models "},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Body.String() != tt.want {
+			t.Errorf("%s%s: expected %q, got %q", tt.host, tt.path, tt.want, w.Body.String())
+		}
+	}
+
+	// 配置替换后重新编译路由表
+	config.Set(&config.Config{})
+	req := httptest.NewRequest("GET", "/v1/models", nil)
+	req.Host = "openai.internal"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Body.String() != "v1 /models " {
+		t.Errorf("Expected route table to be reloaded, got %q", w.Body.String())
+	}
+}
+
+func TestRouteWithoutServiceSegment(t *testing.T) {
+	config.Set(&config.Config{
+		Routes: []router.Spec{{Name: "openai-host", Service: "openai", Hosts: []string{"openai.internal"}}},
+	})
+	defer config.Set(&config.Config{})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.RedirectTrailingSlash = false
+	r.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, "local")
+	})
+	proxy := func(c *gin.Context) {
+		c.String(http.StatusOK, "%s %s %s", c.Param("service"), c.Param("path"), c.GetString(RouteKey))
+	}
+	r.Any("/:service/*path", Route(), proxy)
+	r.NoRoute(Route(), proxy)
+
+	tests := []struct {
+		method, host, path string
+		code               int
+		want               string
+	}{
+		// 路径不以服务名开头时仍按路由表匹配
+		{"POST", "openai.internal", "/", http.StatusOK, "openai / openai-host"},
+		{"POST", "openai.internal", "/v1", http.StatusOK, "openai /v1 openai-host"},
+		// 未匹配时按第一个路径段选择服务，不再重定向
+		{"GET", "api.internal", "/groq", http.StatusOK, "groq / "},
+		{"POST", "api.internal", "/", http.StatusNotFound, ""},
+		// 本地路由优先
+		{"GET", "openai.internal", "/", http.StatusOK, "local"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.code || w.Body.String() != tt.want {
+			t.Errorf("%s %s%s: expected %d %q, got %d %q", tt.method, tt.host, tt.path, tt.code, tt.want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRouteSplit(t *testing.T) {
+	config.Set(&config.Config{
+		Routes: []router.Spec{{
+			Name:           "chat",
+			PathPrefix:     "/chat",
+			Splits:         []router.SplitSpec{{Service: "openai", Weight: 1}, {Name: "canary", Service: "openai-next"}},
+			Sticky:         "client",
+			OverrideHeader: "X-Split",
+		}},
+	})
+	defer config.Set(&config.Config{})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/:service/*path", Route(), func(c *gin.Context) {
+		c.String(http.StatusOK, "%s %s", c.Param("service"), c.GetString(SplitKey))
+	})
+
+	req := httptest.NewRequest("POST", "/chat/v1/completions", nil)
+	req.Header.Set("X-Api-Key", "key-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Body.String() != "openai openai" {
+		t.Errorf("Expected weighted split, got %q", w.Body.String())
+	}
+
+	req.Header.Set("X-Split", "canary")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Body.String() != "openai-next canary" {
+		t.Errorf("Expected forced split, got %q", w.Body.String())
+	}
+}
+
+func TestRouteSplitAuth(t *testing.T) {
+	config.Set(&config.Config{
+		Routes: []router.Spec{{
+			Name:           "chat",
+			PathPrefix:     "/chat",
+			Splits:         []router.SplitSpec{{Service: "openai"}, {Name: "canary", Service: "openai-next", Weight: 1}},
+			OverrideHeader: "X-Split",
+		}},
+		Security: config.SecurityConfig{
+			APIKeys: config.APIKeysConfig{
+				Enabled: true,
+				Keys: []config.APIKeyConfig{
+					{Name: "team-a", Key: "sk-team-a", Services: []string{"openai"}},
+					{Name: "team-b", Key: "sk-team-b", Services: []string{"openai-next"}},
+				},
+			},
+		},
+	})
+	defer config.Set(&config.Config{})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/:service/*path", Route(), Auth(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.Param("service"))
+	})
+
+	tests := []struct {
+		key, split string
+		status     int
+		want       string
+	}{
+		// 只允许访问主服务的客户端也可以按权重被分配到灰度分流
+		{"sk-team-a", "", http.StatusOK, "openai-next"},
+		// 但不能通过请求头强制选择没有权限的分流
+		{"sk-team-a", "canary", http.StatusForbidden, ""},
+		// 没有主服务权限的客户端只能强制选择有权限的分流
+		{"sk-team-b", "", http.StatusForbidden, ""},
+		{"sk-team-b", "canary", http.StatusOK, "openai-next"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/chat/v1/completions", nil)
+		req.Header.Set("X-API-Key", tt.key)
+		if tt.split != "" {
+			req.Header.Set("X-Split", tt.split)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.status || (tt.status == http.StatusOK && w.Body.String() != tt.want) {
+			t.Errorf("%s %s: expected %d %q, got %d %q", tt.key, tt.split, tt.status, tt.want, w.Code, w.Body.String())
+		}
+	}
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	stderrors "errors"
 	"net/http"
 	"time"
 
@@ -10,26 +11,26 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// Timeout 超时中间件
+// Timeout 超时中间件，为请求上下文设置截止时间。
+//
+// 处理器在当前协程中执行，需要自行响应上下文取消；超时后如果处理器尚未写入任何响应，
+// 返回 504，已经开始写入的响应保持不变。timeout 为 0 时不限制
 func Timeout(timeout time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
 		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
 		defer cancel()
-
 		c.Request = c.Request.WithContext(ctx)
 
-		done := make(chan struct{})
-		go func() {
-			c.Next()
-			done <- struct{}{}
-		}()
+		c.Next()
 
-		select {
-		case <-done:
-			return
-		case <-ctx.Done():
-			c.AbortWithError(http.StatusGatewayTimeout,
-				errors.New(errors.ErrorTypeInternal, "request timeout", http.StatusGatewayTimeout))
+		if stderrors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout,
+				errors.New(errors.ErrorTypeTimeout, "request timeout", http.StatusGatewayTimeout).
+					ToResponse(c.GetString("trace_id")))
 		}
 	}
 }
@@ -10,20 +10,21 @@ import (
 // Tracing 请求追踪中间件
 func Tracing() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !config.GlobalConfig.Tracing.Enabled {
+		tracing := config.Get().Tracing
+		if !tracing.Enabled {
 			c.Next()
 			return
 		}
 
 		// 获取或生成追踪 ID
-		traceID := c.GetHeader(config.GlobalConfig.Tracing.HeaderName)
+		traceID := c.GetHeader(tracing.HeaderName)
 		if traceID == "" {
 			traceID = uuid.New().String()
 		}
 
 		// 设置追踪 ID
 		c.Set("trace_id", traceID)
-		c.Header(config.GlobalConfig.Tracing.HeaderName, traceID)
+		c.Header(tracing.HeaderName, traceID)
 
 		c.Next()
 	}
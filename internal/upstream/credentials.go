@@ -0,0 +1,263 @@
+package upstream
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sub-router/internal/config"
+	"sub-router/pkg/metrics"
+)
+
+// ClientCredentialHeaders 配置上游凭证后总是移除的客户端凭证头
+var ClientCredentialHeaders = []string{"Authorization", "X-Api-Key", "X-Goog-Api-Key", "Api-Key"}
+
+// ClientCredentialQuery 可能携带客户端凭证的查询参数
+var ClientCredentialQuery = []string{"key", "api_key", "access_token"}
+
+// StripClientCredentials 移除请求中所有客户端凭证头和查询参数，用于转发到其他服务的请求副本
+func StripClientCredentials(req *http.Request) {
+	for _, header := range ClientCredentialHeaders {
+		req.Header.Del(header)
+	}
+	if req.URL.RawQuery == "" {
+		return
+	}
+	query := req.URL.Query()
+	for _, name := range ClientCredentialQuery {
+		query.Del(name)
+	}
+	req.URL.RawQuery = query.Encode()
+}
+
+// remainingHeaders 上游返回的剩余请求配额响应头
+var remainingHeaders = []string{
+	"X-Ratelimit-Remaining-Requests",         // OpenAI
+	"Anthropic-Ratelimit-Requests-Remaining", // Anthropic
+	"X-Ratelimit-Remaining",
+}
+
+// resetHeaders 上游返回的配额重置时间响应头
+var resetHeaders = []string{
+	"X-Ratelimit-Reset-Requests",         // OpenAI，如 "1s"、"6m0s"
+	"Anthropic-Ratelimit-Requests-Reset", // Anthropic，RFC 3339 时间
+}
+
+// defaultKeyCooldown 密钥被限流且无法从响应中得知恢复时间时的冷却时长
+const defaultKeyCooldown = 30 * time.Second
+
+// Key 密钥池中的上游密钥
+type Key struct {
+	Name  string
+	value string
+
+	// 剩余配额和冷却截止时间，由上游响应更新
+	remaining int64 // -1 表示未知
+	cooldown  time.Time
+}
+
+// KeyPool 上游密钥池
+type KeyPool struct {
+	cfg    config.CredentialConfig
+	header string
+	format string
+	query  string
+	strip  []string
+
+	service string
+	keys    []*Key
+	next    int
+	mu      sync.Mutex
+}
+
+// NewKeyPool 根据凭证配置创建密钥池
+func NewKeyPool(service string, cfg config.CredentialConfig) (*KeyPool, error) {
+	p := &KeyPool{cfg: cfg, service: service}
+	p.header, p.format, p.query = cfg.Injection()
+	p.strip = append(append([]string{}, ClientCredentialHeaders...), cfg.Strip...)
+	if p.header != "" {
+		p.strip = append(p.strip, p.header)
+	}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload 重新读取密钥（环境变量或文件），内容未变化的密钥保留配额状态
+func (p *KeyPool) Reload() error {
+	keys := make([]*Key, 0, len(p.cfg.Keys))
+	for i, k := range p.cfg.Keys {
+		value, err := k.Resolve()
+		if err != nil {
+			return fmt.Errorf("service %s key %d: %w", p.service, i, err)
+		}
+		name := k.Name
+		if name == "" {
+			name = "key-" + strconv.Itoa(i)
+		}
+		keys = append(keys, &Key{Name: name, value: value, remaining: -1})
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, key := range keys {
+		for _, old := range p.keys {
+			if old.Name == key.Name && old.value == key.value {
+				key.remaining, key.cooldown = old.remaining, old.cooldown
+			}
+		}
+	}
+	p.keys = keys
+	p.next = 0
+	return nil
+}
+
+// Next 选择下一个密钥，跳过冷却中的密钥；全部冷却时选择最早恢复的密钥
+func (p *KeyPool) Next() *Key {
+	return p.pick(true)
+}
+
+// Peek 获取下一个会被选中的密钥但不推进轮询位置，用于健康检查等非业务请求
+func (p *KeyPool) Peek() *Key {
+	return p.pick(false)
+}
+
+// pick 选择密钥，advance 为 false 时不推进轮询位置
+func (p *KeyPool) pick(advance bool) *Key {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.keys) == 0 {
+		return nil
+	}
+	now := time.Now()
+
+	if p.cfg.Strategy == config.KeyStrategyQuota {
+		var best *Key
+		for _, key := range p.keys {
+			if key.cooldown.After(now) {
+				continue
+			}
+			if best == nil || remainingOf(key) > remainingOf(best) {
+				best = key
+			}
+		}
+		if best != nil {
+			return best
+		}
+	} else {
+		for i := 0; i < len(p.keys); i++ {
+			key := p.keys[(p.next+i)%len(p.keys)]
+			if !key.cooldown.After(now) {
+				if advance {
+					p.next = (p.next + i + 1) % len(p.keys)
+				}
+				return key
+			}
+		}
+	}
+
+	earliest := p.keys[0]
+	for _, key := range p.keys[1:] {
+		if key.cooldown.Before(earliest.cooldown) {
+			earliest = key
+		}
+	}
+	return earliest
+}
+
+// remainingOf 未知剩余配额视为最大
+func remainingOf(key *Key) int64 {
+	if key.remaining < 0 {
+		return 1<<63 - 1
+	}
+	return key.remaining
+}
+
+// Inject 移除客户端凭证并注入密钥
+func (p *KeyPool) Inject(req *http.Request, key *Key) {
+	for _, header := range p.strip {
+		req.Header.Del(header)
+	}
+	if p.query != "" {
+		query := req.URL.Query()
+		query.Set(p.query, key.value)
+		req.URL.RawQuery = query.Encode()
+		return
+	}
+	req.Header.Set(p.header, strings.ReplaceAll(p.format, "{key}", key.value))
+}
+
+// StripQuery 移除客户端在查询参数中携带的凭证
+func (p *KeyPool) StripQuery(rawQuery string) string {
+	if p.query == "" || rawQuery == "" {
+		return rawQuery
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return rawQuery
+	}
+	query.Del(p.query)
+	return query.Encode()
+}
+
+// Observe 根据上游响应更新密钥的剩余配额，被限流时进入冷却
+func (p *KeyPool) Observe(key *Key, resp *http.Response) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if remaining, ok := headerInt(resp.Header, remainingHeaders); ok {
+		key.remaining = remaining
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		cooldown := defaultKeyCooldown
+		if d, ok := parseReset(resp.Header); ok {
+			cooldown = d
+		}
+		key.cooldown = time.Now().Add(cooldown)
+		key.remaining = 0
+	}
+	metrics.UpstreamKeyRequests.WithLabelValues(p.service, key.Name, strconv.Itoa(resp.StatusCode)).Inc()
+}
+
+// headerInt 读取第一个存在的整数响应头
+func headerInt(h http.Header, names []string) (int64, bool) {
+	for _, name := range names {
+		if v := h.Get(name); v != "" {
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return n, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// parseReset 解析配额重置时间，支持 Retry-After 秒数、时长（1m30s）和 RFC 3339 时间
+func parseReset(h http.Header) (time.Duration, bool) {
+	if v := h.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second, true
+		}
+	}
+	for _, name := range resetHeaders {
+		v := h.Get(name)
+		if v == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d, true
+		}
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			if d := time.Until(t); d > 0 {
+				return d, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
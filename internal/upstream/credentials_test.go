@@ -0,0 +1,111 @@
+package upstream
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sub-router/internal/config"
+)
+
+func TestKeyPoolSources(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "key")
+	os.WriteFile(file, []byte("sk-file\n"), 0o600)
+	t.Setenv("TEST_UPSTREAM_KEY", "sk-env")
+
+	pool, err := NewKeyPool("test", config.CredentialConfig{
+		Keys: []config.CredentialKey{{Value: "sk-value"}, {Env: "TEST_UPSTREAM_KEY"}, {File: file}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create key pool: %v", err)
+	}
+
+	// 轮询使用全部密钥，Peek 不推进轮询位置
+	if key := pool.Peek(); key.value != "sk-value" || pool.Peek() != key {
+		t.Errorf("Expected Peek to return the next key without advancing, got %s", key.value)
+	}
+	var values []string
+	for i := 0; i < 3; i++ {
+		values = append(values, pool.Next().value)
+	}
+	if values[0] != "sk-value" || values[1] != "sk-env" || values[2] != "sk-file" {
+		t.Errorf("Unexpected key order: %v", values)
+	}
+
+	if _, err := NewKeyPool("test", config.CredentialConfig{
+		Keys: []config.CredentialKey{{Env: "TEST_UPSTREAM_KEY_MISSING"}},
+	}); err == nil {
+		t.Error("Expected error for missing env key")
+	}
+}
+
+func TestKeyPoolCooldown(t *testing.T) {
+	pool, _ := NewKeyPool("test", config.CredentialConfig{
+		Keys: []config.CredentialKey{{Name: "a", Value: "sk-a"}, {Name: "b", Value: "sk-b"}},
+	})
+
+	// 被限流的密钥在冷却期内不再使用
+	a := pool.Next()
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"60"}}}
+	pool.Observe(a, resp)
+	for i := 0; i < 4; i++ {
+		if key := pool.Next(); key.Name != "b" {
+			t.Errorf("Expected key b, got %s", key.Name)
+		}
+	}
+}
+
+func TestKeyPoolQuota(t *testing.T) {
+	pool, _ := NewKeyPool("test", config.CredentialConfig{
+		Strategy: config.KeyStrategyQuota,
+		Keys:     []config.CredentialKey{{Name: "a", Value: "sk-a"}, {Name: "b", Value: "sk-b"}},
+	})
+	keys := map[string]*Key{}
+	for _, key := range pool.keys {
+		keys[key.Name] = key
+	}
+
+	pool.Observe(keys["a"], &http.Response{StatusCode: 200, Header: http.Header{"X-Ratelimit-Remaining-Requests": {"5"}}})
+	pool.Observe(keys["b"], &http.Response{StatusCode: 200, Header: http.Header{"Anthropic-Ratelimit-Requests-Remaining": {"50"}}})
+	if key := pool.Next(); key.Name != "b" {
+		t.Errorf("Expected key with most remaining quota, got %s", key.Name)
+	}
+}
+
+func TestKeyPoolInject(t *testing.T) {
+	tests := []struct {
+		cfg    config.CredentialConfig
+		header string
+		value  string
+		query  string
+	}{
+		{config.CredentialConfig{}, "Authorization", "Bearer sk", ""},
+		{config.CredentialConfig{Type: config.CredentialAPIKey}, "X-Api-Key", "sk", ""},
+		{config.CredentialConfig{Header: "api-key"}, "Api-Key", "sk", ""},
+		{config.CredentialConfig{Header: "X-Token", Format: "Token {key}"}, "X-Token", "Token sk", ""},
+		{config.CredentialConfig{Type: config.CredentialQuery}, "", "", "alt=sse&key=sk"},
+	}
+	for _, tt := range tests {
+		tt.cfg.Keys = []config.CredentialKey{{Value: "sk"}}
+		pool, _ := NewKeyPool("test", tt.cfg)
+
+		req := httptest.NewRequest("GET", "http://upstream/v1?alt=sse", nil)
+		req.Header.Set("Authorization", "Bearer client")
+		req.Header.Set("X-Api-Key", "client")
+		pool.Inject(req, pool.Next())
+
+		if tt.header != "" && req.Header.Get(tt.header) != tt.value {
+			t.Errorf("%+v: expected %s=%q, got %q", tt.cfg, tt.header, tt.value, req.Header.Get(tt.header))
+		}
+		if tt.query != "" && req.URL.RawQuery != tt.query {
+			t.Errorf("%+v: expected query %q, got %q", tt.cfg, tt.query, req.URL.RawQuery)
+		}
+		for _, h := range []string{"Authorization", "X-Api-Key"} {
+			if h != tt.header && req.Header.Get(h) != "" {
+				t.Errorf("%+v: client credential %s not stripped", tt.cfg, h)
+			}
+		}
+	}
+}
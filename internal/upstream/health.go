@@ -0,0 +1,287 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"sub-router/internal/config"
+	"sub-router/pkg/metrics"
+)
+
+const (
+	defaultHealthyThreshold   = 2
+	defaultUnhealthyThreshold = 3
+
+	// 健康检查最多读取的响应体大小
+	maxProbeBodySize = 64 * 1024
+)
+
+// BackendStatus 后端健康状态
+type BackendStatus struct {
+	URL       string
+	Healthy   bool
+	Drained   bool // 是否被手动下线
+	Message   string
+	Latency   time.Duration
+	CheckedAt time.Time
+
+	successes int // 连续成功次数
+	failures  int // 连续失败次数
+}
+
+// ClientFunc 按服务获取 HTTP 客户端
+type ClientFunc func(service string) (*http.Client, error)
+
+// HealthChecker 后端主动健康检查器
+type HealthChecker struct {
+	registry *Registry
+	client   ClientFunc
+	tick     time.Duration
+
+	lastProbe map[string]time.Time
+	running   map[string]bool // 检查尚未结束的服务
+	mu        sync.Mutex
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup // 后台循环
+	probes sync.WaitGroup // 进行中的服务检查
+}
+
+// NewHealthChecker 创建健康检查器，client 为空时使用默认客户端
+func NewHealthChecker(registry *Registry, client ClientFunc) *HealthChecker {
+	if client == nil {
+		client = func(string) (*http.Client, error) { return http.DefaultClient, nil }
+	}
+	return &HealthChecker{
+		registry:  registry,
+		client:    client,
+		tick:      time.Second,
+		lastProbe: make(map[string]time.Time),
+		running:   make(map[string]bool),
+	}
+}
+
+// Start 启动后台检查
+func (h *HealthChecker) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		ticker := time.NewTicker(h.tick)
+		defer ticker.Stop()
+		for {
+			h.CheckDue(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop 停止后台检查并等待正在进行的检查结束
+func (h *HealthChecker) Stop() {
+	if h.cancel != nil {
+		h.cancel()
+	}
+	h.wg.Wait()
+	h.probes.Wait()
+}
+
+// CheckDue 启动所有到达检查间隔的服务的检查。
+//
+// 每个服务独立检查，不等待其他服务，慢服务不会推迟其他服务的下一轮检查；
+// 上一轮检查尚未结束的服务跳过本轮
+func (h *HealthChecker) CheckDue(ctx context.Context) {
+	for name := range config.Get().APIMappings {
+		mapping, _ := config.GetAPIMapping(name)
+		hc := mapping.HealthCheck
+		if hc == nil || !hc.Enabled || !h.claim(name, hc.Interval) {
+			continue
+		}
+
+		svc := h.registry.Resolve(name, mapping)
+		client, err := h.client(name)
+		h.probes.Add(1)
+		go func(name string) {
+			defer h.probes.Done()
+			defer h.release(name)
+			h.checkService(ctx, svc, mapping.Targets(), hc, client, err)
+		}(name)
+	}
+}
+
+// claim 判断服务是否到达检查间隔且没有进行中的检查，是则标记为检查中
+func (h *HealthChecker) claim(name string, interval time.Duration) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.running[name] {
+		return false
+	}
+	if last, ok := h.lastProbe[name]; ok && time.Since(last) < interval {
+		return false
+	}
+	h.lastProbe[name] = time.Now()
+	h.running[name] = true
+	return true
+}
+
+// release 标记服务的检查已结束
+func (h *HealthChecker) release(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.running, name)
+}
+
+// checkService 并发检查服务的所有后端，等待本服务的检查全部结束
+func (h *HealthChecker) checkService(ctx context.Context, svc *Service, targets []config.BackendConfig, hc *config.UpstreamHealthCheck, client *http.Client, clientErr error) {
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		if clientErr != nil {
+			svc.ReportHealth(target.URL, 0, clientErr)
+			continue
+		}
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			latency, err := probe(ctx, client, url, hc, svc.Credentials)
+			if ctx.Err() != nil {
+				return
+			}
+			svc.ReportHealth(url, latency, err)
+		}(target.URL)
+	}
+	wg.Wait()
+}
+
+// probe 执行一次健康检查
+func probe(ctx context.Context, client *http.Client, baseURL string, hc *config.UpstreamHealthCheck, creds *KeyPool) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, hc.Timeout)
+	defer cancel()
+
+	method := hc.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	target := strings.TrimSuffix(baseURL, "/") + "/" + strings.TrimPrefix(hc.Path, "/")
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	// 需要认证的检查路径（如 /v1/models）使用服务的上游凭证，不推进业务请求的密钥轮询
+	if creds != nil {
+		if key := creds.Peek(); key != nil {
+			creds.Inject(req, key)
+		}
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return time.Since(start), err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBodySize))
+	latency := time.Since(start)
+	if err != nil {
+		return latency, err
+	}
+
+	if !expectedStatus(resp.StatusCode, hc.ExpectedStatus) {
+		return latency, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if hc.ExpectedBody != "" && !strings.Contains(string(body), hc.ExpectedBody) {
+		return latency, fmt.Errorf("response body does not contain %q", hc.ExpectedBody)
+	}
+	if hc.MaxLatency > 0 && latency > hc.MaxLatency {
+		return latency, fmt.Errorf("latency %v exceeds budget %v", latency, hc.MaxLatency)
+	}
+	return latency, nil
+}
+
+// expectedStatus 判断状态码是否符合预期
+func expectedStatus(status int, expected []int) bool {
+	if len(expected) == 0 {
+		return status >= 200 && status < 400
+	}
+	for _, code := range expected {
+		if status == code {
+			return true
+		}
+	}
+	return false
+}
+
+// ReportHealth 记录健康检查结果，连续成功或失败达到阈值后才切换状态
+func (s *Service) ReportHealth(url string, latency time.Duration, err error) {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+
+	status, ok := s.health[url]
+	if !ok {
+		return
+	}
+	status.Latency = latency
+	status.CheckedAt = time.Now()
+
+	healthy, unhealthy := defaultHealthyThreshold, defaultUnhealthyThreshold
+	if hc := s.Mapping.HealthCheck; hc != nil {
+		if hc.HealthyThreshold > 0 {
+			healthy = hc.HealthyThreshold
+		}
+		if hc.UnhealthyThreshold > 0 {
+			unhealthy = hc.UnhealthyThreshold
+		}
+	}
+
+	if err != nil {
+		status.Message = err.Error()
+		status.successes = 0
+		status.failures++
+		if status.Healthy && status.failures >= unhealthy {
+			status.Healthy = false
+			s.Balancer.MarkDown(url)
+		}
+	} else {
+		status.Message = ""
+		status.failures = 0
+		status.successes++
+		if !status.Healthy && status.successes >= healthy {
+			status.Healthy = true
+			if !s.drained[url] {
+				s.Balancer.MarkUp(url)
+			}
+		}
+	}
+
+	value := 0.0
+	if status.Healthy {
+		value = 1
+	}
+	metrics.BackendHealth.WithLabelValues(s.Name, url).Set(value)
+}
+
+// Health 获取各后端健康状态快照
+func (s *Service) Health() []BackendStatus {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+
+	result := make([]BackendStatus, 0, len(s.health))
+	for _, target := range s.Mapping.Targets() {
+		if status, ok := s.health[target.URL]; ok {
+			snapshot := *status
+			snapshot.Drained = s.drained[target.URL]
+			result = append(result, snapshot)
+		}
+	}
+	return result
+}
@@ -0,0 +1,124 @@
+package upstream
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sub-router/internal/config"
+)
+
+func TestHealthCheckerHysteresis(t *testing.T) {
+	// 创建可切换健康状态的后端
+	var healthy atomic.Bool
+	healthy.Store(true)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer backend.Close()
+
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"test": {
+				URL: backend.URL,
+				HealthCheck: &config.UpstreamHealthCheck{
+					Enabled:            true,
+					Path:               "/healthz",
+					Interval:           time.Nanosecond,
+					Timeout:            time.Second,
+					ExpectedBody:       `"ok"`,
+					HealthyThreshold:   2,
+					UnhealthyThreshold: 2,
+				},
+			},
+		},
+	})
+
+	registry := NewRegistry()
+	checker := NewHealthChecker(registry, nil)
+	ctx := context.Background()
+	check := func() BackendStatus {
+		checker.CheckDue(ctx)
+		checker.probes.Wait()
+		svc, _ := registry.Get("test")
+		return svc.Health()[0]
+	}
+
+	if status := check(); !status.Healthy || status.Message != "" {
+		t.Fatalf("Expected healthy backend, got %+v", status)
+	}
+
+	// 单次失败不应标记为不可用
+	healthy.Store(false)
+	if status := check(); !status.Healthy {
+		t.Fatal("Single failure should not mark backend down")
+	}
+	if status := check(); status.Healthy {
+		t.Fatal("Expected backend to be marked down after 2 failures")
+	}
+	svc, _ := registry.Get("test")
+	if svc.Next(nil) != nil {
+		t.Error("Expected no available backend after mark down")
+	}
+
+	// 连续成功后恢复
+	healthy.Store(true)
+	if status := check(); status.Healthy {
+		t.Fatal("Single success should not mark backend up")
+	}
+	if status := check(); !status.Healthy {
+		t.Fatal("Expected backend to be marked up after 2 successes")
+	}
+	if svc.Next(nil) == nil {
+		t.Error("Expected backend to be available after mark up")
+	}
+}
+
+func TestHealthCheckerIndependentServices(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+	var fastProbes atomic.Int32
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fastProbes.Add(1)
+	}))
+	defer fast.Close()
+
+	hc := &config.UpstreamHealthCheck{Enabled: true, Path: "/", Interval: time.Nanosecond, Timeout: 5 * time.Second}
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"slow": {URL: slow.URL, HealthCheck: hc},
+			"fast": {URL: fast.URL, HealthCheck: hc},
+		},
+	})
+	checker := NewHealthChecker(NewRegistry(), nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		checker.probes.Wait()
+	}()
+
+	// 慢服务的检查没有结束时，其他服务仍按自己的间隔检查
+	deadline := time.Now().Add(2 * time.Second)
+	for fastProbes.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected fast service to be probed repeatedly, got %d probes", fastProbes.Load())
+		}
+		checker.CheckDue(ctx)
+		time.Sleep(10 * time.Millisecond)
+	}
+}
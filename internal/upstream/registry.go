@@ -0,0 +1,387 @@
+package upstream
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"sub-router/internal/config"
+	"sub-router/pkg/breaker"
+	"sub-router/pkg/loadbalance"
+	"sub-router/pkg/metrics"
+	"sub-router/pkg/transform"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Service 上游服务
+type Service struct {
+	Name    string
+	Mapping config.APIMapping
+
+	// 后端状态，映射中后端、策略和熔断配置不变时在重建的服务之间共享
+	*backendState
+
+	// 上游密钥池，未配置凭证时为空；凭证配置不变时在重建的服务之间共享
+	Credentials *KeyPool
+
+	// 由 transforms 编译的转换器，未配置规则时为空
+	Transformer *transform.Transformer
+
+	// 接口格式翻译器，未配置 translate 时为空
+	Translator *transform.Translator
+
+	// 创建或最近确认服务时映射所属配置快照的代数
+	generation atomic.Uint64
+}
+
+// backendState 服务的负载均衡、熔断和健康检查状态
+type backendState struct {
+	Balancer loadbalance.Balancer
+
+	// 服务级熔断器（单个后端时），以及后端池中每个后端各自的熔断器
+	breaker  *breaker.CircuitBreaker
+	breakers map[string]*breaker.CircuitBreaker
+	size     int // 后端数量
+
+	// 主动健康检查结果，以及被手动下线的后端
+	health   map[string]*BackendStatus
+	drained  map[string]bool
+	healthMu sync.Mutex
+}
+
+// Registry 上游服务注册表，按服务名缓存负载均衡器
+type Registry struct {
+	services map[string]*Service
+	// 手动下线的后端，服务重建后仍然保持
+	drained map[string]map[string]bool
+	mu      sync.RWMutex
+}
+
+// GlobalRegistry 全局上游服务注册表
+var GlobalRegistry = NewRegistry()
+
+// NewRegistry 创建上游服务注册表
+func NewRegistry() *Registry {
+	return &Registry{
+		services: make(map[string]*Service),
+		drained:  make(map[string]map[string]bool),
+	}
+}
+
+// Resolve 获取服务，映射配置发生变化时重建服务。
+//
+// 来自同一份配置快照的映射只比较代数；配置替换后逐项比较一次，内容不变时沿用原服务。
+// 只有后端、负载均衡策略或熔断配置变化时才重置后端状态，其他配置（超时、转换规则等）变化时
+// 熔断、健康检查、负载均衡位置和密钥冷却状态都会保留
+func (r *Registry) Resolve(name string, mapping config.APIMapping) *Service {
+	r.mu.RLock()
+	svc, ok := r.services[name]
+	r.mu.RUnlock()
+	if ok && svc.matches(mapping) {
+		return svc
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 双重检查，避免并发重建
+	prev, ok := r.services[name]
+	if ok && prev.matches(mapping) {
+		return prev
+	}
+	svc = newService(name, mapping, prev)
+	if prev == nil || svc.backendState != prev.backendState {
+		for url := range r.drained[name] {
+			svc.setDrained(url, true)
+		}
+	}
+	r.services[name] = svc
+	return svc
+}
+
+// matches 判断服务是否由相同的映射配置创建，配置内容相同时记录新的代数
+func (s *Service) matches(mapping config.APIMapping) bool {
+	gen := mapping.Generation()
+	if gen != 0 && s.generation.Load() == gen {
+		return true
+	}
+	if !s.Mapping.Equal(mapping) {
+		return false
+	}
+	if gen != 0 {
+		s.generation.Store(gen)
+	}
+	return true
+}
+
+// SetDrained 手动下线或恢复后端，下线的后端不会被健康检查自动恢复
+func (r *Registry) SetDrained(name string, mapping config.APIMapping, url string, drained bool) error {
+	svc := r.Resolve(name, mapping)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !svc.setDrained(url, drained) {
+		return fmt.Errorf("backend %s not found in service %s", url, name)
+	}
+	if drained {
+		if r.drained[name] == nil {
+			r.drained[name] = make(map[string]bool)
+		}
+		r.drained[name][url] = true
+	} else {
+		delete(r.drained[name], url)
+	}
+	return nil
+}
+
+// ReloadCredentials 重新读取所有服务的上游密钥，用于环境变量或密钥文件更新后刷新
+func (r *Registry) ReloadCredentials() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, svc := range r.services {
+		if svc.Credentials == nil {
+			continue
+		}
+		if err := svc.Credentials.Reload(); err != nil {
+			log.Printf("upstream credentials reload failed, keeping previous keys: %v", err)
+		}
+	}
+}
+
+// Prune 移除配置中已不存在的服务
+func (r *Registry) Prune(mappings map[string]config.APIMapping) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name := range r.services {
+		if _, ok := mappings[name]; !ok {
+			delete(r.services, name)
+			delete(r.drained, name)
+			metrics.BackendHealth.DeletePartialMatch(prometheus.Labels{"service": name})
+		}
+	}
+}
+
+// Get 获取已创建的服务
+func (r *Registry) Get(name string) (*Service, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	svc, ok := r.services[name]
+	return svc, ok
+}
+
+// newService 根据映射配置创建服务，prev 不为空时沿用其中配置未变化的状态
+func newService(name string, mapping config.APIMapping, prev *Service) *Service {
+	svc := &Service{Name: name, Mapping: mapping}
+	svc.generation.Store(mapping.Generation())
+	if prev != nil && prev.Mapping.SameBackends(mapping) {
+		svc.backendState = prev.backendState
+	} else {
+		svc.backendState = newBackendState(name, mapping)
+	}
+
+	// 密钥已在配置校验时读取过，这里失败说明密钥在此期间被移除
+	if prev != nil && prev.Credentials != nil && reflect.DeepEqual(prev.Mapping.Credentials, mapping.Credentials) {
+		svc.Credentials = prev.Credentials
+	} else if mapping.Credentials != nil {
+		pool, err := NewKeyPool(name, *mapping.Credentials)
+		if err != nil {
+			log.Printf("upstream credentials unavailable: %v", err)
+			pool = &KeyPool{service: name}
+		}
+		svc.Credentials = pool
+	}
+
+	// 规则已在配置校验时编译过，这里失败时不做转换
+	if len(mapping.Transforms) > 0 {
+		t, err := transform.Compile(mapping.Transforms)
+		if err != nil {
+			log.Printf("transform rules unavailable for service %s: %v", name, err)
+		}
+		svc.Transformer = t
+	}
+	if mapping.Translate != "" {
+		t, err := transform.NewTranslator(mapping.Translate)
+		if err != nil {
+			log.Printf("translator unavailable for service %s: %v", name, err)
+		}
+		svc.Translator = t
+	}
+	return svc
+}
+
+// newBackendState 根据映射中的后端、策略和熔断配置创建后端状态
+func newBackendState(name string, mapping config.APIMapping) *backendState {
+	// 策略已在配置校验时检查，这里解析失败时退回轮询
+	strategy, _ := loadbalance.ParseStrategy(mapping.Strategy)
+	state := &backendState{
+		Balancer: loadbalance.NewBalancer(strategy),
+		breakers: make(map[string]*breaker.CircuitBreaker),
+		health:   make(map[string]*BackendStatus),
+		drained:  make(map[string]bool),
+	}
+
+	// 多个后端时只使用后端熔断器，单个后端故障不影响其他后端；全部熔断时选不出后端
+	targets := mapping.Targets()
+	state.size = len(targets)
+	if len(targets) <= 1 {
+		state.breaker = newBreaker(mapping.CircuitBreaker, name, "")
+	}
+	for _, target := range targets {
+		state.Balancer.Add(&loadbalance.Backend{
+			URL:      target.URL,
+			Weight:   target.Weight,
+			Priority: target.Priority,
+			Healthy:  true,
+		})
+		state.health[target.URL] = &BackendStatus{URL: target.URL, Healthy: true}
+		if len(targets) > 1 {
+			if cb := newBreaker(mapping.CircuitBreaker, name, target.URL); cb != nil {
+				state.breakers[target.URL] = cb
+			}
+		}
+	}
+	return state
+}
+
+// newBreaker 创建熔断器并同步状态到监控指标，未启用时返回 nil
+func newBreaker(cfg *config.CircuitBreakerConfig, service, backend string) *breaker.CircuitBreaker {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	gauge := metrics.CircuitBreakerStatus.WithLabelValues(service, backend)
+	gauge.Set(float64(breaker.StateClosed))
+	return breaker.NewCircuitBreaker(breaker.Config{
+		ErrorThreshold:   cfg.ErrorThreshold,
+		SuccessThreshold: cfg.SuccessThreshold,
+		Timeout:          cfg.Timeout,
+		MaxRequests:      cfg.MaxRequests,
+		OnStateChange: func(from, to breaker.State) {
+			gauge.Set(float64(to))
+		},
+	})
+}
+
+// Allow 判断服务级熔断器能否放行请求，不占用半开状态的试探名额；实际放行在 Next 中
+func (s *Service) Allow() bool {
+	return s.breaker == nil || s.breaker.Ready()
+}
+
+// Next 选择下一个可用后端，跳过熔断中的后端。
+//
+// 优先选择 tried 之外的后端，没有时才重复选择已尝试过的后端；已尝试过的后端在熔断器放行之前跳过，
+// 不会占用半开状态的试探名额。返回的后端已被熔断器放行，调用方必须通过 Success、Failure 或 Cancel 报告结果
+func (s *Service) Next(tried map[string]bool) *loadbalance.Backend {
+	var retry *loadbalance.Backend
+	for i := 0; i <= s.size; i++ {
+		backend := s.Balancer.Next()
+		if backend == nil {
+			break
+		}
+		if tried[backend.URL] {
+			if retry == nil {
+				retry = backend
+			}
+			continue
+		}
+		if cb := s.breakerFor(backend); cb == nil || cb.Allow() {
+			return backend
+		}
+	}
+	if retry != nil {
+		if cb := s.breakerFor(retry); cb == nil || cb.Allow() {
+			return retry
+		}
+	}
+	return nil
+}
+
+// breakerFor 获取后端使用的熔断器，未启用时返回 nil
+func (s *Service) breakerFor(backend *loadbalance.Backend) *breaker.CircuitBreaker {
+	if cb, ok := s.breakers[backend.URL]; ok {
+		return cb
+	}
+	return s.breaker
+}
+
+// Success 记录后端请求成功
+func (s *Service) Success(backend *loadbalance.Backend) {
+	if cb := s.breakerFor(backend); cb != nil {
+		cb.Success()
+	}
+}
+
+// Failure 记录后端请求失败
+func (s *Service) Failure(backend *loadbalance.Backend) {
+	if cb := s.breakerFor(backend); cb != nil {
+		cb.Failure()
+	}
+}
+
+// Cancel 放弃已选择但没有结果的后端请求，不计入成功或失败
+func (s *Service) Cancel(backend *loadbalance.Backend) {
+	if cb := s.breakerFor(backend); cb != nil {
+		cb.Cancel()
+	}
+}
+
+// BreakerState 获取服务的熔断状态：多个后端时所有后端都熔断才视为熔断，未启用时视为关闭
+func (s *Service) BreakerState() breaker.State {
+	if s.breaker != nil {
+		return s.breaker.State()
+	}
+	if len(s.breakers) == 0 {
+		return breaker.StateClosed
+	}
+	for _, cb := range s.breakers {
+		if cb.State() != breaker.StateOpen {
+			return breaker.StateClosed
+		}
+	}
+	return breaker.StateOpen
+}
+
+// BackendBreakerState 获取后端熔断器状态，未单独配置熔断器时返回 false
+func (s *Service) BackendBreakerState(url string) (breaker.State, bool) {
+	cb, ok := s.breakers[url]
+	if !ok {
+		return breaker.StateClosed, false
+	}
+	return cb.State(), true
+}
+
+// setDrained 设置后端手动下线状态，恢复时仍需健康检查通过
+func (s *Service) setDrained(url string, drained bool) bool {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+
+	status, ok := s.health[url]
+	if !ok {
+		return false
+	}
+	if drained {
+		s.drained[url] = true
+		s.Balancer.MarkDown(url)
+		return true
+	}
+	delete(s.drained, url)
+	if status.Healthy {
+		s.Balancer.MarkUp(url)
+	}
+	return true
+}
+
+// HasOpenBackend 判断是否存在熔断中的后端
+func (s *Service) HasOpenBackend() bool {
+	if s.breaker != nil && s.breaker.State() != breaker.StateClosed {
+		return true
+	}
+	for _, cb := range s.breakers {
+		if cb.State() == breaker.StateOpen {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package upstream
+
+import (
+	"testing"
+	"time"
+
+	"sub-router/internal/config"
+	"sub-router/pkg/breaker"
+	"sub-router/pkg/loadbalance"
+)
+
+func TestRegistryResolve(t *testing.T) {
+	registry := NewRegistry()
+	mapping := config.APIMapping{URL: "http://a"}
+
+	// 相同配置复用同一服务
+	svc := registry.Resolve("test", mapping)
+	if registry.Resolve("test", mapping) != svc {
+		t.Error("Expected service to be reused for unchanged mapping")
+	}
+
+	// 配置变化时重建服务
+	rebuilt := registry.Resolve("test", config.APIMapping{URL: "http://b"})
+	if rebuilt == svc || rebuilt.Next(nil).URL != "http://b" {
+		t.Error("Expected service to be rebuilt after mapping change")
+	}
+}
+
+func TestServiceBackendBreaker(t *testing.T) {
+	svc := NewRegistry().Resolve("pool", config.APIMapping{
+		Backends: []config.BackendConfig{
+			{URL: "http://a"},
+			{URL: "http://b"},
+		},
+		CircuitBreaker: &config.CircuitBreakerConfig{
+			Enabled:          true,
+			ErrorThreshold:   1,
+			SuccessThreshold: 1,
+			Timeout:          time.Minute,
+			MaxRequests:      1,
+		},
+	})
+
+	// 后端 a 失败后熔断
+	backend := svc.Next(nil)
+	for backend.URL != "http://a" {
+		backend = svc.Next(nil)
+	}
+	svc.Failure(backend)
+	if !svc.HasOpenBackend() {
+		t.Fatal("Expected backend a to be open")
+	}
+	// 单个后端熔断不影响服务
+	if state := svc.BreakerState(); state != breaker.StateClosed || !svc.Allow() {
+		t.Errorf("Expected service to stay available, got %s", state)
+	}
+
+	// 后续请求只会选择 b
+	for i := 0; i < 4; i++ {
+		if backend := svc.Next(nil); backend == nil || backend.URL != "http://b" {
+			t.Fatalf("Expected backend b, got %v", backend)
+		}
+	}
+
+	// 所有后端都熔断时服务视为熔断，选不出后端
+	svc.Failure(svc.Next(nil))
+	if state := svc.BreakerState(); state != breaker.StateOpen || svc.Next(nil) != nil {
+		t.Errorf("Expected service to be open, got %s", state)
+	}
+}
+
+func TestServiceNextSkipsTriedBackends(t *testing.T) {
+	svc := NewRegistry().Resolve("pool", config.APIMapping{
+		Backends: []config.BackendConfig{
+			{URL: "http://a"},
+			{URL: "http://b"},
+		},
+		CircuitBreaker: &config.CircuitBreakerConfig{
+			Enabled:          true,
+			ErrorThreshold:   1,
+			SuccessThreshold: 1,
+			Timeout:          20 * time.Millisecond,
+			MaxRequests:      1,
+		},
+	})
+	a := svc.Next(map[string]bool{"http://b": true})
+	if a == nil || a.URL != "http://a" {
+		t.Fatalf("Expected untried backend a, got %v", a)
+	}
+	svc.Failure(a)
+	time.Sleep(30 * time.Millisecond)
+
+	// 已尝试过的后端在熔断器放行之前跳过，不占用其半开试探名额
+	tried := map[string]bool{"http://a": true}
+	for i := 0; i < 4; i++ {
+		if backend := svc.Next(tried); backend == nil || backend.URL != "http://b" {
+			t.Fatalf("Expected backend b, got %v", backend)
+		}
+		svc.Success(&loadbalance.Backend{URL: "http://b"})
+	}
+	only := map[string]bool{"http://b": true}
+	probe := svc.Next(only)
+	if probe == nil || probe.URL != "http://a" {
+		t.Fatalf("Expected half-open probe to a, got %v", probe)
+	}
+
+	// 取消的试探归还名额，成功后恢复
+	svc.Cancel(probe)
+	if probe = svc.Next(only); probe == nil || probe.URL != "http://a" {
+		t.Fatalf("Expected cancelled probe slot to be reusable, got %v", probe)
+	}
+	svc.Success(probe)
+	if state, _ := svc.BackendBreakerState("http://a"); state != breaker.StateClosed {
+		t.Errorf("Expected backend a to recover, got %s", state)
+	}
+}
+
+func TestRegistryResolveKeepsBackendState(t *testing.T) {
+	cb := &config.CircuitBreakerConfig{
+		Enabled:          true,
+		ErrorThreshold:   1,
+		SuccessThreshold: 1,
+		Timeout:          time.Minute,
+		MaxRequests:      1,
+	}
+	load := func(mapping config.APIMapping) config.APIMapping {
+		config.Set(&config.Config{APIMappings: map[string]config.APIMapping{"test": mapping}})
+		mapping, _ = config.Get().APIMapping("test")
+		return mapping
+	}
+	registry := NewRegistry()
+
+	// 同一配置快照只比较代数
+	mapping := load(config.APIMapping{URL: "http://a", CircuitBreaker: cb})
+	svc := registry.Resolve("test", mapping)
+	if registry.Resolve("test", mapping) != svc {
+		t.Fatal("Expected service to be reused within a generation")
+	}
+	svc.Failure(svc.Next(nil))
+	if svc.BreakerState() != breaker.StateOpen {
+		t.Fatal("Expected service breaker to be open")
+	}
+
+	// 配置重新加载但映射未变化时沿用原服务
+	mapping = load(config.APIMapping{URL: "http://a", CircuitBreaker: cb})
+	if registry.Resolve("test", mapping) != svc {
+		t.Error("Expected service to be reused after reload without changes")
+	}
+
+	// 非后端配置变化时重建服务，但保留熔断状态
+	mapping = load(config.APIMapping{URL: "http://a", CircuitBreaker: cb, Translate: "anthropic"})
+	rebuilt := registry.Resolve("test", mapping)
+	if rebuilt == svc || rebuilt.Translator == nil {
+		t.Fatal("Expected service to be rebuilt after translate change")
+	}
+	if rebuilt.BreakerState() != breaker.StateOpen {
+		t.Errorf("Expected breaker state to be kept, got %s", rebuilt.BreakerState())
+	}
+
+	// 后端变化时重置后端状态
+	mapping = load(config.APIMapping{URL: "http://b", CircuitBreaker: cb, Translate: "anthropic"})
+	reset := registry.Resolve("test", mapping)
+	if reset.BreakerState() != breaker.StateClosed || reset.Next(nil).URL != "http://b" {
+		t.Error("Expected backend state to be reset after backend change")
+	}
+}
@@ -20,6 +20,23 @@ type Config struct {
 	SuccessThreshold int           // 成功阈值
 	Timeout          time.Duration // 熔断超时时间
 	MaxRequests      int           // 半开状态最大请求数
+
+	// OnStateChange 状态变化回调，在持有锁时调用，不应阻塞
+	OnStateChange func(from, to State)
+}
+
+// String 返回状态名称
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
 }
 
 // CircuitBreaker 熔断器
@@ -28,6 +45,7 @@ type CircuitBreaker struct {
 	config          Config
 	failures        int
 	successes       int
+	probes          int // 本轮半开状态已放行的请求数
 	lastStateChange time.Time
 	mu              sync.RWMutex
 }
@@ -42,8 +60,9 @@ func NewCircuitBreaker(config Config) *CircuitBreaker {
 
 // Allow 判断是否允许请求
 func (cb *CircuitBreaker) Allow() bool {
-	cb.mu.RLock()
-	defer cb.mu.RUnlock()
+	// 开启状态超时后会切换到半开状态，因此需要写锁
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
 
 	switch cb.state {
 	case StateClosed:
@@ -51,16 +70,54 @@ func (cb *CircuitBreaker) Allow() bool {
 	case StateOpen:
 		if time.Since(cb.lastStateChange) > cb.config.Timeout {
 			cb.toHalfOpen()
+			cb.probes = 1
 			return true
 		}
 		return false
 	case StateHalfOpen:
-		return cb.successes < cb.config.MaxRequests
+		// 按放行次数而不是成功次数限制试探请求，避免并发请求全部通过；
+		// 放行的请求超时仍未报告结果（如客户端断开）时开始新一轮试探
+		if cb.probes >= cb.config.MaxRequests {
+			if time.Since(cb.lastStateChange) <= cb.config.Timeout {
+				return false
+			}
+			cb.lastStateChange = time.Now()
+			cb.probes = 0
+		}
+		cb.probes++
+		return true
+	default:
+		return false
+	}
+}
+
+// Ready 判断熔断器当前能否放行请求，不占用半开状态的试探名额
+func (cb *CircuitBreaker) Ready() bool {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	switch cb.state {
+	case StateClosed:
+		return true
+	case StateOpen:
+		return time.Since(cb.lastStateChange) > cb.config.Timeout
+	case StateHalfOpen:
+		return cb.probes < cb.config.MaxRequests || time.Since(cb.lastStateChange) > cb.config.Timeout
 	default:
 		return false
 	}
 }
 
+// Cancel 放弃已放行但没有结果的请求（如客户端断开），归还半开状态的试探名额
+func (cb *CircuitBreaker) Cancel() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == StateHalfOpen && cb.probes > 0 {
+		cb.probes--
+	}
+}
+
 // Success 记录成功请求
 func (cb *CircuitBreaker) Success() {
 	cb.mu.Lock()
@@ -96,24 +153,36 @@ func (cb *CircuitBreaker) Failure() {
 
 // 状态转换方法
 func (cb *CircuitBreaker) toOpen() {
-	cb.state = StateOpen
+	cb.setState(StateOpen)
 	cb.lastStateChange = time.Now()
 	cb.failures = 0
 	cb.successes = 0
+	cb.probes = 0
 }
 
 func (cb *CircuitBreaker) toHalfOpen() {
-	cb.state = StateHalfOpen
+	cb.setState(StateHalfOpen)
 	cb.lastStateChange = time.Now()
 	cb.failures = 0
 	cb.successes = 0
+	cb.probes = 0
 }
 
 func (cb *CircuitBreaker) toClosed() {
-	cb.state = StateClosed
+	cb.setState(StateClosed)
 	cb.lastStateChange = time.Now()
 	cb.failures = 0
 	cb.successes = 0
+	cb.probes = 0
+}
+
+// setState 切换状态并触发回调
+func (cb *CircuitBreaker) setState(state State) {
+	from := cb.state
+	cb.state = state
+	if from != state && cb.config.OnStateChange != nil {
+		cb.config.OnStateChange(from, state)
+	}
 }
 
 // State 获取当前状态
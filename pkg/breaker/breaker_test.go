@@ -89,3 +89,93 @@ func TestCircuitBreakerEdgeCases(t *testing.T) {
 	}()
 	time.Sleep(100 * time.Millisecond)
 }
+
+func TestCircuitBreakerStateChange(t *testing.T) {
+	var transitions []string
+	breaker := NewCircuitBreaker(Config{
+		ErrorThreshold:   1,
+		SuccessThreshold: 1,
+		Timeout:          50 * time.Millisecond,
+		MaxRequests:      1,
+		OnStateChange: func(from, to State) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+	})
+
+	breaker.Failure()
+	time.Sleep(60 * time.Millisecond)
+	breaker.Allow()
+	breaker.Success()
+
+	expected := []string{"closed->open", "open->half_open", "half_open->closed"}
+	if len(transitions) != len(expected) {
+		t.Fatalf("Expected transitions %v, got %v", expected, transitions)
+	}
+	for i := range expected {
+		if transitions[i] != expected[i] {
+			t.Errorf("Expected transition %s, got %s", expected[i], transitions[i])
+		}
+	}
+}
+
+func TestCircuitBreakerHalfOpenProbes(t *testing.T) {
+	breaker := NewCircuitBreaker(Config{
+		ErrorThreshold:   1,
+		SuccessThreshold: 2,
+		Timeout:          50 * time.Millisecond,
+		MaxRequests:      2,
+	})
+	breaker.Failure()
+	time.Sleep(60 * time.Millisecond)
+
+	// 半开状态只放行 MaxRequests 个请求，不论是否已返回结果
+	allowed := 0
+	for i := 0; i < 5; i++ {
+		if breaker.Allow() {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("Expected 2 probes in half-open state, got %d", allowed)
+	}
+
+	// 试探请求一直没有结果时，超时后开始新一轮试探
+	breaker.Success()
+	time.Sleep(60 * time.Millisecond)
+	if !breaker.Allow() {
+		t.Error("Expected a new probe after timeout")
+	}
+	breaker.Success()
+	if state := breaker.State(); state != StateClosed {
+		t.Errorf("Expected state to be Closed, got %v", state)
+	}
+}
+
+func TestCircuitBreakerCancel(t *testing.T) {
+	breaker := NewCircuitBreaker(Config{
+		ErrorThreshold:   1,
+		SuccessThreshold: 1,
+		Timeout:          time.Minute,
+		MaxRequests:      1,
+	})
+	breaker.Failure()
+	if breaker.Ready() {
+		t.Fatal("Expected open breaker not to be ready")
+	}
+	breaker.lastStateChange = time.Now().Add(-2 * time.Minute)
+	if !breaker.Ready() || !breaker.Allow() {
+		t.Fatal("Expected a probe after timeout")
+	}
+
+	// 名额用完后不再放行，取消的试探归还名额
+	if breaker.Ready() || breaker.Allow() {
+		t.Fatal("Expected probe slots to be exhausted")
+	}
+	breaker.Cancel()
+	if !breaker.Ready() || !breaker.Allow() {
+		t.Error("Expected cancelled probe to release its slot")
+	}
+	if state := breaker.State(); state != StateHalfOpen {
+		t.Errorf("Expected cancel not to change state, got %v", state)
+	}
+}
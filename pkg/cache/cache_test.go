@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLRUEviction(t *testing.T) {
+	c := NewLRU(100)
+	entry := func(n int) *Entry {
+		return &Entry{Status: 200, Header: http.Header{}, Body: make([]byte, n)}
+	}
+
+	c.Set("a", entry(40))
+	c.Set("b", entry(40))
+	// 访问 a 后 b 成为最久未使用的条目
+	c.Get("a")
+	c.Set("c", entry(40))
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("Expected a to be kept")
+	}
+	if entries, bytes := c.Stats(); entries != 2 || bytes != 82 {
+		t.Errorf("Expected 2 entries / 82 bytes, got %d / %d", entries, bytes)
+	}
+	if c.Set("big", entry(200)) {
+		t.Error("Expected entry larger than the budget to be rejected")
+	}
+
+	c.Resize(50)
+	if entries, _ := c.Stats(); entries != 1 {
+		t.Errorf("Expected resize to evict down to 1 entry, got %d", entries)
+	}
+}
+
+func TestPolicyFreshness(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	policy := Policy{TTL: time.Minute}
+
+	tests := []struct {
+		name     string
+		status   int
+		header   http.Header
+		ttl      time.Duration
+		storable bool
+	}{
+		{"default ttl", 200, http.Header{}, time.Minute, true},
+		{"max-age", 200, http.Header{"Cache-Control": {"public, max-age=30"}}, 30 * time.Second, true},
+		{"s-maxage wins", 200, http.Header{"Cache-Control": {"max-age=30, s-maxage=90"}}, 90 * time.Second, true},
+		{"age", 200, http.Header{"Cache-Control": {"max-age=30"}, "Age": {"10"}}, 20 * time.Second, true},
+		{"expires", 200, http.Header{
+			"Date":    {now.Format(http.TimeFormat)},
+			"Expires": {now.Add(time.Hour).Format(http.TimeFormat)},
+		}, time.Hour, true},
+		{"no-store", 200, http.Header{"Cache-Control": {"no-store"}}, 0, false},
+		{"private", 200, http.Header{"Cache-Control": {"private, max-age=30"}}, 0, false},
+		{"set-cookie", 200, http.Header{"Set-Cookie": {"a=b"}}, 0, false},
+		{"vary star", 200, http.Header{"Vary": {"*"}}, 0, false},
+		{"no-cache without validator", 200, http.Header{"Cache-Control": {"no-cache"}}, 0, false},
+		{"no-cache with etag", 200, http.Header{"Cache-Control": {"no-cache"}, "Etag": {`"x"`}}, 0, true},
+		{"server error", 500, http.Header{}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ttl, ok := policy.Freshness(&http.Response{StatusCode: tt.status, Header: tt.header}, now)
+			if ttl != tt.ttl || ok != tt.storable {
+				t.Errorf("Expected (%v, %v), got (%v, %v)", tt.ttl, tt.storable, ttl, ok)
+			}
+		})
+	}
+
+	forced := Policy{TTL: time.Minute, ForceTTL: true}
+	if ttl, ok := forced.Freshness(&http.Response{StatusCode: 200, Header: http.Header{"Cache-Control": {"no-store"}}}, now); !ok || ttl != time.Minute {
+		t.Errorf("Expected force_ttl to override no-store, got (%v, %v)", ttl, ok)
+	}
+}
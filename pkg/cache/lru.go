@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Entry 缓存的上游响应
+type Entry struct {
+	Status  int
+	Header  http.Header
+	Body    []byte
+	Vary    map[string]string // 生成该响应时 Vary 列出的请求头取值
+	Stored  time.Time         // 存入或最近一次重新验证的时间
+	Expires time.Time         // 过期后需要重新验证
+}
+
+// Fresh 判断缓存是否仍然新鲜
+func (e *Entry) Fresh(now time.Time) bool {
+	return now.Before(e.Expires)
+}
+
+// Validators 获取用于条件请求的 ETag 和 Last-Modified
+func (e *Entry) Validators() (etag, lastModified string) {
+	return e.Header.Get("ETag"), e.Header.Get("Last-Modified")
+}
+
+// Matches 判断请求头是否满足响应的 Vary 条件
+func (e *Entry) Matches(header http.Header) bool {
+	for name, value := range e.Vary {
+		if header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// size 估算条目占用的字节数
+func (e *Entry) size(key string) int64 {
+	n := len(key) + len(e.Body)
+	for name, values := range e.Header {
+		n += len(name)
+		for _, v := range values {
+			n += len(v)
+		}
+	}
+	for name, value := range e.Vary {
+		n += len(name) + len(value)
+	}
+	return int64(n)
+}
+
+// LRU 按字节预算淘汰最近最少使用条目的内存缓存
+type LRU struct {
+	maxBytes int64
+	bytes    int64
+	items    map[string]*list.Element
+	lru      *list.List
+	mu       sync.Mutex
+}
+
+// item LRU 中的条目
+type item struct {
+	key   string
+	entry *Entry
+	size  int64
+}
+
+// NewLRU 创建缓存，maxBytes 为所有条目的总字节上限
+func NewLRU(maxBytes int64) *LRU {
+	return &LRU{
+		maxBytes: maxBytes,
+		items:    make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Get 获取缓存条目，返回的条目不应被修改
+func (c *LRU) Get(key string) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*item).entry, true
+}
+
+// Set 存入缓存条目，超过总预算的条目不会被存入
+func (c *LRU) Set(key string, entry *Entry) bool {
+	size := entry.size(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if size > c.maxBytes {
+		return false
+	}
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+	c.items[key] = c.lru.PushFront(&item{key: key, entry: entry, size: size})
+	c.bytes += size
+	c.evict()
+	return true
+}
+
+// Delete 删除缓存条目
+func (c *LRU) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+}
+
+// Resize 修改字节预算，超出部分立即淘汰
+func (c *LRU) Resize(maxBytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxBytes = maxBytes
+	c.evict()
+}
+
+// Stats 返回条目数和占用字节数
+func (c *LRU) Stats() (entries int, bytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len(), c.bytes
+}
+
+// evict 淘汰最旧的条目直到满足预算，调用方需持有锁
+func (c *LRU) evict() {
+	for c.bytes > c.maxBytes && c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove 删除条目，调用方需持有锁
+func (c *LRU) remove(elem *list.Element) {
+	it := elem.Value.(*item)
+	c.lru.Remove(elem)
+	delete(c.items, it.key)
+	c.bytes -= it.size
+}
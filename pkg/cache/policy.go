@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheableStatus 默认可缓存的响应状态码
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// Directives 解析后的 Cache-Control 指令
+type Directives map[string]string
+
+// ParseCacheControl 解析 Cache-Control 头，指令名转为小写
+func ParseCacheControl(header http.Header) Directives {
+	d := make(Directives)
+	for _, line := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name == "" {
+				continue
+			}
+			d[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return d
+}
+
+// Has 判断是否包含指令
+func (d Directives) Has(name string) bool {
+	_, ok := d[name]
+	return ok
+}
+
+// Seconds 获取秒数指令的值
+func (d Directives) Seconds(name string) (time.Duration, bool) {
+	value, ok := d[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// Policy 服务的缓存策略
+type Policy struct {
+	TTL      time.Duration // 上游没有给出缓存时间时使用
+	ForceTTL bool          // 忽略上游的缓存头，总是按 TTL 缓存
+}
+
+// Freshness 计算响应的缓存时间，返回 false 表示不可存储。
+//
+// 按共享缓存的规则处理：no-store、private、Set-Cookie 和 Vary: * 的响应不存储；
+// 新鲜度依次取 s-maxage、max-age、Expires，都没有时使用 TTL；no-cache 或新鲜度为 0 的
+// 响应只有带校验器（ETag、Last-Modified）时才存储，每次使用前都需要重新验证
+func (p Policy) Freshness(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if !cacheableStatus[resp.StatusCode] {
+		return 0, false
+	}
+	if p.ForceTTL {
+		return p.TTL, p.TTL > 0
+	}
+
+	cc := ParseCacheControl(resp.Header)
+	if cc.Has("no-store") || cc.Has("private") || resp.Header.Get("Set-Cookie") != "" {
+		return 0, false
+	}
+	if strings.TrimSpace(resp.Header.Get("Vary")) == "*" {
+		return 0, false
+	}
+
+	ttl := p.TTL
+	switch {
+	case cc.Has("no-cache"):
+		ttl = 0
+	case cc.Has("s-maxage"):
+		ttl, _ = cc.Seconds("s-maxage")
+	case cc.Has("max-age"):
+		ttl, _ = cc.Seconds("max-age")
+	case resp.Header.Get("Expires") != "":
+		ttl = 0
+		if expires, err := http.ParseTime(resp.Header.Get("Expires")); err == nil {
+			date := now
+			if d, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+				date = d
+			}
+			if expires.After(date) {
+				ttl = expires.Sub(date)
+			}
+		}
+	}
+	// 扣除响应在上游缓存中已经停留的时间
+	if age, err := strconv.ParseInt(resp.Header.Get("Age"), 10, 64); err == nil && age > 0 {
+		ttl -= time.Duration(age) * time.Second
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	if ttl == 0 && resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "" {
+		return 0, false
+	}
+	return ttl, true
+}
+
+// RequestBypass 判断请求是否要求跳过缓存（no-store）
+func RequestBypass(header http.Header) bool {
+	return ParseCacheControl(header).Has("no-store")
+}
+
+// RequestRevalidate 判断请求是否要求使用前重新验证（no-cache、max-age=0、Pragma: no-cache）
+func RequestRevalidate(header http.Header) bool {
+	cc := ParseCacheControl(header)
+	if cc.Has("no-cache") || strings.EqualFold(header.Get("Pragma"), "no-cache") {
+		return true
+	}
+	maxAge, ok := cc.Seconds("max-age")
+	return ok && maxAge == 0
+}
+
+// VaryHeaders 获取响应 Vary 列出的请求头
+func VaryHeaders(header http.Header) []string {
+	var names []string
+	for _, line := range header.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
@@ -1,6 +1,8 @@
 package loadbalance
 
 import (
+	"fmt"
+	"math/rand"
 	"sync"
 	"sync/atomic"
 )
@@ -14,12 +16,41 @@ const (
 	WeightedRR                 // 加权轮询
 )
 
+// String 返回策略名称
+func (s Strategy) String() string {
+	switch s {
+	case RoundRobin:
+		return "round_robin"
+	case Random:
+		return "random"
+	case WeightedRR:
+		return "weighted_round_robin"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseStrategy 解析策略名称，空字符串视为轮询
+func ParseStrategy(name string) (Strategy, error) {
+	switch name {
+	case "", "round_robin":
+		return RoundRobin, nil
+	case "random":
+		return Random, nil
+	case "weighted_round_robin", "weighted":
+		return WeightedRR, nil
+	default:
+		return RoundRobin, fmt.Errorf("unknown load balance strategy: %s", name)
+	}
+}
+
 // Backend 后端服务器
 type Backend struct {
 	URL      string // 服务器地址
 	Weight   int    // 权重
 	Healthy  bool   // 健康状态
 	Active   int64  // 活跃连接数
+	Requests int64  // 累计请求数
 	Priority int    // 优先级
 }
 
@@ -30,6 +61,37 @@ type Balancer interface {
 	Next() *Backend
 	MarkDown(url string)
 	MarkUp(url string)
+	Backends() []Backend
+}
+
+// NewBalancer 根据策略创建负载均衡器
+func NewBalancer(strategy Strategy) Balancer {
+	switch strategy {
+	case Random:
+		return NewRandomBalancer()
+	case WeightedRR:
+		return NewWeightedRoundRobinBalancer()
+	default:
+		return NewRoundRobinBalancer()
+	}
+}
+
+// availableBackends 返回优先级最高（数值最小）的一组健康后端，
+// 只有当该组全部不可用时才会降级到下一优先级
+func availableBackends(backends []*Backend) []*Backend {
+	var available []*Backend
+	for _, backend := range backends {
+		if !backend.Healthy {
+			continue
+		}
+		switch {
+		case len(available) == 0 || backend.Priority < available[0].Priority:
+			available = append(available[:0], backend)
+		case backend.Priority == available[0].Priority:
+			available = append(available, backend)
+		}
+	}
+	return available
 }
 
 // RoundRobinBalancer 轮询负载均衡器
@@ -57,12 +119,7 @@ func (b *RoundRobinBalancer) Add(backend *Backend) {
 func (b *RoundRobinBalancer) Remove(url string) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	for i, backend := range b.backends {
-		if backend.URL == url {
-			b.backends = append(b.backends[:i], b.backends[i+1:]...)
-			return
-		}
-	}
+	b.backends = removeBackend(b.backends, url)
 }
 
 // Next 获取下一个后端服务器
@@ -70,18 +127,8 @@ func (b *RoundRobinBalancer) Next() *Backend {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	if len(b.backends) == 0 {
-		return nil
-	}
-
 	// 获取可用的后端服务器
-	var availableBackends []*Backend
-	for _, backend := range b.backends {
-		if backend.Healthy {
-			availableBackends = append(availableBackends, backend)
-		}
-	}
-
+	availableBackends := availableBackends(b.backends)
 	if len(availableBackends) == 0 {
 		return nil
 	}
@@ -96,21 +143,214 @@ func (b *RoundRobinBalancer) Next() *Backend {
 func (b *RoundRobinBalancer) MarkDown(url string) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	for _, backend := range b.backends {
+	setHealthy(b.backends, url, false)
+}
+
+// MarkUp 标记服务器为可用
+func (b *RoundRobinBalancer) MarkUp(url string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	setHealthy(b.backends, url, true)
+}
+
+// Backends 获取后端服务器状态快照
+func (b *RoundRobinBalancer) Backends() []Backend {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return snapshotBackends(b.backends)
+}
+
+// RandomBalancer 随机负载均衡器（按权重随机）
+type RandomBalancer struct {
+	backends []*Backend
+	mu       sync.RWMutex
+}
+
+// NewRandomBalancer 创建随机负载均衡器
+func NewRandomBalancer() *RandomBalancer {
+	return &RandomBalancer{
+		backends: make([]*Backend, 0),
+	}
+}
+
+// Add 添加后端服务器
+func (b *RandomBalancer) Add(backend *Backend) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.backends = append(b.backends, backend)
+}
+
+// Remove 移除后端服务器
+func (b *RandomBalancer) Remove(url string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.backends = removeBackend(b.backends, url)
+}
+
+// Next 按权重随机选择一个后端服务器
+func (b *RandomBalancer) Next() *Backend {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	availableBackends := availableBackends(b.backends)
+	if len(availableBackends) == 0 {
+		return nil
+	}
+
+	total := 0
+	for _, backend := range availableBackends {
+		total += effectiveWeight(backend)
+	}
+	n := rand.Intn(total)
+	for _, backend := range availableBackends {
+		n -= effectiveWeight(backend)
+		if n < 0 {
+			return backend
+		}
+	}
+	return availableBackends[len(availableBackends)-1]
+}
+
+// MarkDown 标记服务器为不可用
+func (b *RandomBalancer) MarkDown(url string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	setHealthy(b.backends, url, false)
+}
+
+// MarkUp 标记服务器为可用
+func (b *RandomBalancer) MarkUp(url string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	setHealthy(b.backends, url, true)
+}
+
+// Backends 获取后端服务器状态快照
+func (b *RandomBalancer) Backends() []Backend {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return snapshotBackends(b.backends)
+}
+
+// WeightedRoundRobinBalancer 平滑加权轮询负载均衡器
+type WeightedRoundRobinBalancer struct {
+	backends []*Backend
+	current  map[*Backend]int
+	mu       sync.Mutex
+}
+
+// NewWeightedRoundRobinBalancer 创建平滑加权轮询负载均衡器
+func NewWeightedRoundRobinBalancer() *WeightedRoundRobinBalancer {
+	return &WeightedRoundRobinBalancer{
+		backends: make([]*Backend, 0),
+		current:  make(map[*Backend]int),
+	}
+}
+
+// Add 添加后端服务器
+func (b *WeightedRoundRobinBalancer) Add(backend *Backend) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.backends = append(b.backends, backend)
+}
+
+// Remove 移除后端服务器
+func (b *WeightedRoundRobinBalancer) Remove(url string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for backend := range b.current {
 		if backend.URL == url {
-			backend.Healthy = false
-			return
+			delete(b.current, backend)
+		}
+	}
+	b.backends = removeBackend(b.backends, url)
+}
+
+// Next 获取下一个后端服务器
+func (b *WeightedRoundRobinBalancer) Next() *Backend {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	availableBackends := availableBackends(b.backends)
+	if len(availableBackends) == 0 {
+		return nil
+	}
+
+	// 每轮给所有后端加上自身权重，选出当前权重最大的后端后再减去总权重
+	var best *Backend
+	total := 0
+	for _, backend := range availableBackends {
+		weight := effectiveWeight(backend)
+		total += weight
+		b.current[backend] += weight
+		if best == nil || b.current[backend] > b.current[best] {
+			best = backend
 		}
 	}
+	b.current[best] -= total
+	return best
+}
+
+// MarkDown 标记服务器为不可用
+func (b *WeightedRoundRobinBalancer) MarkDown(url string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	setHealthy(b.backends, url, false)
 }
 
 // MarkUp 标记服务器为可用
-func (b *RoundRobinBalancer) MarkUp(url string) {
+func (b *WeightedRoundRobinBalancer) MarkUp(url string) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	for _, backend := range b.backends {
+	setHealthy(b.backends, url, true)
+}
+
+// Backends 获取后端服务器状态快照
+func (b *WeightedRoundRobinBalancer) Backends() []Backend {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return snapshotBackends(b.backends)
+}
+
+// snapshotBackends 复制后端状态，计数器使用原子读取
+func snapshotBackends(backends []*Backend) []Backend {
+	result := make([]Backend, 0, len(backends))
+	for _, backend := range backends {
+		result = append(result, Backend{
+			URL:      backend.URL,
+			Weight:   backend.Weight,
+			Healthy:  backend.Healthy,
+			Active:   atomic.LoadInt64(&backend.Active),
+			Requests: atomic.LoadInt64(&backend.Requests),
+			Priority: backend.Priority,
+		})
+	}
+	return result
+}
+
+// effectiveWeight 获取有效权重，未配置时视为 1
+func effectiveWeight(backend *Backend) int {
+	if backend.Weight <= 0 {
+		return 1
+	}
+	return backend.Weight
+}
+
+// removeBackend 从列表中移除指定地址的后端
+func removeBackend(backends []*Backend, url string) []*Backend {
+	for i, backend := range backends {
+		if backend.URL == url {
+			return append(backends[:i], backends[i+1:]...)
+		}
+	}
+	return backends
+}
+
+// setHealthy 设置指定地址后端的健康状态
+func setHealthy(backends []*Backend, url string, healthy bool) {
+	for _, backend := range backends {
 		if backend.URL == url {
-			backend.Healthy = true
+			backend.Healthy = healthy
 			return
 		}
 	}
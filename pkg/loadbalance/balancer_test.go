@@ -1,6 +1,7 @@
 package loadbalance
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -58,3 +59,101 @@ func TestRoundRobinBalancer(t *testing.T) {
 		t.Error("Expected nil after all servers removed")
 	}
 }
+
+func TestWeightedRoundRobinBalancer(t *testing.T) {
+	balancer := NewWeightedRoundRobinBalancer()
+	balancer.Add(&Backend{URL: "a", Weight: 3, Healthy: true})
+	balancer.Add(&Backend{URL: "b", Weight: 1, Healthy: true})
+
+	// 测试按权重分发
+	backends := make(map[string]int)
+	for i := 0; i < 8; i++ {
+		backends[balancer.Next().URL]++
+	}
+	if backends["a"] != 6 || backends["b"] != 2 {
+		t.Errorf("Weighted distribution is wrong: %v", backends)
+	}
+
+	// 测试标记不可用后只返回剩余后端
+	balancer.MarkDown("a")
+	if backend := balancer.Next(); backend.URL != "b" {
+		t.Errorf("Expected b after a marked down, got %s", backend.URL)
+	}
+}
+
+func TestBalancerPriority(t *testing.T) {
+	for _, strategy := range []Strategy{RoundRobin, Random, WeightedRR} {
+		balancer := NewBalancer(strategy)
+		balancer.Add(&Backend{URL: "primary", Weight: 1, Healthy: true, Priority: 0})
+		balancer.Add(&Backend{URL: "backup", Weight: 10, Healthy: true, Priority: 1})
+
+		// 测试优先使用高优先级后端
+		for i := 0; i < 10; i++ {
+			if backend := balancer.Next(); backend.URL != "primary" {
+				t.Fatalf("%s: expected primary backend, got %s", strategy, backend.URL)
+			}
+		}
+
+		// 测试高优先级不可用时降级
+		balancer.MarkDown("primary")
+		if backend := balancer.Next(); backend.URL != "backup" {
+			t.Errorf("%s: expected backup backend, got %s", strategy, backend.URL)
+		}
+
+		// 测试恢复后重新使用高优先级后端
+		balancer.MarkUp("primary")
+		if backend := balancer.Next(); backend.URL != "primary" {
+			t.Errorf("%s: expected primary backend after recovery, got %s", strategy, backend.URL)
+		}
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	tests := map[string]Strategy{
+		"":                     RoundRobin,
+		"round_robin":          RoundRobin,
+		"random":               Random,
+		"weighted_round_robin": WeightedRR,
+	}
+	for name, expected := range tests {
+		strategy, err := ParseStrategy(name)
+		if err != nil || strategy != expected {
+			t.Errorf("ParseStrategy(%q) = %v, %v; want %v", name, strategy, err, expected)
+		}
+	}
+	if _, err := ParseStrategy("least_conn"); err == nil {
+		t.Error("Expected error for unknown strategy")
+	}
+}
+
+func TestRendezvous(t *testing.T) {
+	backends := []*Backend{
+		{URL: "stable", Weight: 95, Healthy: true},
+		{URL: "canary", Weight: 5, Healthy: true},
+	}
+	counts := make(map[string]int)
+	assigned := make(map[string]string)
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("client-%d", i)
+		backend := Rendezvous(backends, key)
+		counts[backend.URL]++
+		assigned[key] = backend.URL
+	}
+	if counts["canary"] < 350 || counts["canary"] > 650 {
+		t.Errorf("Expected about 5%% canary traffic, got %v", counts)
+	}
+
+	// 同一个键的选择稳定，增加金丝雀权重时只有原本的稳定流量迁移到金丝雀
+	backends[0].Weight, backends[1].Weight = 80, 20
+	for key, url := range assigned {
+		if got := Rendezvous(backends, key).URL; url == "canary" && got != "canary" {
+			t.Fatalf("Key %s moved away from canary after its weight increased", key)
+		}
+	}
+
+	// 不可用的后端不参与选择
+	backends[1].Healthy = false
+	if got := Rendezvous(backends, "client-1"); got == nil || got.URL != "stable" {
+		t.Errorf("Expected stable backend, got %v", got)
+	}
+}
@@ -0,0 +1,38 @@
+package loadbalance
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// Rendezvous 按键在可用后端中做加权一致性选择（加权 rendezvous 哈希）。
+//
+// 同一个键总是选中同一个后端，各后端被选中的比例与权重成正比；
+// 调整权重或增删后端时，只有必须迁移的键才会改变选择
+func Rendezvous(backends []*Backend, key string) *Backend {
+	var best *Backend
+	var bestScore float64
+	for _, backend := range availableBackends(backends) {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(backend.URL))
+		// 将哈希值均匀映射到 (0, 1)，得分为 -w/ln(u)
+		u := (float64(mix64(h.Sum64())>>11) + 0.5) / (1 << 53)
+		score := -float64(effectiveWeight(backend)) / math.Log(u)
+		if best == nil || score > bestScore {
+			best, bestScore = backend, score
+		}
+	}
+	return best
+}
+
+// mix64 打散 FNV 哈希的高位（splitmix64 终结函数），相近的键也能得到独立的结果
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -21,7 +21,7 @@ var (
 			Name: "backend_health_status",
 			Help: "Backend health status (1 for healthy, 0 for unhealthy)",
 		},
-		[]string{"backend"},
+		[]string{"service", "backend"},
 	)
 
 	// 熔断器状态
@@ -30,7 +30,178 @@ var (
 			Name: "circuit_breaker_status",
 			Help: "Circuit breaker status (0: Closed, 1: Open, 2: Half-Open)",
 		},
-		[]string{"service"},
+		[]string{"service", "backend"},
+	)
+
+	// 客户端请求数
+	ClientRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "client_requests_total",
+			Help: "Total number of authenticated requests by client and service",
+		},
+		[]string{"client", "service"},
+	)
+
+	// 认证失败次数
+	AuthFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_failures_total",
+			Help: "Total number of rejected requests by reason (missing, invalid, forbidden)",
+		},
+		[]string{"reason"},
+	)
+
+	// 上游密钥请求数
+	UpstreamKeyRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "upstream_key_requests_total",
+			Help: "Total number of upstream requests by service, injected key and status",
+		},
+		[]string{"service", "key", "status"},
+	)
+
+	// 上游返回的 token 用量
+	TokenUsage = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "llm_tokens_total",
+			Help: "Total number of LLM tokens reported by upstream responses, by client, service, model and type (prompt, completion, cached)",
+		},
+		[]string{"client", "service", "model", "type"},
+	)
+
+	// 限流拒绝次数
+	RateLimitRejections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rate_limit_rejections_total",
+			Help: "Total number of requests rejected by rate limiting, by key class (e.g. ip, client+service)",
+		},
+		[]string{"class"},
+	)
+
+	// 配额耗尽拒绝次数
+	QuotaRejections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "quota_rejections_total",
+			Help: "Total number of requests rejected because a client quota was exhausted, by client and period (daily, monthly)",
+		},
+		[]string{"client", "period"},
+	)
+
+	// 限流存储错误次数
+	RateLimitStoreErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "rate_limit_store_errors_total",
+			Help: "Total number of rate limit checks that failed because the store was unavailable",
+		},
+	)
+
+	// 响应缓存请求结果
+	CacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "response_cache_requests_total",
+			Help: "Total number of cacheable requests by service and result (hit, miss, revalidated, bypass)",
+		},
+		[]string{"service", "result"},
+	)
+
+	// 响应缓存条目数
+	CacheEntries = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "response_cache_entries",
+			Help: "Number of responses currently held in the response cache",
+		},
+	)
+
+	// 响应缓存占用字节数
+	CacheBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "response_cache_bytes",
+			Help: "Approximate size in bytes of the responses held in the response cache",
+		},
+	)
+
+	// 请求合并结果
+	CoalescedRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "coalesced_requests_total",
+			Help: "Total number of requests that joined an identical in-flight request, by service and result (shared, fallback, overflow)",
+		},
+		[]string{"service", "result"},
+	)
+
+	// 模型路由结果
+	ModelRouteRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "model_route_requests_total",
+			Help: "Total number of model routed requests, by virtual service, target service and result (served, fallback)",
+		},
+		[]string{"router", "service", "result"},
+	)
+
+	// 路由分流请求数和耗时，用于比较各分流的错误率和延迟
+	RouteSplitRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "route_split_requests_total",
+			Help: "Total number of requests assigned to a traffic split, by route, split and status",
+		},
+		[]string{"route", "split", "status"},
+	)
+	RouteSplitDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "route_split_request_duration_seconds",
+			Help:    "Request duration of traffic splits in seconds, by route and split",
+			Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+		},
+		[]string{"route", "split"},
+	)
+
+	// 请求镜像结果和对比，用于在不影响用户的情况下评估新的上游
+	ShadowRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shadow_requests_total",
+			Help: "Total number of sampled shadow requests, by service, shadow service and result (completed, error, dropped)",
+		},
+		[]string{"service", "shadow", "result"},
+	)
+	ShadowComparisons = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shadow_comparisons_total",
+			Help: "Total number of primary and shadow response comparisons, by service, shadow service, status (match, mismatch) and body (match, mismatch, skipped)",
+		},
+		[]string{"service", "shadow", "status", "body"},
+	)
+	ShadowLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "shadow_request_duration_seconds",
+			Help:    "Request duration of mirrored requests in seconds, by service, shadow service and upstream (primary, shadow)",
+			Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+		},
+		[]string{"service", "shadow", "upstream"},
+	)
+
+	// 配置热加载次数
+	ConfigReloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "config_reloads_total",
+			Help: "Total number of configuration reloads by result (success, failure)",
+		},
+		[]string{"result"},
+	)
+
+	// 最近一次配置热加载是否成功
+	ConfigLastReloadSuccess = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "config_last_reload_successful",
+			Help: "Whether the last configuration reload succeeded (1 for success, 0 for failure)",
+		},
+	)
+
+	// 最近一次配置成功加载的时间
+	ConfigLastReloadTimestamp = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "config_last_reload_success_timestamp_seconds",
+			Help: "Timestamp of the last successful configuration reload",
+		},
 	)
 )
 
@@ -38,4 +209,24 @@ func init() {
 	prometheus.MustRegister(RequestLatency)
 	prometheus.MustRegister(BackendHealth)
 	prometheus.MustRegister(CircuitBreakerStatus)
+	prometheus.MustRegister(ClientRequests)
+	prometheus.MustRegister(AuthFailures)
+	prometheus.MustRegister(UpstreamKeyRequests)
+	prometheus.MustRegister(TokenUsage)
+	prometheus.MustRegister(RateLimitRejections)
+	prometheus.MustRegister(RateLimitStoreErrors)
+	prometheus.MustRegister(QuotaRejections)
+	prometheus.MustRegister(CacheRequests)
+	prometheus.MustRegister(CacheEntries)
+	prometheus.MustRegister(CacheBytes)
+	prometheus.MustRegister(CoalescedRequests)
+	prometheus.MustRegister(ModelRouteRequests)
+	prometheus.MustRegister(RouteSplitRequests)
+	prometheus.MustRegister(RouteSplitDuration)
+	prometheus.MustRegister(ShadowRequests)
+	prometheus.MustRegister(ShadowComparisons)
+	prometheus.MustRegister(ShadowLatency)
+	prometheus.MustRegister(ConfigReloads)
+	prometheus.MustRegister(ConfigLastReloadSuccess)
+	prometheus.MustRegister(ConfigLastReloadTimestamp)
 }
@@ -0,0 +1,250 @@
+package quota
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Amount 配额用量或上限，上限中的 0 表示不限制该项
+type Amount struct {
+	Requests int64   `mapstructure:"requests" json:"requests"`
+	Tokens   int64   `mapstructure:"tokens" json:"tokens"`
+	Cost     float64 `mapstructure:"cost" json:"cost"` // 估算费用（价格表中的货币单位）
+}
+
+// add 累加用量
+func (a *Amount) add(delta Amount) {
+	a.Requests += delta.Requests
+	a.Tokens += delta.Tokens
+	a.Cost += delta.Cost
+}
+
+// Exhausted 返回用量已达到上限的项
+func (a Amount) Exhausted(limit Amount) []string {
+	var items []string
+	if limit.Requests > 0 && a.Requests >= limit.Requests {
+		items = append(items, "requests")
+	}
+	if limit.Tokens > 0 && a.Tokens >= limit.Tokens {
+		items = append(items, "tokens")
+	}
+	if limit.Cost > 0 && a.Cost >= limit.Cost {
+		items = append(items, "cost")
+	}
+	return items
+}
+
+// Ratios 返回各项用量占上限的比例，未设置上限的项不返回
+func (a Amount) Ratios(limit Amount) map[string]float64 {
+	ratios := make(map[string]float64)
+	if limit.Requests > 0 {
+		ratios["requests"] = float64(a.Requests) / float64(limit.Requests)
+	}
+	if limit.Tokens > 0 {
+		ratios["tokens"] = float64(a.Tokens) / float64(limit.Tokens)
+	}
+	if limit.Cost > 0 {
+		ratios["cost"] = a.Cost / limit.Cost
+	}
+	return ratios
+}
+
+// Limits 每日和每月配额上限
+type Limits struct {
+	Daily   Amount
+	Monthly Amount
+}
+
+// Status 客户端当前周期的用量
+type Status struct {
+	Daily   Amount `json:"daily"`
+	Monthly Amount `json:"monthly"`
+}
+
+// counter 单个客户端的计数，周期变化时清零
+type counter struct {
+	Day     string `json:"day"`   // 2006-01-02
+	Month   string `json:"month"` // 2006-01
+	Daily   Amount `json:"daily"`
+	Monthly Amount `json:"monthly"`
+}
+
+// roll 切换到 now 所在的周期
+func (c *counter) roll(now time.Time) {
+	if day := now.Format("2006-01-02"); c.Day != day {
+		c.Day, c.Daily = day, Amount{}
+	}
+	if month := now.Format("2006-01"); c.Month != month {
+		c.Month, c.Monthly = month, Amount{}
+	}
+}
+
+// Store 按客户端统计每日、每月用量，定期持久化到本地文件。
+//
+// 周期按 UTC 自然日和自然月划分
+type Store struct {
+	file     string
+	mu       sync.Mutex
+	counters map[string]*counter
+	dirty    bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// Open 打开配额存储，file 为空时只保存在内存中
+func Open(file string) (*Store, error) {
+	s := &Store{file: file, counters: make(map[string]*counter), stop: make(chan struct{})}
+	if file == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.counters); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Reserve 在未超出配额时计入一次请求，返回计入后的用量；超出时不计入并返回已耗尽的周期
+func (s *Store) Reserve(client string, limits Limits, now time.Time) (Status, string, bool) {
+	now = now.UTC()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.counter(client, now)
+	if len(c.Daily.Exhausted(limits.Daily)) > 0 {
+		return Status{c.Daily, c.Monthly}, "daily", false
+	}
+	if len(c.Monthly.Exhausted(limits.Monthly)) > 0 {
+		return Status{c.Daily, c.Monthly}, "monthly", false
+	}
+	delta := Amount{Requests: 1}
+	c.Daily.add(delta)
+	c.Monthly.add(delta)
+	s.dirty = true
+	return Status{c.Daily, c.Monthly}, "", true
+}
+
+// Add 累加用量（响应完成后的 token 数和费用）
+func (s *Store) Add(client string, delta Amount, now time.Time) {
+	now = now.UTC()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.counter(client, now)
+	c.Daily.add(delta)
+	c.Monthly.add(delta)
+	s.dirty = true
+}
+
+// Get 获取客户端当前周期的用量
+func (s *Store) Get(client string, now time.Time) Status {
+	now = now.UTC()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.counter(client, now)
+	return Status{c.Daily, c.Monthly}
+}
+
+// counter 获取客户端计数并切换到当前周期，调用方需持有锁
+func (s *Store) counter(client string, now time.Time) *counter {
+	c, ok := s.counters[client]
+	if !ok {
+		c = &counter{}
+		s.counters[client] = c
+	}
+	c.roll(now)
+	return c
+}
+
+// Save 将计数写入文件，先写临时文件再重命名，避免中途崩溃损坏数据
+func (s *Store) Save() error {
+	if s.file == "" {
+		return nil
+	}
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(s.counters)
+	s.dirty = false
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := s.write(data); err != nil {
+		// 写入失败时保留脏标记，下次重试
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// write 写入文件
+func (s *Store) write(data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(s.file), 0755); err != nil {
+		return err
+	}
+	tmp := s.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.file)
+}
+
+// Start 按间隔定期保存
+func (s *Store) Start(interval time.Duration, onError func(error)) {
+	if s.file == "" || interval <= 0 {
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Save(); err != nil && onError != nil {
+					onError(err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止定期保存并写入最新计数
+func (s *Store) Close() error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	s.wg.Wait()
+	return s.Save()
+}
+
+// NextReset 返回周期的下一次重置时间
+func NextReset(period string, now time.Time) time.Time {
+	now = now.UTC()
+	year, month, day := now.Date()
+	if period == "monthly" {
+		return time.Date(year, month+1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC)
+}
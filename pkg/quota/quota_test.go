@@ -0,0 +1,77 @@
+package quota
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoreReserve(t *testing.T) {
+	store, _ := Open("")
+	limits := Limits{Daily: Amount{Requests: 2}, Monthly: Amount{Tokens: 100}}
+	now := time.Date(2024, 5, 31, 23, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		if _, _, ok := store.Reserve("team-a", limits, now); !ok {
+			t.Fatalf("Request %d should be allowed", i)
+		}
+	}
+	status, period, ok := store.Reserve("team-a", limits, now)
+	if ok || period != "daily" || status.Daily.Requests != 2 {
+		t.Errorf("Expected daily quota to be exhausted, got %+v %s %v", status, period, ok)
+	}
+
+	// 其他客户端不受影响
+	if _, _, ok := store.Reserve("team-b", limits, now); !ok {
+		t.Error("Expected other client to be allowed")
+	}
+
+	// token 用量计入每月配额
+	store.Add("team-a", Amount{Tokens: 100, Cost: 0.5}, now)
+	next := now.Add(2 * time.Hour) // 6 月 1 日，日和月周期同时切换
+	if _, _, ok := store.Reserve("team-a", limits, next); !ok {
+		t.Error("Expected counters to reset in the next day and month")
+	}
+	if status := store.Get("team-a", next); status.Daily.Requests != 1 || status.Monthly.Tokens != 0 {
+		t.Errorf("Unexpected status after rollover: %+v", status)
+	}
+
+	store.Add("team-a", Amount{Tokens: 100}, next)
+	if _, period, ok := store.Reserve("team-a", limits, next); ok || period != "monthly" {
+		t.Errorf("Expected monthly quota to be exhausted, got %s %v", period, ok)
+	}
+}
+
+func TestStorePersistence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "quotas.json")
+	now := time.Now()
+
+	store, err := Open(file)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	store.Reserve("team-a", Limits{}, now)
+	store.Add("team-a", Amount{Tokens: 42, Cost: 1.5}, now)
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	store, err = Open(file)
+	if err != nil {
+		t.Fatalf("Reopen: %v", err)
+	}
+	status := store.Get("team-a", now)
+	if status.Daily != (Amount{Requests: 1, Tokens: 42, Cost: 1.5}) || status.Monthly.Tokens != 42 {
+		t.Errorf("Counters not restored: %+v", status)
+	}
+}
+
+func TestNextReset(t *testing.T) {
+	now := time.Date(2024, 12, 31, 10, 0, 0, 0, time.UTC)
+	if got := NextReset("daily", now); !got.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected daily reset: %v", got)
+	}
+	if got := NextReset("monthly", now); !got.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected monthly reset: %v", got)
+	}
+}
@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// DefaultMaxKeys 内存限流器默认最多保留的键数量
+const DefaultMaxKeys = 10000
+
+// MemoryStore 进程内令牌桶限流，按最近最少使用淘汰空闲的键
+type MemoryStore struct {
+	maxKeys int
+	items   map[string]*list.Element
+	lru     *list.List
+	mu      sync.Mutex
+}
+
+// entry LRU 中的限流器
+type entry struct {
+	key     string
+	limiter *rate.Limiter
+}
+
+// NewMemoryStore 创建内存限流器，maxKeys <= 0 时使用默认值
+func NewMemoryStore(maxKeys int) *MemoryStore {
+	if maxKeys <= 0 {
+		maxKeys = DefaultMaxKeys
+	}
+	return &MemoryStore{
+		maxKeys: maxKeys,
+		items:   make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Allow 判断键是否允许一次请求
+func (s *MemoryStore) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := time.Now()
+	limiter := s.limiter(key, limit, now)
+	allowed := limiter.AllowN(now, 1)
+	return newResult(allowed, limit, limiter.TokensAt(now)), nil
+}
+
+// Len 当前保留的键数量
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// limiter 获取键对应的限流器，配置变化时更新速率和容量
+func (s *MemoryStore) limiter(key string, limit Limit, now time.Time) *rate.Limiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.items[key]; ok {
+		s.lru.MoveToFront(elem)
+		limiter := elem.Value.(*entry).limiter
+		if limiter.Limit() != rate.Limit(limit.Rate) {
+			limiter.SetLimitAt(now, rate.Limit(limit.Rate))
+		}
+		if limiter.Burst() != limit.Burst {
+			limiter.SetBurstAt(now, limit.Burst)
+		}
+		return limiter
+	}
+
+	limiter := rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst)
+	s.items[key] = s.lru.PushFront(&entry{key: key, limiter: limiter})
+	for s.lru.Len() > s.maxKeys {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.items, oldest.Value.(*entry).key)
+	}
+	return limiter
+}
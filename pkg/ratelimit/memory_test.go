@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(10)
+	limit := Limit{Rate: 1, Burst: 2}
+
+	// 突发容量用完后拒绝
+	for i := 0; i < 2; i++ {
+		if result := allow(store, "a", limit); !result.Allowed || result.Remaining != 1-i {
+			t.Errorf("Request %d: unexpected result %+v", i, result)
+		}
+	}
+	result := allow(store, "a", limit)
+	if result.Allowed {
+		t.Fatal("Expected request to be rejected")
+	}
+	if result.RetryAfter <= 0 || result.RetryAfter > time.Second {
+		t.Errorf("Unexpected retry after: %v", result.RetryAfter)
+	}
+	if result.ResetAfter <= time.Second || result.ResetAfter > 2*time.Second {
+		t.Errorf("Unexpected reset after: %v", result.ResetAfter)
+	}
+
+	// 不同键互不影响
+	if result := allow(store, "b", limit); !result.Allowed {
+		t.Error("Expected independent key to be allowed")
+	}
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	store := NewMemoryStore(2)
+	limit := Limit{Rate: 1, Burst: 1}
+
+	allow(store, "a", limit)
+	allow(store, "b", limit)
+	allow(store, "a", limit) // a 最近使用
+	allow(store, "c", limit) // 淘汰 b
+
+	if store.Len() != 2 {
+		t.Errorf("Expected 2 keys, got %d", store.Len())
+	}
+	if result := allow(store, "b", limit); !result.Allowed {
+		t.Error("Evicted key should start with a full bucket")
+	}
+	if result := allow(store, "c", limit); result.Allowed {
+		t.Error("Recently used key should keep its state")
+	}
+}
+
+func allow(store Store, key string, limit Limit) Result {
+	result, _ := store.Allow(context.Background(), key, limit)
+	return result
+}
@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Store 限流存储，按键维护限流状态。多实例部署时使用共享存储（如 Redis）
+// 使限流在所有实例间生效
+type Store interface {
+	// Allow 判断键是否允许一次请求，存储不可用时返回错误
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Limit 限流参数
+type Limit struct {
+	Rate  float64 // 每秒请求数
+	Burst int     // 突发容量
+}
+
+// Result 限流判定结果
+type Result struct {
+	Allowed    bool
+	Limit      int           // 突发容量
+	Remaining  int           // 剩余可用请求数
+	RetryAfter time.Duration // 被拒绝时距下一个可用令牌的时间
+	ResetAfter time.Duration // 令牌桶回满所需时间
+}
+
+// newResult 根据令牌桶中剩余的令牌数计算结果
+func newResult(allowed bool, limit Limit, tokens float64) Result {
+	result := Result{
+		Allowed: allowed,
+		Limit:   limit.Burst,
+	}
+	if tokens > 0 {
+		result.Remaining = int(math.Floor(tokens))
+	}
+	if limit.Rate <= 0 {
+		return result
+	}
+	if tokens < 1 {
+		result.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	if missing := float64(limit.Burst) - tokens; missing > 0 {
+		result.ResetAfter = seconds(missing / limit.Rate)
+	}
+	return result
+}
+
+// seconds 将秒数转换为时长
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,318 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RedisOptions Redis 限流存储配置
+type RedisOptions struct {
+	Addr     string        // host:port
+	Password string        // 为空时不认证
+	DB       int           // 数据库编号
+	Prefix   string        // 键前缀
+	Timeout  time.Duration // 连接、等待空闲连接和单次请求超时
+	PoolSize int           // 连接数上限，连接都在使用时等待空闲连接
+}
+
+// errPoolTimeout 等待空闲连接超时
+var errPoolTimeout = errors.New("redis: connection pool timeout")
+
+// slidingWindowScript 滑动窗口判定脚本，读取计数、判定和计数在 Redis 中原子执行，被拒绝的请求不计数。
+//
+// KEYS[1] 当前窗口，KEYS[2] 上一窗口；ARGV[1] 上一窗口计入的比例，ARGV[2] 上限，ARGV[3] 过期时间（毫秒）
+const slidingWindowScript = `
+local curr = tonumber(redis.call('GET', KEYS[1]) or '0')
+local prev = tonumber(redis.call('GET', KEYS[2]) or '0')
+if prev * tonumber(ARGV[1]) + curr + 1 > tonumber(ARGV[2]) then
+	return {0, prev, curr}
+end
+curr = redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return {1, prev, curr}
+`
+
+// RedisStore 基于 Redis 协议的滑动窗口限流，多个实例共享同一份计数。
+//
+// 以 burst/rate 为窗口长度、burst 为窗口内请求上限，按上一窗口计数的剩余比例
+// 加上当前窗口计数估算滑动窗口内的请求数。每次判定通过一条 EVAL 原子执行，
+// 并发请求不会同时越过上限
+type RedisStore struct {
+	opts  RedisOptions
+	conns chan *redisConn // 空闲连接
+	slots chan struct{}   // 已建立的连接，容量为连接数上限
+}
+
+// NewRedisStore 创建 Redis 限流存储，连接在首次使用时建立
+func NewRedisStore(opts RedisOptions) *RedisStore {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 100 * time.Millisecond
+	}
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 16
+	}
+	return &RedisStore{
+		opts:  opts,
+		conns: make(chan *redisConn, opts.PoolSize),
+		slots: make(chan struct{}, opts.PoolSize),
+	}
+}
+
+// Allow 判断键是否允许一次请求
+func (s *RedisStore) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if limit.Rate <= 0 || limit.Burst <= 0 {
+		return Result{Limit: limit.Burst}, nil
+	}
+
+	window := time.Duration(float64(limit.Burst) / limit.Rate * float64(time.Second))
+	if window < time.Millisecond {
+		window = time.Millisecond
+	}
+	now := time.Now()
+	index := now.UnixNano() / int64(window)
+	elapsed := float64(now.UnixNano()%int64(window)) / float64(window)
+
+	current := s.opts.Prefix + key + ":" + strconv.FormatInt(index, 10)
+	previous := s.opts.Prefix + key + ":" + strconv.FormatInt(index-1, 10)
+	ttl := strconv.FormatInt((2*window).Milliseconds()+1, 10)
+
+	// 滑动窗口估算：上一窗口按剩余时间比例计入
+	replies, err := s.do(ctx, []string{"EVAL", slidingWindowScript, "2", current, previous,
+		strconv.FormatFloat(1-elapsed, 'f', -1, 64), strconv.Itoa(limit.Burst), ttl})
+	if err != nil {
+		return Result{}, err
+	}
+	reply, _ := replies[0].([]interface{})
+	if len(reply) != 3 {
+		return Result{}, fmt.Errorf("redis: unexpected script reply %v", replies[0])
+	}
+	allowed, _ := reply[0].(int64)
+	prev, _ := reply[1].(int64)
+	curr, _ := reply[2].(int64)
+
+	count := float64(prev)*(1-elapsed) + float64(curr)
+	if allowed != 1 {
+		result := newResult(false, limit, float64(limit.Burst)-count)
+		result.RetryAfter = retryAfter(window, elapsed, prev, curr, limit.Burst)
+		return result, nil
+	}
+	return newResult(true, limit, float64(limit.Burst)-count), nil
+}
+
+// retryAfter 估算滑动窗口内请求数降到上限以下所需的时间
+func retryAfter(window time.Duration, elapsed float64, prev, curr int64, burst int) time.Duration {
+	remaining := time.Duration((1 - elapsed) * float64(window))
+	if prev == 0 || curr >= int64(burst) {
+		return remaining
+	}
+	// prev*(1-elapsed-t/window) + curr <= burst-1
+	t := (1 - elapsed - float64(int64(burst)-1-curr)/float64(prev)) * float64(window)
+	if t <= 0 {
+		return time.Millisecond
+	}
+	if d := time.Duration(t); d < remaining {
+		return d
+	}
+	return remaining
+}
+
+// do 以流水线方式执行命令
+func (s *RedisStore) do(ctx context.Context, cmds ...[]string) ([]interface{}, error) {
+	conn, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(s.opts.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	replies, err := conn.pipeline(cmds)
+	if err != nil {
+		var redisErr redisError
+		if !errors.As(err, &redisErr) {
+			s.discard(conn)
+			return nil, err
+		}
+	}
+	s.put(conn)
+	return replies, err
+}
+
+// get 从连接池获取连接：优先使用空闲连接，未达到连接数上限时新建，否则等待空闲连接
+func (s *RedisStore) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-s.conns:
+		return conn, nil
+	default:
+	}
+
+	timer := time.NewTimer(s.opts.Timeout)
+	defer timer.Stop()
+	select {
+	case conn := <-s.conns:
+		return conn, nil
+	case s.slots <- struct{}{}:
+	case <-timer.C:
+		return nil, errPoolTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	conn, err := s.dial(ctx)
+	if err != nil {
+		<-s.slots
+		return nil, err
+	}
+	return conn, nil
+}
+
+// dial 建立新连接并完成认证和选择数据库
+func (s *RedisStore) dial(ctx context.Context) (*redisConn, error) {
+	dialer := net.Dialer{Timeout: s.opts.Timeout}
+	nc, err := dialer.DialContext(ctx, "tcp", s.opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: nc, r: bufio.NewReader(nc)}
+	conn.SetDeadline(time.Now().Add(s.opts.Timeout))
+
+	var setup [][]string
+	if s.opts.Password != "" {
+		setup = append(setup, []string{"AUTH", s.opts.Password})
+	}
+	if s.opts.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(s.opts.DB)})
+	}
+	if len(setup) > 0 {
+		if _, err := conn.pipeline(setup); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// put 归还连接，连接总数不超过空闲连接池容量
+func (s *RedisStore) put(conn *redisConn) {
+	select {
+	case s.conns <- conn:
+	default:
+		s.discard(conn)
+	}
+}
+
+// discard 关闭出错的连接并释放连接数
+func (s *RedisStore) discard(conn *redisConn) {
+	conn.Close()
+	<-s.slots
+}
+
+// Close 关闭所有空闲连接
+func (s *RedisStore) Close() error {
+	for {
+		select {
+		case conn := <-s.conns:
+			s.discard(conn)
+		default:
+			return nil
+		}
+	}
+}
+
+// redisError Redis 返回的错误回复
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// redisConn RESP 协议连接
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// pipeline 发送多条命令并依次读取回复，返回第一条错误回复
+func (c *redisConn) pipeline(cmds [][]string) ([]interface{}, error) {
+	var buf strings.Builder
+	for _, cmd := range cmds {
+		fmt.Fprintf(&buf, "*%d\r\n", len(cmd))
+		for _, arg := range cmd {
+			fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if _, err := c.Write([]byte(buf.String())); err != nil {
+		return nil, err
+	}
+
+	replies := make([]interface{}, len(cmds))
+	var firstErr error
+	for i := range cmds {
+		reply, err := c.readReply()
+		if err != nil {
+			if _, ok := err.(redisError); !ok {
+				return nil, err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		replies[i] = reply
+	}
+	return replies, firstErr
+}
+
+// readReply 读取一条回复：简单字符串和批量字符串返回 string，整数返回 int64，
+// 空值返回 nil，数组返回 []interface{}
+func (c *redisConn) readReply() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
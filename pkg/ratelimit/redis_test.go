@@ -0,0 +1,204 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeRedis 实现限流所需命令的最小 RESP 服务器
+type fakeRedis struct {
+	listener net.Listener
+	password string
+	data     map[string]int64
+	conns    int32 // 已接受的连接数
+	mu       sync.Mutex
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeRedis{listener: ln, password: password, data: make(map[string]int64)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&s.conns, 1)
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := s.password == ""
+	for {
+		cmd, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		name := strings.ToUpper(cmd[0])
+		if !authed && name != "AUTH" {
+			io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+
+		s.mu.Lock()
+		switch name {
+		case "AUTH":
+			if cmd[1] == s.password {
+				authed = true
+				io.WriteString(conn, "+OK\r\n")
+			} else {
+				io.WriteString(conn, "-WRONGPASS invalid password\r\n")
+			}
+		case "SELECT", "PEXPIRE":
+			io.WriteString(conn, ":1\r\n")
+		case "INCR":
+			s.data[cmd[1]]++
+			fmt.Fprintf(conn, ":%d\r\n", s.data[cmd[1]])
+		case "DECR":
+			s.data[cmd[1]]--
+			fmt.Fprintf(conn, ":%d\r\n", s.data[cmd[1]])
+		case "GET":
+			if v, ok := s.data[cmd[1]]; ok {
+				value := strconv.FormatInt(v, 10)
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(value), value)
+			} else {
+				io.WriteString(conn, "$-1\r\n")
+			}
+		case "EVAL":
+			// 按 slidingWindowScript 的语义执行
+			curr, prev := s.data[cmd[3]], s.data[cmd[4]]
+			ratio, _ := strconv.ParseFloat(cmd[5], 64)
+			limit, _ := strconv.ParseFloat(cmd[6], 64)
+			allowed := 0
+			if float64(prev)*ratio+float64(curr)+1 <= limit {
+				s.data[cmd[3]]++
+				curr, allowed = s.data[cmd[3]], 1
+			}
+			fmt.Fprintf(conn, "*3\r\n:%d\r\n:%d\r\n:%d\r\n", allowed, prev, curr)
+		default:
+			io.WriteString(conn, "-ERR unknown command\r\n")
+		}
+		s.mu.Unlock()
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(header[1:]))
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+func TestRedisStoreSharedAcrossInstances(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	opts := RedisOptions{Addr: server.listener.Addr().String(), Password: "secret", DB: 1, Prefix: "test:"}
+
+	// 两个实例共享同一份计数
+	a, b := NewRedisStore(opts), NewRedisStore(opts)
+	defer a.Close()
+	defer b.Close()
+
+	ctx := context.Background()
+	limit := Limit{Rate: 0.01, Burst: 4}
+	allowed := 0
+	for i := 0; i < 6; i++ {
+		store := a
+		if i%2 == 1 {
+			store = b
+		}
+		result, err := store.Allow(ctx, "client", limit)
+		if err != nil {
+			t.Fatalf("Allow failed: %v", err)
+		}
+		if result.Allowed {
+			allowed++
+		} else if result.RetryAfter <= 0 {
+			t.Errorf("Expected retry after for rejected request: %+v", result)
+		}
+	}
+	// 窗口边界可能带入上一窗口的计数，最多允许 4 个
+	if allowed == 0 || allowed > 4 {
+		t.Errorf("Expected at most 4 requests across instances, got %d", allowed)
+	}
+}
+
+func TestRedisStoreErrors(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+
+	// 密码错误
+	store := NewRedisStore(RedisOptions{Addr: server.listener.Addr().String(), Password: "wrong"})
+	if _, err := store.Allow(context.Background(), "k", Limit{Rate: 1, Burst: 1}); err == nil {
+		t.Error("Expected auth error")
+	}
+
+	// 无法连接
+	store = NewRedisStore(RedisOptions{Addr: "127.0.0.1:1", Timeout: 50 * time.Millisecond})
+	if _, err := store.Allow(context.Background(), "k", Limit{Rate: 1, Burst: 1}); err == nil {
+		t.Error("Expected connection error")
+	}
+}
+
+func TestRedisStoreConcurrent(t *testing.T) {
+	server := newFakeRedis(t, "")
+	store := NewRedisStore(RedisOptions{Addr: server.listener.Addr().String(), PoolSize: 2, Timeout: time.Second})
+	defer store.Close()
+
+	// 判定和计数原子执行，并发请求不会越过上限；连接数不超过连接池上限
+	limit := Limit{Rate: 0.001, Burst: 5}
+	var allowed int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := store.Allow(context.Background(), "client", limit)
+			if err != nil {
+				t.Errorf("Allow failed: %v", err)
+				return
+			}
+			if result.Allowed {
+				atomic.AddInt32(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed == 0 || allowed > 5 {
+		t.Errorf("Expected at most 5 concurrent requests, got %d", allowed)
+	}
+	if conns := atomic.LoadInt32(&server.conns); conns > 2 {
+		t.Errorf("Expected at most 2 connections, got %d", conns)
+	}
+}
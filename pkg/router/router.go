@@ -0,0 +1,356 @@
+package router
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"sub-router/pkg/loadbalance"
+)
+
+// Spec 声明式路由规则，所有条件都满足时匹配
+type Spec struct {
+	Name        string            `mapstructure:"name"`
+	Service     string            `mapstructure:"service"`      // 目标服务：api_mappings 或 model_routing 中的名称
+	Hosts       []string          `mapstructure:"hosts"`        // 主机名，支持 *.example.com 匹配任意子域名；为空时匹配所有主机
+	PathPrefix  string            `mapstructure:"path_prefix"`  // 路径前缀，按路径段匹配：/openai 匹配 /openai 和 /openai/...
+	PathRegex   string            `mapstructure:"path_regex"`   // 路径正则表达式
+	Methods     []string          `mapstructure:"methods"`      // 请求方法
+	Headers     map[string]string `mapstructure:"headers"`      // 请求头取值需完全相等
+	Priority    int               `mapstructure:"priority"`     // 数值越小越先匹配，相同时按配置顺序
+	StripPrefix string            `mapstructure:"strip_prefix"` // 转发前从路径中移除的前缀
+	AddPrefix   string            `mapstructure:"add_prefix"`   // 转发前在路径前添加的前缀
+
+	// Splits 按权重在多个服务之间分配流量，配置后代替 service
+	Splits []SplitSpec `mapstructure:"splits"`
+	// Sticky 粘性分配的键：client（客户端凭证，没有时使用 IP）、ip 或 header:<请求头>；为空时按权重轮询
+	Sticky string `mapstructure:"sticky"`
+	// OverrideHeader 强制选择分流的请求头，取值为分流名称，用于测试；为空时不允许强制选择
+	OverrideHeader string `mapstructure:"override_header"`
+}
+
+// SplitSpec 流量分配
+type SplitSpec struct {
+	Name    string `mapstructure:"name"`    // 分流名称，用于指标和强制选择，默认为服务名
+	Service string `mapstructure:"service"` // 目标服务
+	Weight  int    `mapstructure:"weight"`  // 权重，为 0 时只接收强制选择的请求
+}
+
+// Route 编译后的路由规则
+type Route struct {
+	Name    string
+	Service string // 目标服务，配置分流时为第一个分流（主服务）的服务
+
+	rank        int // 排序后的位置，越小越先匹配
+	prefix      string
+	pattern     *regexp.Regexp
+	methods     []string
+	headers     map[string]string
+	stripPrefix string
+	addPrefix   string
+
+	splits   map[string]*Split
+	backends []*loadbalance.Backend // 每个分流对应一个后端，URL 为分流名称
+	balancer loadbalance.Balancer
+	sticky   string // client、ip 或请求头名称
+	override string
+}
+
+// Split 编译后的流量分配
+type Split struct {
+	Name    string
+	Service string
+}
+
+// Table 编译后的路由表。
+//
+// 规则按主机名建立索引：精确主机名和通配子域名通过 map 查找，只需检查与请求主机相关的规则
+type Table struct {
+	exact    map[string][]*Route // 精确主机名
+	wildcard map[string][]*Route // 通配子域名，键为 .example.com
+	any      []*Route            // 不限主机名
+	size     int
+}
+
+// Compile 校验并编译路由规则
+func Compile(specs []Spec) (*Table, error) {
+	routes := make([]*Route, 0, len(specs))
+	for i, spec := range specs {
+		route, err := compileRoute(spec)
+		if err != nil {
+			return nil, fmt.Errorf("routes[%d]: %w", i, err)
+		}
+		if route.Name == "" {
+			route.Name = fmt.Sprintf("route-%d", i)
+		}
+		routes = append(routes, route)
+	}
+	order := make([]int, len(specs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return specs[order[a]].Priority < specs[order[b]].Priority
+	})
+
+	t := &Table{exact: make(map[string][]*Route), wildcard: make(map[string][]*Route), size: len(specs)}
+	for rank, i := range order {
+		route := routes[i]
+		route.rank = rank
+		if len(specs[i].Hosts) == 0 {
+			t.any = append(t.any, route)
+			continue
+		}
+		for _, host := range specs[i].Hosts {
+			host = strings.ToLower(host)
+			if suffix, ok := strings.CutPrefix(host, "*"); ok {
+				t.wildcard[suffix] = append(t.wildcard[suffix], route)
+			} else {
+				t.exact[host] = append(t.exact[host], route)
+			}
+		}
+	}
+	return t, nil
+}
+
+// compileRoute 编译单条规则
+func compileRoute(spec Spec) (*Route, error) {
+	if spec.Service == "" && len(spec.Splits) == 0 {
+		return nil, fmt.Errorf("service or splits is required")
+	}
+	if spec.Service != "" && len(spec.Splits) > 0 {
+		return nil, fmt.Errorf("service and splits are mutually exclusive")
+	}
+	route := &Route{
+		Name:        spec.Name,
+		Service:     spec.Service,
+		prefix:      spec.PathPrefix,
+		headers:     spec.Headers,
+		stripPrefix: spec.StripPrefix,
+		addPrefix:   strings.TrimSuffix(spec.AddPrefix, "/"),
+	}
+	if route.Name == "" {
+		route.Name = spec.Service
+	}
+	for _, host := range spec.Hosts {
+		if host == "" || strings.Contains(host[1:], "*") || (strings.HasPrefix(host, "*") && !strings.HasPrefix(host, "*.")) {
+			return nil, fmt.Errorf("invalid host %q", host)
+		}
+	}
+	for _, p := range []string{spec.PathPrefix, spec.StripPrefix, spec.AddPrefix} {
+		if p != "" && !strings.HasPrefix(p, "/") {
+			return nil, fmt.Errorf("path prefix %q must start with /", p)
+		}
+	}
+	if spec.PathRegex != "" {
+		re, err := regexp.Compile(spec.PathRegex)
+		if err != nil {
+			return nil, fmt.Errorf("path_regex: %w", err)
+		}
+		route.pattern = re
+	}
+	for _, m := range spec.Methods {
+		route.methods = append(route.methods, strings.ToUpper(m))
+	}
+	if len(spec.Splits) > 0 {
+		if err := route.compileSplits(spec); err != nil {
+			return nil, err
+		}
+	}
+	return route, nil
+}
+
+// compileSplits 编译流量分配，每个分流作为一个后端交给加权负载均衡选择
+func (r *Route) compileSplits(spec Spec) error {
+	switch header, isHeader := strings.CutPrefix(spec.Sticky, "header:"); {
+	case spec.Sticky == "" || spec.Sticky == "client" || spec.Sticky == "ip":
+		r.sticky = spec.Sticky
+	case isHeader && header != "":
+		r.sticky = http.CanonicalHeaderKey(header)
+	default:
+		return fmt.Errorf("invalid sticky %q", spec.Sticky)
+	}
+	r.override = spec.OverrideHeader
+
+	r.splits = make(map[string]*Split)
+	r.balancer = loadbalance.NewBalancer(loadbalance.WeightedRR)
+	total := 0
+	for i, s := range spec.Splits {
+		split := &Split{Name: s.Name, Service: s.Service}
+		if split.Name == "" {
+			split.Name = s.Service
+		}
+		if s.Service == "" || s.Weight < 0 {
+			return fmt.Errorf("splits[%d]: service and a non-negative weight are required", i)
+		}
+		if _, ok := r.splits[split.Name]; ok {
+			return fmt.Errorf("splits[%d]: duplicate split %q", i, split.Name)
+		}
+		r.splits[split.Name] = split
+		total += s.Weight
+		if i == 0 {
+			r.Service = split.Service
+		}
+
+		// 权重为 0 的分流标记为不可用，不参与按权重选择
+		backend := &loadbalance.Backend{URL: split.Name, Weight: s.Weight, Healthy: s.Weight > 0}
+		r.backends = append(r.backends, backend)
+		r.balancer.Add(backend)
+	}
+	if total == 0 {
+		return fmt.Errorf("splits: total weight must be positive")
+	}
+	return nil
+}
+
+// Splits 返回分流列表，没有配置分流时为空
+func (r *Route) Splits() []Split {
+	splits := make([]Split, 0, len(r.backends))
+	for _, backend := range r.backends {
+		splits = append(splits, *r.splits[backend.URL])
+	}
+	return splits
+}
+
+// Split 为请求选择分流：优先使用强制选择的请求头，其次按粘性键一致性选择，否则按权重轮询。
+// clientIP 用于 ip 粘性以及没有客户端凭证时的 client 粘性；没有配置分流时返回 nil
+func (r *Route) Split(req *http.Request, clientIP string) *Split {
+	if r.splits == nil {
+		return nil
+	}
+	if split := r.Override(req); split != nil {
+		return split
+	}
+
+	var key string
+	switch r.sticky {
+	case "":
+	case "client":
+		key = clientKey(req)
+		if key == "" {
+			key = clientIP
+		}
+	case "ip":
+		key = clientIP
+	default:
+		key = req.Header.Get(r.sticky)
+	}
+	var backend *loadbalance.Backend
+	if key != "" {
+		backend = loadbalance.Rendezvous(r.backends, key)
+	} else {
+		backend = r.balancer.Next()
+	}
+	return r.splits[backend.URL]
+}
+
+// Override 返回请求通过 override_header 强制选择的分流，没有强制选择时返回 nil
+func (r *Route) Override(req *http.Request) *Split {
+	if r.override == "" {
+		return nil
+	}
+	return r.splits[req.Header.Get(r.override)]
+}
+
+// clientKey 获取客户端凭证，同一个 API 密钥或用户的请求分配到同一个分流
+func clientKey(req *http.Request) string {
+	for _, name := range []string{"X-Api-Key", "Authorization"} {
+		if value := req.Header.Get(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Len 返回规则数量
+func (t *Table) Len() int {
+	return t.size
+}
+
+// Match 查找请求匹配的第一条规则，返回规则和转发到服务的相对路径
+func (t *Table) Match(req *http.Request) (*Route, string, bool) {
+	host := requestHost(req)
+	candidates := [][]*Route{t.exact[host], t.any}
+	// 依次检查 a.b.example.com 的 .b.example.com、.example.com、.com
+	for i := strings.IndexByte(host, '.'); i >= 0; {
+		candidates = append(candidates, t.wildcard[host[i:]])
+		next := strings.IndexByte(host[i+1:], '.')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+
+	// 每组规则已按优先级排序，取各组第一条匹配规则中最靠前的
+	var best *Route
+	for _, routes := range candidates {
+		for _, route := range routes {
+			if best != nil && route.rank > best.rank {
+				break
+			}
+			if route.matches(req) {
+				best = route
+				break
+			}
+		}
+	}
+	if best == nil {
+		return nil, "", false
+	}
+	return best, best.rewrite(req.URL.Path), true
+}
+
+// matches 判断请求是否满足规则的路径、方法和请求头条件
+func (r *Route) matches(req *http.Request) bool {
+	path := req.URL.Path
+	if r.prefix != "" && !hasPathPrefix(path, r.prefix) {
+		return false
+	}
+	if r.pattern != nil && !r.pattern.MatchString(path) {
+		return false
+	}
+	if len(r.methods) > 0 && !slices.Contains(r.methods, req.Method) {
+		return false
+	}
+	for k, v := range r.headers {
+		if req.Header.Get(k) != v {
+			return false
+		}
+	}
+	return true
+}
+
+// rewrite 移除和添加路径前缀
+func (r *Route) rewrite(path string) string {
+	if r.stripPrefix != "" && hasPathPrefix(path, r.stripPrefix) {
+		path = strings.TrimPrefix(path, strings.TrimSuffix(r.stripPrefix, "/"))
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+	}
+	if r.addPrefix != "" {
+		path = r.addPrefix + path
+	}
+	return path
+}
+
+// hasPathPrefix 按路径段判断前缀，以 / 结尾的前缀按字符串前缀判断
+func hasPathPrefix(path, prefix string) bool {
+	if strings.HasSuffix(prefix, "/") {
+		return strings.HasPrefix(path, prefix)
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// requestHost 获取请求的主机名（不含端口，小写）
+func requestHost(req *http.Request) string {
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
@@ -0,0 +1,133 @@
+package router
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTableMatch(t *testing.T) {
+	table, err := Compile([]Spec{
+		{Name: "internal-openai", Service: "openai", Hosts: []string{"api.internal"}, PathPrefix: "/openai", StripPrefix: "/openai"},
+		{Name: "openai-host", Service: "openai", Hosts: []string{"openai.internal"}},
+		{Name: "team", Service: "openai-team", Hosts: []string{"*.internal"}, Headers: map[string]string{"X-Team": "search"}, Priority: -1},
+		{Name: "models", Service: "openai", PathRegex: `^/v\d+/models$`, Methods: []string{"get"}, AddPrefix: "/v1/"},
+		{Name: "fallback", Service: "groq", PathPrefix: "/", Priority: 10},
+	})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	tests := []struct {
+		method, host, path string
+		header             string
+		route, wantPath    string
+	}{
+		{"POST", "api.internal", "/openai/v1/chat", "", "internal-openai", "/v1/chat"},
+		{"POST", "api.internal:8080", "/openai", "", "internal-openai", "/"},
+		{"POST", "api.internal", "/openaix/v1", "", "fallback", "/openaix/v1"},
+		{"POST", "OpenAI.Internal", "/v1/chat", "", "openai-host", "/v1/chat"},
+		{"POST", "openai.internal", "/v1/chat", "search", "team", "/v1/chat"},
+		{"POST", "a.b.internal", "/v1/chat", "search", "team", "/v1/chat"},
+		{"GET", "other.example", "/v2/models", "", "models", "/v1/v2/models"},
+		{"POST", "other.example", "/v2/models", "", "fallback", "/v2/models"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Host = tt.host
+		if tt.header != "" {
+			req.Header.Set("X-Team", tt.header)
+		}
+		route, path, ok := table.Match(req)
+		if !ok || route.Name != tt.route || path != tt.wantPath {
+			name := ""
+			if route != nil {
+				name = route.Name
+			}
+			t.Errorf("%s %s%s: expected %s %s, got %s %s (%v)", tt.method, tt.host, tt.path, tt.route, tt.wantPath, name, path, ok)
+		}
+	}
+
+	// 通配子域名不匹配顶级域名本身
+	table, _ = Compile([]Spec{{Service: "a", Hosts: []string{"*.internal"}}})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "internal"
+	if _, _, ok := table.Match(req); ok {
+		t.Error("Expected *.internal not to match internal")
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	invalid := []Spec{
+		{},
+		{Service: "a", Hosts: []string{"a.*.com"}},
+		{Service: "a", Hosts: []string{"*internal"}},
+		{Service: "a", PathPrefix: "openai"},
+		{Service: "a", PathRegex: "("},
+	}
+	for _, spec := range invalid {
+		if _, err := Compile([]Spec{spec}); err == nil {
+			t.Errorf("Expected error for %+v", spec)
+		}
+	}
+}
+
+func TestRouteSplit(t *testing.T) {
+	table, err := Compile([]Spec{{
+		PathPrefix: "/",
+		Splits: []SplitSpec{
+			{Service: "openai", Weight: 95},
+			{Name: "mirror", Service: "openrouter", Weight: 5},
+			{Name: "next", Service: "openai-next"},
+		},
+		Sticky:         "header:x-user",
+		OverrideHeader: "X-Split",
+	}})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	route, _, _ := table.Match(httptest.NewRequest("GET", "/v1/models", nil))
+	if route.Name != "route-0" || route.Service != "openai" || len(route.Splits()) != 3 {
+		t.Fatalf("Unexpected route %s %s %v", route.Name, route.Service, route.Splits())
+	}
+
+	// 没有粘性键时按权重轮询，权重为 0 的分流不参与
+	counts := make(map[string]int)
+	for i := 0; i < 100; i++ {
+		counts[route.Split(httptest.NewRequest("GET", "/", nil), "").Name]++
+	}
+	if counts["openai"] != 95 || counts["mirror"] != 5 || counts["next"] != 0 {
+		t.Errorf("Unexpected weighted split %v", counts)
+	}
+
+	// 同一个用户总是分配到同一个分流
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-User", "alice")
+	first := route.Split(req, "")
+	for i := 0; i < 10; i++ {
+		if got := route.Split(req, "10.0.0.1"); got != first {
+			t.Fatalf("Expected sticky split %s, got %s", first.Name, got.Name)
+		}
+	}
+
+	// 强制选择包括权重为 0 的分流，未知名称时忽略
+	req.Header.Set("X-Split", "next")
+	if got := route.Split(req, ""); got.Service != "openai-next" {
+		t.Errorf("Expected forced split, got %s", got.Name)
+	}
+	req.Header.Set("X-Split", "unknown")
+	if got := route.Split(req, ""); got != first {
+		t.Errorf("Expected unknown override to be ignored, got %s", got.Name)
+	}
+
+	invalid := []Spec{
+		{Service: "a", Splits: []SplitSpec{{Service: "b", Weight: 1}}},
+		{Splits: []SplitSpec{{Service: "b"}}},
+		{Splits: []SplitSpec{{Service: "b", Weight: 1}, {Service: "b", Weight: 1}}},
+		{Splits: []SplitSpec{{Service: "b", Weight: 1}}, Sticky: "cookie"},
+	}
+	for _, spec := range invalid {
+		if _, err := Compile([]Spec{spec}); err == nil {
+			t.Errorf("Expected error for %+v", spec)
+		}
+	}
+}
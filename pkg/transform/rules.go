@@ -0,0 +1,438 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxBodySize 改写 JSON 请求体、响应体时最多读取的字节数，超出时原样转发
+const DefaultMaxBodySize = 8 << 20
+
+// Spec 声明式转换规则，对应配置中 api_mappings.<service>.transforms 的一项
+type Spec struct {
+	Match    MatchSpec    `mapstructure:"match"`
+	Request  RequestSpec  `mapstructure:"request"`
+	Response ResponseSpec `mapstructure:"response"`
+}
+
+// MatchSpec 规则匹配条件，均为空时匹配服务的所有请求
+type MatchSpec struct {
+	Path    string            `mapstructure:"path"`    // 服务内相对路径的正则表达式
+	Methods []string          `mapstructure:"methods"` // 请求方法
+	Headers map[string]string `mapstructure:"headers"` // 请求头取值需完全相等
+}
+
+// RequestSpec 请求转换，按路径、查询参数、请求头、请求体的顺序执行
+type RequestSpec struct {
+	Path    []PathRewrite `mapstructure:"path"`
+	Query   QuerySpec     `mapstructure:"query"`
+	Headers HeaderSpec    `mapstructure:"headers"`
+	Body    BodySpec      `mapstructure:"body"`
+}
+
+// ResponseSpec 响应转换，按状态码、响应头、响应体的顺序执行
+type ResponseSpec struct {
+	Status  []StatusMap `mapstructure:"status"`
+	Headers HeaderSpec  `mapstructure:"headers"`
+	Body    BodySpec    `mapstructure:"body"`
+}
+
+// HeaderSpec 请求头或响应头操作，按删除、重命名、设置、追加的顺序执行
+type HeaderSpec struct {
+	Remove []string    `mapstructure:"remove"`
+	Rename []Rename    `mapstructure:"rename"`
+	Set    []NameValue `mapstructure:"set"` // 覆盖已有的值
+	Add    []NameValue `mapstructure:"add"` // 追加一个值
+}
+
+// QuerySpec 查询参数操作
+type QuerySpec struct {
+	Remove []string    `mapstructure:"remove"`
+	Set    []NameValue `mapstructure:"set"`
+}
+
+// BodySpec JSON 体操作，按删除、重命名、设置的顺序执行，字段使用选择器（见 Selector）
+type BodySpec struct {
+	Delete []string     `mapstructure:"delete"`
+	Rename []Rename     `mapstructure:"rename"`
+	Set    []FieldValue `mapstructure:"set"`
+}
+
+// NameValue 名称和值
+type NameValue struct {
+	Name  string `mapstructure:"name"`
+	Value string `mapstructure:"value"`
+}
+
+// Rename 重命名
+type Rename struct {
+	From string `mapstructure:"from"`
+	To   string `mapstructure:"to"`
+}
+
+// FieldValue JSON 字段和值，值可以是任意 YAML 值（对象、数组、数字等）
+type FieldValue struct {
+	Path  string      `mapstructure:"path"`
+	Value interface{} `mapstructure:"value"`
+}
+
+// PathRewrite 路径正则替换，replace 中可以使用 $1 引用分组
+type PathRewrite struct {
+	Pattern string `mapstructure:"pattern"`
+	Replace string `mapstructure:"replace"`
+}
+
+// StatusMap 状态码映射
+type StatusMap struct {
+	From int `mapstructure:"from"`
+	To   int `mapstructure:"to"`
+}
+
+// Compile 校验并编译声明式规则，规则按顺序执行
+func Compile(specs []Spec) (*Transformer, error) {
+	t := NewTransformer()
+	for i, spec := range specs {
+		rule, err := compileRule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("transforms[%d]: %w", i, err)
+		}
+		t.AddRule(rule)
+	}
+	return t, nil
+}
+
+// compileRule 编译单条规则
+func compileRule(spec Spec) (*TransformRule, error) {
+	rule := &TransformRule{Headers: spec.Match.Headers}
+	if spec.Match.Path != "" {
+		re, err := regexp.Compile(spec.Match.Path)
+		if err != nil {
+			return nil, fmt.Errorf("match.path: %w", err)
+		}
+		rule.PathPattern = re
+	}
+	for _, m := range spec.Match.Methods {
+		rule.Methods = append(rule.Methods, strings.ToUpper(m))
+	}
+
+	req, err := compileRequest(spec.Request)
+	if err != nil {
+		return nil, fmt.Errorf("request: %w", err)
+	}
+	resp, err := compileResponse(spec.Response)
+	if err != nil {
+		return nil, fmt.Errorf("response: %w", err)
+	}
+	rule.RequestTransform, rule.ResponseTransform = req, resp
+	return rule, nil
+}
+
+// pathRewrite 编译后的路径替换
+type pathRewrite struct {
+	pattern *regexp.Regexp
+	replace string
+}
+
+// compileRequest 编译请求转换，没有任何操作时返回 nil
+func compileRequest(spec RequestSpec) (RequestTransformFunc, error) {
+	var rewrites []pathRewrite
+	for _, p := range spec.Path {
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("path: %w", err)
+		}
+		rewrites = append(rewrites, pathRewrite{re, p.Replace})
+	}
+	for _, q := range spec.Query.Set {
+		if q.Name == "" {
+			return nil, fmt.Errorf("query: empty parameter name")
+		}
+	}
+	headers, err := compileHeaders(spec.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("headers: %w", err)
+	}
+	body, err := compileBody(spec.Body)
+	if err != nil {
+		return nil, fmt.Errorf("body: %w", err)
+	}
+	if len(rewrites) == 0 && len(spec.Query.Remove) == 0 && len(spec.Query.Set) == 0 && headers == nil && body == nil {
+		return nil, nil
+	}
+
+	query := spec.Query
+	return func(req *http.Request) error {
+		for _, r := range rewrites {
+			req.URL.Path = r.pattern.ReplaceAllString(req.URL.Path, r.replace)
+		}
+		if len(query.Remove) > 0 || len(query.Set) > 0 {
+			values := req.URL.Query()
+			for _, name := range query.Remove {
+				values.Del(name)
+			}
+			for _, q := range query.Set {
+				values.Set(q.Name, q.Value)
+			}
+			req.URL.RawQuery = values.Encode()
+		}
+		if headers != nil {
+			headers.apply(req.Header)
+		}
+		if body == nil || req.Body == nil || req.Body == http.NoBody || !rewritableJSON(req.Header) {
+			return nil
+		}
+		data, rest, ok, err := readBounded(req.Body, DefaultMaxBodySize)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			req.Body = rest
+			return nil
+		}
+		if out, changed := body.rewrite(data); changed {
+			data = out
+		}
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		req.ContentLength = int64(len(data))
+		req.Header.Set("Content-Length", strconv.Itoa(len(data)))
+		return nil
+	}, nil
+}
+
+// compileResponse 编译响应转换，没有任何操作时返回 nil
+func compileResponse(spec ResponseSpec) (ResponseTransformFunc, error) {
+	status := make(map[int]int)
+	for _, s := range spec.Status {
+		if s.From < 100 || s.From > 599 || s.To < 100 || s.To > 599 {
+			return nil, fmt.Errorf("status: invalid mapping %d -> %d", s.From, s.To)
+		}
+		status[s.From] = s.To
+	}
+	headers, err := compileHeaders(spec.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("headers: %w", err)
+	}
+	body, err := compileBody(spec.Body)
+	if err != nil {
+		return nil, fmt.Errorf("body: %w", err)
+	}
+	if len(status) == 0 && headers == nil && body == nil {
+		return nil, nil
+	}
+
+	return func(resp *http.Response) error {
+		if to, ok := status[resp.StatusCode]; ok {
+			resp.StatusCode = to
+			resp.Status = fmt.Sprintf("%d %s", to, http.StatusText(to))
+		}
+		if headers != nil {
+			headers.apply(resp.Header)
+		}
+		if body == nil {
+			return nil
+		}
+		// JSON 响应体整体改写，SSE 和 NDJSON 逐个事件、逐行改写
+		return BodyTransform{JSON: body.rewrite}.Apply(resp)
+	}, nil
+}
+
+// headerOps 编译后的头操作
+type headerOps HeaderSpec
+
+// compileHeaders 校验头操作，没有任何操作时返回 nil
+func compileHeaders(spec HeaderSpec) (*headerOps, error) {
+	if len(spec.Remove) == 0 && len(spec.Rename) == 0 && len(spec.Set) == 0 && len(spec.Add) == 0 {
+		return nil, nil
+	}
+	for _, r := range spec.Rename {
+		if r.From == "" || r.To == "" {
+			return nil, fmt.Errorf("rename requires from and to")
+		}
+	}
+	for _, nv := range append(append([]NameValue{}, spec.Set...), spec.Add...) {
+		if nv.Name == "" {
+			return nil, fmt.Errorf("empty header name")
+		}
+	}
+	ops := headerOps(spec)
+	return &ops, nil
+}
+
+// apply 执行头操作
+func (o *headerOps) apply(header http.Header) {
+	for _, name := range o.Remove {
+		header.Del(name)
+	}
+	for _, r := range o.Rename {
+		if values := header.Values(r.From); len(values) > 0 {
+			values = append([]string(nil), values...)
+			header.Del(r.From)
+			header.Del(r.To)
+			for _, v := range values {
+				header.Add(r.To, v)
+			}
+		}
+	}
+	for _, nv := range o.Set {
+		header.Set(nv.Name, nv.Value)
+	}
+	for _, nv := range o.Add {
+		header.Add(nv.Name, nv.Value)
+	}
+}
+
+// fieldValue 编译后的字段设置
+type fieldValue struct {
+	selector Selector
+	value    interface{}
+}
+
+// fieldRename 编译后的字段重命名
+type fieldRename struct {
+	from, to Selector
+}
+
+// bodyOps 编译后的 JSON 体操作
+type bodyOps struct {
+	deletes []Selector
+	renames []fieldRename
+	sets    []fieldValue
+}
+
+// compileBody 解析选择器，没有任何操作时返回 nil
+func compileBody(spec BodySpec) (*bodyOps, error) {
+	if len(spec.Delete) == 0 && len(spec.Rename) == 0 && len(spec.Set) == 0 {
+		return nil, nil
+	}
+	ops := &bodyOps{}
+	for _, path := range spec.Delete {
+		sel, err := ParseSelector(path)
+		if err != nil {
+			return nil, err
+		}
+		ops.deletes = append(ops.deletes, sel)
+	}
+	for _, r := range spec.Rename {
+		from, err := ParseSelector(r.From)
+		if err != nil {
+			return nil, err
+		}
+		to, err := ParseSelector(r.To)
+		if err != nil {
+			return nil, err
+		}
+		if from.Wildcard() || to.Wildcard() {
+			return nil, fmt.Errorf("rename %s -> %s: wildcards are not supported", r.From, r.To)
+		}
+		ops.renames = append(ops.renames, fieldRename{from, to})
+	}
+	for _, f := range spec.Set {
+		sel, err := ParseSelector(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		ops.sets = append(ops.sets, fieldValue{sel, normalize(f.Value)})
+	}
+	return ops, nil
+}
+
+// apply 对已解析的 JSON 文档执行操作，返回新的文档
+func (o *bodyOps) apply(doc interface{}) interface{} {
+	for _, sel := range o.deletes {
+		doc = sel.Delete(doc)
+	}
+	for _, r := range o.renames {
+		values := r.from.Get(doc)
+		if len(values) == 0 {
+			continue
+		}
+		doc = r.to.Set(r.from.Delete(doc), values[0])
+	}
+	for _, f := range o.sets {
+		// 每次复制配置中的值，避免后续操作修改共享的对象
+		doc = f.selector.Set(doc, clone(f.value))
+	}
+	return doc
+}
+
+// rewrite 改写 JSON 文本，无法解析时返回 false 并保持原样
+func (o *bodyOps) rewrite(data []byte) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, false
+	}
+	out, err := json.Marshal(o.apply(doc))
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+// rewritableJSON 判断消息体是否为未压缩的 JSON
+func rewritableJSON(header http.Header) bool {
+	if encoding := header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// readBounded 读取至多 limit 字节。超出时返回 false，并返回拼接了已读部分的完整消息体
+func readBounded(body io.ReadCloser, limit int64) ([]byte, io.ReadCloser, bool, error) {
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		body.Close()
+		return nil, nil, false, err
+	}
+	if int64(len(data)) > limit {
+		return nil, struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), body), body}, false, nil
+	}
+	body.Close()
+	return data, nil, true, nil
+}
+
+// normalize 将 YAML 解析出的 map[interface{}]interface{} 转换为可编码为 JSON 的形式
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[fmt.Sprint(k)] = normalize(val)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[k] = normalize(val)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(t))
+		for i, val := range t {
+			s[i] = normalize(val)
+		}
+		return s
+	}
+	return v
+}
+
+// clone 深拷贝 JSON 值
+func clone(v interface{}) interface{} {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return normalize(v)
+	}
+	return v
+}
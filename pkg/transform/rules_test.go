@@ -0,0 +1,164 @@
+package transform
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSelector(t *testing.T) {
+	doc := func() interface{} {
+		var v interface{}
+		json.Unmarshal([]byte(`{"model":"a","messages":[{"role":"system","name":"x"},{"role":"user","name":"y"}],"a.b":1}`), &v)
+		return v
+	}
+	encode := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+
+	tests := []struct {
+		name string
+		op   func(sel Selector, doc interface{}) interface{}
+		sel  string
+		want string
+	}{
+		{"set nested", func(s Selector, d interface{}) interface{} { return s.Set(d, true) }, "$.options.stream",
+			`{"a.b":1,"messages":[{"name":"x","role":"system"},{"name":"y","role":"user"}],"model":"a","options":{"stream":true}}`},
+		{"set without $", func(s Selector, d interface{}) interface{} { return s.Set(d, "b") }, "model",
+			`{"a.b":1,"messages":[{"name":"x","role":"system"},{"name":"y","role":"user"}],"model":"b"}`},
+		{"delete wildcard", func(s Selector, d interface{}) interface{} { return s.Delete(d) }, "$.messages[*].name",
+			`{"a.b":1,"messages":[{"role":"system"},{"role":"user"}],"model":"a"}`},
+		{"delete element", func(s Selector, d interface{}) interface{} { return s.Delete(d) }, "$.messages[0]",
+			`{"a.b":1,"messages":[{"name":"y","role":"user"}],"model":"a"}`},
+		{"negative index", func(s Selector, d interface{}) interface{} { return s.Set(d, "z") }, "$.messages[-1].name",
+			`{"a.b":1,"messages":[{"name":"x","role":"system"},{"name":"z","role":"user"}],"model":"a"}`},
+		{"quoted key", func(s Selector, d interface{}) interface{} { return s.Delete(d) }, "$['a.b']",
+			`{"messages":[{"name":"x","role":"system"},{"name":"y","role":"user"}],"model":"a"}`},
+		{"index out of range", func(s Selector, d interface{}) interface{} { return s.Set(d, 1) }, "$.messages[5].name",
+			`{"a.b":1,"messages":[{"name":"x","role":"system"},{"name":"y","role":"user"}],"model":"a"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel, err := ParseSelector(tt.sel)
+			if err != nil {
+				t.Fatalf("parse %q: %v", tt.sel, err)
+			}
+			if got := encode(tt.op(sel, doc())); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"", "$", "$.a[", "$.a[x]", "$..a", "$.a]"} {
+		if _, err := ParseSelector(invalid); err == nil {
+			t.Errorf("Expected error for selector %q", invalid)
+		}
+	}
+}
+
+func TestCompileRequest(t *testing.T) {
+	tr, err := Compile([]Spec{{
+		Match: MatchSpec{Path: "^/v1/chat/", Methods: []string{"post"}},
+		Request: RequestSpec{
+			Path:  []PathRewrite{{Pattern: "^/v1/(.*)$", Replace: "/v2/$1"}},
+			Query: QuerySpec{Remove: []string{"debug"}, Set: []NameValue{{Name: "api-version", Value: "2024"}}},
+			Headers: HeaderSpec{
+				Remove: []string{"X-Debug"},
+				Rename: []Rename{{From: "X-Old", To: "X-New"}},
+				Set:    []NameValue{{Name: "X-Gateway", Value: "sub-router"}},
+			},
+			Body: BodySpec{
+				Delete: []string{"$.user"},
+				Rename: []Rename{{From: "$.max_tokens", To: "$.max_completion_tokens"}},
+				Set:    []FieldValue{{Path: "$.stream_options", Value: map[interface{}]interface{}{"include_usage": true}}},
+			},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions?debug=1&x=2", strings.NewReader(`{"model":"gpt-4o","user":"u","max_tokens":10,"n":12345678901234567890}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Debug", "1")
+	req.Header.Set("X-Old", "v")
+	if err := tr.TransformRequest(req, "openai"); err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+
+	if req.URL.Path != "/v2/chat/completions" || req.URL.RawQuery != "api-version=2024&x=2" {
+		t.Errorf("Unexpected URL: %s?%s", req.URL.Path, req.URL.RawQuery)
+	}
+	if req.Header.Get("X-Debug") != "" || req.Header.Get("X-Old") != "" || req.Header.Get("X-New") != "v" || req.Header.Get("X-Gateway") != "sub-router" {
+		t.Errorf("Unexpected headers: %v", req.Header)
+	}
+	body, _ := io.ReadAll(req.Body)
+	want := `{"max_completion_tokens":10,"model":"gpt-4o","n":12345678901234567890,"stream_options":{"include_usage":true}}`
+	if string(body) != want || req.ContentLength != int64(len(want)) {
+		t.Errorf("Unexpected body %s (length %d)", body, req.ContentLength)
+	}
+
+	// 不匹配的请求保持原样
+	other := httptest.NewRequest("GET", "/v1/chat/completions?debug=1", nil)
+	tr.TransformRequest(other, "openai")
+	if other.URL.Path != "/v1/chat/completions" || other.URL.RawQuery != "debug=1" {
+		t.Errorf("Expected GET request to be unchanged, got %s?%s", other.URL.Path, other.URL.RawQuery)
+	}
+}
+
+func TestCompileResponse(t *testing.T) {
+	tr, err := Compile([]Spec{{
+		Response: ResponseSpec{
+			Status:  []StatusMap{{From: 404, To: 200}},
+			Headers: HeaderSpec{Add: []NameValue{{Name: "X-Transformed", Value: "1"}}},
+			Body:    BodySpec{Rename: []Rename{{From: "$.error.message", To: "$.detail"}}},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	newResp := func(contentType, body string) *http.Response {
+		return &http.Response{
+			StatusCode: 404,
+			Header:     http.Header{"Content-Type": {contentType}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    httptest.NewRequest("GET", "/v1/models", nil),
+		}
+	}
+
+	resp := newResp("application/json", `{"error":{"message":"gone","code":1}}`)
+	if err := tr.TransformResponse(resp, "openai"); err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || resp.Header.Get("X-Transformed") != "1" || string(body) != `{"detail":"gone","error":{"code":1}}` {
+		t.Errorf("Unexpected response %d %v %s", resp.StatusCode, resp.Header, body)
+	}
+
+	// 非 JSON 响应只改写状态码和响应头
+	resp = newResp("text/plain", "not found")
+	tr.TransformResponse(resp, "openai")
+	if body, _ := io.ReadAll(resp.Body); resp.StatusCode != 200 || string(body) != "not found" {
+		t.Errorf("Unexpected text response %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestCompileInvalid(t *testing.T) {
+	invalid := []Spec{
+		{Match: MatchSpec{Path: "("}},
+		{Request: RequestSpec{Path: []PathRewrite{{Pattern: "[", Replace: ""}}}},
+		{Request: RequestSpec{Headers: HeaderSpec{Rename: []Rename{{From: "X-A"}}}}},
+		{Request: RequestSpec{Body: BodySpec{Rename: []Rename{{From: "$.a[*]", To: "$.b"}}}}},
+		{Response: ResponseSpec{Status: []StatusMap{{From: 404, To: 42}}}},
+	}
+	for i, spec := range invalid {
+		if _, err := Compile([]Spec{spec}); err == nil {
+			t.Errorf("Expected error for spec %d", i)
+		}
+	}
+}
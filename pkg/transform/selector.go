@@ -0,0 +1,205 @@
+package transform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// segment 选择器中的一段：对象字段或数组下标
+type segment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool // [*] 匹配数组的所有元素
+}
+
+// Selector 类 JSONPath 的字段选择器。
+//
+// 支持 $.a.b、a.b、items[0].name（负数下标从末尾计数）、items[*].name 和 ['key.with.dots']，
+// 不支持过滤表达式和递归下降
+type Selector struct {
+	raw      string
+	segments []segment
+}
+
+// ParseSelector 解析字段选择器
+func ParseSelector(s string) (Selector, error) {
+	sel := Selector{raw: s}
+	rest := strings.TrimPrefix(strings.TrimSpace(s), "$")
+	for rest != "" {
+		switch {
+		case rest[0] == '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[]")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return Selector{}, fmt.Errorf("invalid selector %q: empty field name", s)
+			}
+			sel.segments = append(sel.segments, segment{key: rest[:end]})
+			rest = rest[end:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return Selector{}, fmt.Errorf("invalid selector %q: missing ]", s)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				sel.segments = append(sel.segments, segment{isIndex: true, wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				sel.segments = append(sel.segments, segment{key: inner[1 : len(inner)-1]})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return Selector{}, fmt.Errorf("invalid selector %q: bad index %q", s, inner)
+				}
+				sel.segments = append(sel.segments, segment{isIndex: true, index: n})
+			}
+		case len(sel.segments) == 0:
+			// 省略了 $. 的写法
+			rest = "." + rest
+		default:
+			return Selector{}, fmt.Errorf("invalid selector %q", s)
+		}
+	}
+	if len(sel.segments) == 0 {
+		return Selector{}, fmt.Errorf("invalid selector %q: empty path", s)
+	}
+	return sel, nil
+}
+
+// String 返回选择器原文
+func (s Selector) String() string {
+	return s.raw
+}
+
+// Wildcard 判断选择器是否包含 [*]
+func (s Selector) Wildcard() bool {
+	for _, seg := range s.segments {
+		if seg.wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// Get 获取选择器匹配的值，包含 [*] 时可能返回多个
+func (s Selector) Get(doc interface{}) []interface{} {
+	nodes := []interface{}{doc}
+	for _, seg := range s.segments {
+		var next []interface{}
+		for _, node := range nodes {
+			switch n := node.(type) {
+			case map[string]interface{}:
+				if v, ok := n[seg.key]; ok && !seg.isIndex {
+					next = append(next, v)
+				}
+			case []interface{}:
+				if !seg.isIndex {
+					continue
+				}
+				for _, i := range seg.indexes(len(n)) {
+					next = append(next, n[i])
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// Set 设置字段，中间缺少的对象会自动创建；数组下标越界时不修改
+func (s Selector) Set(doc, value interface{}) interface{} {
+	return update(doc, s.segments, true, func(interface{}, bool) (interface{}, bool) {
+		return value, true
+	})
+}
+
+// Delete 删除字段或数组元素
+func (s Selector) Delete(doc interface{}) interface{} {
+	return update(doc, s.segments, false, func(v interface{}, ok bool) (interface{}, bool) {
+		return v, false
+	})
+}
+
+// indexes 返回段匹配的数组下标
+func (seg segment) indexes(n int) []int {
+	if seg.wildcard {
+		all := make([]int, n)
+		for i := range all {
+			all[i] = i
+		}
+		return all
+	}
+	i := seg.index
+	if i < 0 {
+		i += n
+	}
+	if i < 0 || i >= n {
+		return nil
+	}
+	return []int{i}
+}
+
+// update 定位到最后一段，由 fn 根据原值返回新值，keep 为 false 时删除该字段。
+// 返回更新后的节点，数组删除元素后需要由上层重新赋值
+func update(node interface{}, segs []segment, create bool, fn func(v interface{}, ok bool) (interface{}, bool)) interface{} {
+	seg, last := segs[0], len(segs) == 1
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if seg.isIndex {
+			return node
+		}
+		child, ok := n[seg.key]
+		if last {
+			if v, keep := fn(child, ok); keep {
+				n[seg.key] = v
+			} else {
+				delete(n, seg.key)
+			}
+			return n
+		}
+		if !ok || child == nil {
+			if !create || segs[1].isIndex {
+				return n
+			}
+			child = make(map[string]interface{})
+		}
+		n[seg.key] = update(child, segs[1:], create, fn)
+		return n
+	case []interface{}:
+		if !seg.isIndex {
+			return node
+		}
+		indexes := seg.indexes(len(n))
+		if !last {
+			for _, i := range indexes {
+				n[i] = update(n[i], segs[1:], create, fn)
+			}
+			return n
+		}
+		removed := make(map[int]bool)
+		for _, i := range indexes {
+			if v, keep := fn(n[i], true); keep {
+				n[i] = v
+			} else {
+				removed[i] = true
+			}
+		}
+		if len(removed) == 0 {
+			return n
+		}
+		kept := make([]interface{}, 0, len(n)-len(removed))
+		for i, v := range n {
+			if !removed[i] {
+				kept = append(kept, v)
+			}
+		}
+		return kept
+	}
+	return node
+}
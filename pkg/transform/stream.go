@@ -0,0 +1,341 @@
+package transform
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// streamReadSize 流式读取上游响应的缓冲大小
+const streamReadSize = 32 * 1024
+
+// Event 一个 SSE 事件
+type Event struct {
+	Comments []string // 以 : 开头的注释行
+	Type     string   // event 字段
+	ID       string   // id 字段
+	Retry    string   // retry 字段
+	Data     string   // 多个 data 行以 \n 连接
+	Extra    []string // 其他字段的原始行
+
+	hasID   bool
+	hasData bool
+}
+
+// parseLine 解析事件中的一行（不含换行符）
+func (e *Event) parseLine(line string) {
+	if strings.HasPrefix(line, ":") {
+		e.Comments = append(e.Comments, strings.TrimPrefix(line[1:], " "))
+		return
+	}
+	name, value, _ := strings.Cut(line, ":")
+	value = strings.TrimPrefix(value, " ")
+	switch name {
+	case "event":
+		e.Type = value
+	case "id":
+		e.ID, e.hasID = value, true
+	case "retry":
+		e.Retry = value
+	case "data":
+		if e.hasData {
+			e.Data += "\n" + value
+		} else {
+			e.Data, e.hasData = value, true
+		}
+	default:
+		e.Extra = append(e.Extra, line)
+	}
+}
+
+// writeTo 序列化事件，不含结尾的空行
+func (e *Event) writeTo(buf *bytes.Buffer) {
+	for _, c := range e.Comments {
+		buf.WriteString(": " + c + "\n")
+	}
+	if e.Type != "" {
+		buf.WriteString("event: " + e.Type + "\n")
+	}
+	if e.ID != "" || e.hasID {
+		buf.WriteString("id: " + e.ID + "\n")
+	}
+	if e.Retry != "" {
+		buf.WriteString("retry: " + e.Retry + "\n")
+	}
+	for _, line := range e.Extra {
+		buf.WriteString(line + "\n")
+	}
+	if e.Data != "" || e.hasData {
+		for _, line := range strings.Split(e.Data, "\n") {
+			buf.WriteString("data: " + line + "\n")
+		}
+	}
+}
+
+// BodyTransform 响应体转换流水线，按 Content-Type 选择处理方式：
+//
+//   - SSE（text/event-stream）逐个事件改写，每个事件完整到达后立即输出
+//   - NDJSON、JSON Lines 逐行改写
+//   - JSON 缓冲至 MaxBuffer 后整体改写
+//
+// 单个事件、单行或整个 JSON 响应体超过 MaxBuffer 时原样转发；压缩的响应体不处理
+type BodyTransform struct {
+	JSON      func(data []byte) ([]byte, bool) // 改写 JSON 文档，返回 false 时保持原样
+	Events    func(ev Event) []Event           // 改写 SSE 事件，在 JSON 之后执行，返回零个或多个事件
+	MaxBuffer int64                            // 为 0 时使用 DefaultMaxBodySize
+}
+
+// Apply 包装响应体并修正 Content-Length、Transfer-Encoding
+func (t BodyTransform) Apply(resp *http.Response) error {
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return nil
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return nil
+	}
+	limit := t.MaxBuffer
+	if limit <= 0 {
+		limit = DefaultMaxBodySize
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "text/event-stream":
+		if t.JSON == nil && t.Events == nil {
+			return nil
+		}
+		resp.Body = newLineStream(resp.Body, int(limit), &eventHandler{transform: t, limit: int(limit)})
+		setBodyLength(resp, -1)
+	case mediaType == "application/x-ndjson" || mediaType == "application/ndjson" || mediaType == "application/jsonl":
+		if t.JSON == nil {
+			return nil
+		}
+		resp.Body = newLineStream(resp.Body, int(limit), &jsonLineHandler{fn: t.JSON})
+		setBodyLength(resp, -1)
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		if t.JSON == nil || resp.ContentLength > limit {
+			return nil
+		}
+		data, rest, ok, err := readBounded(resp.Body, limit)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			resp.Body = rest
+			return nil
+		}
+		if out, changed := t.JSON(data); changed {
+			data = out
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+		setBodyLength(resp, int64(len(data)))
+	}
+	return nil
+}
+
+// setBodyLength 修正消息体长度：n 小于 0 时改为分块传输
+func setBodyLength(resp *http.Response, n int64) {
+	// 内容已改变，原内容的摘要不再有效
+	resp.Header.Del("Content-Md5")
+	if n < 0 {
+		resp.ContentLength = -1
+		resp.Header.Del("Content-Length")
+		resp.TransferEncoding = []string{"chunked"}
+		return
+	}
+	resp.ContentLength = n
+	resp.Header.Set("Content-Length", strconv.FormatInt(n, 10))
+	resp.Header.Del("Transfer-Encoding")
+	resp.TransferEncoding = nil
+}
+
+// lineHandler 逐行处理流式响应
+type lineHandler interface {
+	// line 处理完整的一行，包含结尾的换行符（最后一行可能没有）
+	line(l []byte, out *bytes.Buffer)
+	// overflow 处理超过上限的行的一部分，需要原样输出
+	overflow(chunk []byte, out *bytes.Buffer)
+	// end 上游响应结束
+	end(out *bytes.Buffer)
+}
+
+// lineStream 逐行读取上游响应、改写后输出的响应体
+type lineStream struct {
+	src     *bufio.Reader
+	body    io.Closer
+	handler lineHandler
+	limit   int
+
+	line []byte // 尚未读完的行
+	long bool   // 当前行超过上限，剩余部分原样输出
+	out  bytes.Buffer
+	err  error
+}
+
+// newLineStream 创建逐行改写的响应体，limit 为单行的缓冲上限
+func newLineStream(body io.ReadCloser, limit int, handler lineHandler) *lineStream {
+	return &lineStream{
+		src:     bufio.NewReaderSize(body, streamReadSize),
+		body:    body,
+		handler: handler,
+		limit:   limit,
+	}
+}
+
+// Read 读取改写后的数据，每处理完一行就返回，保证流式输出不被延迟
+func (s *lineStream) Read(p []byte) (int, error) {
+	for s.out.Len() == 0 && s.err == nil {
+		chunk, err := s.src.ReadSlice('\n')
+		complete := err == nil
+		switch {
+		case s.long:
+			s.handler.overflow(chunk, &s.out)
+			s.long = !complete && err == bufio.ErrBufferFull
+		case len(s.line)+len(chunk) > s.limit:
+			s.handler.overflow(append(s.line, chunk...), &s.out)
+			s.line = s.line[:0]
+			s.long = !complete && err == bufio.ErrBufferFull
+		case complete:
+			s.handler.line(append(s.line, chunk...), &s.out)
+			s.line = s.line[:0]
+		default:
+			// ReadSlice 返回的切片在下次读取时失效，需要复制
+			s.line = append(s.line, chunk...)
+		}
+
+		if err != nil && err != bufio.ErrBufferFull {
+			if len(s.line) > 0 {
+				s.handler.line(s.line, &s.out)
+				s.line = nil
+			}
+			s.handler.end(&s.out)
+			s.err = err
+		}
+	}
+	if s.out.Len() > 0 {
+		return s.out.Read(p)
+	}
+	return 0, s.err
+}
+
+// Close 关闭上游响应体
+func (s *lineStream) Close() error {
+	return s.body.Close()
+}
+
+// splitEOL 拆分行内容和换行符
+func splitEOL(l []byte) ([]byte, []byte) {
+	content := bytes.TrimRight(l, "\r\n")
+	return content, l[len(content):]
+}
+
+// jsonLineHandler 逐行改写 NDJSON
+type jsonLineHandler struct {
+	fn func(data []byte) ([]byte, bool)
+}
+
+func (h *jsonLineHandler) line(l []byte, out *bytes.Buffer) {
+	content, eol := splitEOL(l)
+	if len(bytes.TrimSpace(content)) > 0 {
+		if data, ok := h.fn(content); ok {
+			out.Write(data)
+			out.Write(eol)
+			return
+		}
+	}
+	out.Write(l)
+}
+
+func (h *jsonLineHandler) overflow(chunk []byte, out *bytes.Buffer) {
+	out.Write(chunk)
+}
+
+func (h *jsonLineHandler) end(*bytes.Buffer) {}
+
+// eventHandler 逐个事件改写 SSE
+type eventHandler struct {
+	transform BodyTransform
+	limit     int
+
+	raw  bytes.Buffer // 当前事件的原始内容
+	ev   Event
+	pass bool // 当前事件超过上限，原样输出到空行为止
+}
+
+func (h *eventHandler) line(l []byte, out *bytes.Buffer) {
+	content, _ := splitEOL(l)
+	switch {
+	case h.pass:
+		out.Write(l)
+		h.pass = len(content) > 0
+	case len(content) == 0:
+		h.dispatch(l, out)
+	case h.raw.Len()+len(l) > h.limit:
+		h.overflow(l, out)
+		h.pass = true
+	default:
+		h.raw.Write(l)
+		h.ev.parseLine(string(content))
+	}
+}
+
+func (h *eventHandler) overflow(chunk []byte, out *bytes.Buffer) {
+	if !h.pass {
+		out.Write(h.raw.Bytes())
+		h.reset()
+		h.pass = true
+	}
+	out.Write(chunk)
+}
+
+func (h *eventHandler) end(out *bytes.Buffer) {
+	if !h.pass && h.raw.Len() > 0 {
+		// 没有以空行结尾的最后一个事件
+		h.dispatch(nil, out)
+	}
+}
+
+// dispatch 改写并输出完整的事件，blank 为结束事件的空行
+func (h *eventHandler) dispatch(blank []byte, out *bytes.Buffer) {
+	if h.raw.Len() == 0 {
+		out.Write(blank)
+		return
+	}
+	ev := h.ev
+	h.reset()
+
+	if fn := h.transform.JSON; fn != nil && isJSONData(ev.Data) {
+		if data, ok := fn([]byte(ev.Data)); ok {
+			ev.Data = string(data)
+		}
+	}
+	events := []Event{ev}
+	if fn := h.transform.Events; fn != nil {
+		events = fn(ev)
+	}
+	for i, e := range events {
+		e.writeTo(out)
+		// 展开的事件之间补充空行，最后一个事件沿用原始的结尾
+		if i < len(events)-1 {
+			out.WriteString("\n")
+		} else {
+			out.Write(blank)
+		}
+	}
+}
+
+// reset 开始下一个事件
+func (h *eventHandler) reset() {
+	h.raw.Reset()
+	h.ev = Event{}
+}
+
+// isJSONData 判断事件数据是否为 JSON 对象或数组（排除 [DONE] 等结束标记）
+func isJSONData(data string) bool {
+	data = strings.TrimSpace(data)
+	return strings.HasPrefix(data, "{") || (strings.HasPrefix(data, "[") && data != "[DONE]")
+}
@@ -0,0 +1,151 @@
+package transform
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// upperModel 将 JSON 中的 "model":"a" 改写为 "model":"A"
+func upperModel(data []byte) ([]byte, bool) {
+	if !bytes.Contains(data, []byte(`"model":"a"`)) {
+		return nil, false
+	}
+	return bytes.ReplaceAll(data, []byte(`"model":"a"`), []byte(`"model":"A"`)), true
+}
+
+// newStreamResponse 创建上游响应，body 逐字节返回以模拟分块到达
+func newStreamResponse(contentType, body string, length int64) *http.Response {
+	header := http.Header{"Content-Type": {contentType}}
+	if length >= 0 {
+		header.Set("Content-Length", strconv.FormatInt(length, 10))
+	}
+	return &http.Response{
+		StatusCode:    200,
+		Header:        header,
+		ContentLength: length,
+		Body:          io.NopCloser(iotest.OneByteReader(strings.NewReader(body))),
+	}
+}
+
+func TestBodyTransformEvents(t *testing.T) {
+	body := ": keep-alive\n\n" +
+		"event: message\nid: 1\ndata: {\"model\":\"a\",\ndata: \"n\":1}\n\n" +
+		"data: {\"type\":\"ping\"}\n\n" +
+		"data: {\"model\":\"a\"}\r\n\r\n" +
+		"data: [DONE]"
+	resp := newStreamResponse("text/event-stream", body, -1)
+	err := BodyTransform{
+		JSON: upperModel,
+		Events: func(ev Event) []Event {
+			switch {
+			case strings.Contains(ev.Data, "ping"):
+				return nil
+			case ev.Data == "[DONE]":
+				return []Event{{Data: `{"usage":{}}`}, ev}
+			}
+			return []Event{ev}
+		},
+	}.Apply(resp)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	got, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	want := ": keep-alive\n\n" +
+		"event: message\nid: 1\ndata: {\"model\":\"A\",\ndata: \"n\":1}\n\n" +
+		"data: {\"model\":\"A\"}\n\r\n" +
+		"data: {\"usage\":{}}\n\n" +
+		"data: [DONE]\n"
+	if string(got) != want {
+		t.Errorf("Unexpected stream:\n%q\nwant:\n%q", got, want)
+	}
+	if resp.ContentLength != -1 || len(resp.TransferEncoding) != 1 || resp.TransferEncoding[0] != "chunked" {
+		t.Errorf("Expected chunked encoding, got length %d %v", resp.ContentLength, resp.TransferEncoding)
+	}
+}
+
+func TestBodyTransformEventsIncremental(t *testing.T) {
+	pr, pw := io.Pipe()
+	resp := &http.Response{Header: http.Header{"Content-Type": {"text/event-stream"}}, ContentLength: -1, Body: pr}
+	BodyTransform{JSON: upperModel}.Apply(resp)
+
+	// 第一个事件完整到达后立即输出，不等待后续数据
+	go pw.Write([]byte("data: {\"model\":\"a\"}\n\ndata: {\"mo"))
+	buf := make([]byte, 1024)
+	n, err := resp.Body.Read(buf)
+	if err != nil || string(buf[:n]) != "data: {\"model\":\"A\"}\n\n" {
+		t.Errorf("Expected first event, got %q (%v)", buf[:n], err)
+	}
+	pw.Close()
+	resp.Body.Close()
+}
+
+func TestBodyTransformEventOverflow(t *testing.T) {
+	big := strings.Repeat("x", 100)
+	body := "data: {\"model\":\"a\",\"pad\":\"" + big + "\"}\n\ndata: {\"model\":\"a\"}\n\n"
+	resp := newStreamResponse("text/event-stream", body, -1)
+	BodyTransform{JSON: upperModel, MaxBuffer: 64}.Apply(resp)
+
+	got, _ := io.ReadAll(resp.Body)
+	// 超过上限的事件原样转发，之后的事件继续改写
+	want := "data: {\"model\":\"a\",\"pad\":\"" + big + "\"}\n\ndata: {\"model\":\"A\"}\n\n"
+	if string(got) != want {
+		t.Errorf("Unexpected stream:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestBodyTransformNDJSON(t *testing.T) {
+	body := "{\"model\":\"a\"}\n\n{\"model\":\"b\"}\n{\"model\":\"a\"}"
+	resp := newStreamResponse("application/x-ndjson", body, int64(len(body)))
+	BodyTransform{JSON: upperModel}.Apply(resp)
+
+	got, _ := io.ReadAll(resp.Body)
+	if want := "{\"model\":\"A\"}\n\n{\"model\":\"b\"}\n{\"model\":\"A\"}"; string(got) != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if resp.ContentLength != -1 || resp.Header.Get("Content-Length") != "" {
+		t.Errorf("Expected Content-Length to be removed, got %d %q", resp.ContentLength, resp.Header.Get("Content-Length"))
+	}
+}
+
+func TestBodyTransformJSON(t *testing.T) {
+	body := `{"model":"a"}`
+	resp := newStreamResponse("application/json", body, -1)
+	resp.Header.Set("Transfer-Encoding", "chunked")
+	resp.TransferEncoding = []string{"chunked"}
+	BodyTransform{JSON: upperModel}.Apply(resp)
+
+	got, _ := io.ReadAll(resp.Body)
+	if string(got) != `{"model":"A"}` {
+		t.Errorf("Unexpected body %q", got)
+	}
+	if resp.ContentLength != 13 || resp.Header.Get("Content-Length") != "13" || resp.TransferEncoding != nil || resp.Header.Get("Transfer-Encoding") != "" {
+		t.Errorf("Expected fixed length, got %d %v %v", resp.ContentLength, resp.Header, resp.TransferEncoding)
+	}
+
+	// 超过缓冲上限的响应原样转发
+	large := `{"model":"a","pad":"` + strings.Repeat("x", 100) + `"}`
+	for _, length := range []int64{int64(len(large)), -1} {
+		resp = newStreamResponse("application/json", large, length)
+		BodyTransform{JSON: upperModel, MaxBuffer: 64}.Apply(resp)
+		if got, _ := io.ReadAll(resp.Body); string(got) != large || resp.ContentLength != length {
+			t.Errorf("Expected passthrough for length %d, got %q (%d)", length, got, resp.ContentLength)
+		}
+	}
+
+	// 压缩的响应不处理
+	resp = newStreamResponse("application/json", body, int64(len(body)))
+	resp.Header.Set("Content-Encoding", "gzip")
+	BodyTransform{JSON: upperModel}.Apply(resp)
+	if got, _ := io.ReadAll(resp.Body); string(got) != body {
+		t.Errorf("Expected compressed body to be untouched, got %q", got)
+	}
+}
@@ -1,21 +1,22 @@
 package transform
 
 import (
-	"bytes"
 	"encoding/json"
-	"fmt"
-	"io"
 	"net/http"
+	"regexp"
+	"slices"
 )
 
 // TransformRule 转换规则
 type TransformRule struct {
 	// 匹配条件
-	Service    string            // 服务名
-	Path       string            // 路径
-	Method     string            // HTTP方法
-	Headers    map[string]string // 请求头匹配
-	QueryParam map[string]string // 查询参数匹配
+	Service     string            // 服务名
+	Path        string            // 路径
+	Method      string            // HTTP方法
+	Methods     []string          // HTTP方法列表，任意一个相等即匹配
+	PathPattern *regexp.Regexp    // 路径正则
+	Headers     map[string]string // 请求头匹配
+	QueryParam  map[string]string // 查询参数匹配
 
 	// 转换操作
 	RequestTransform  RequestTransformFunc  // 请求转换函数
@@ -83,10 +84,17 @@ func (r *TransformRule) matches(req *http.Request, service string) bool {
 		return false
 	}
 
+	if r.PathPattern != nil && !r.PathPattern.MatchString(req.URL.Path) {
+		return false
+	}
+
 	// 检查方法
 	if r.Method != "" && r.Method != req.Method {
 		return false
 	}
+	if len(r.Methods) > 0 && !slices.Contains(r.Methods, req.Method) {
+		return false
+	}
 
 	// 检查请求头
 	for k, v := range r.Headers {
@@ -107,40 +115,32 @@ func (r *TransformRule) matches(req *http.Request, service string) bool {
 
 // Common transform functions
 
-// JSONKeyRename 重命名JSON字段
+// JSONKeyRename 重命名JSON字段，SSE 和 NDJSON 响应逐个事件、逐行处理
 func JSONKeyRename(oldKey, newKey string) ResponseTransformFunc {
-	return func(resp *http.Response) error {
-		// 读取响应体
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-		resp.Body.Close()
-
+	rename := func(body []byte) ([]byte, bool) {
 		// 解析JSON
 		var data map[string]interface{}
 		if err := json.Unmarshal(body, &data); err != nil {
-			return err
+			return nil, false
 		}
 
 		// 重命名字段
-		if val, ok := data[oldKey]; ok {
-			data[newKey] = val
-			delete(data, oldKey)
+		val, ok := data[oldKey]
+		if !ok {
+			return nil, false
 		}
+		data[newKey] = val
+		delete(data, oldKey)
 
 		// 重新编码
 		newBody, err := json.Marshal(data)
 		if err != nil {
-			return err
+			return nil, false
 		}
-
-		// 更新响应
-		resp.Body = io.NopCloser(bytes.NewReader(newBody))
-		resp.ContentLength = int64(len(newBody))
-		resp.Header.Set("Content-Length", fmt.Sprint(len(newBody)))
-
-		return nil
+		return newBody, true
+	}
+	return func(resp *http.Response) error {
+		return BodyTransform{JSON: rename}.Apply(resp)
 	}
 }
 
@@ -0,0 +1,442 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 支持的上游格式，客户端统一使用 OpenAI Chat Completions 格式
+const (
+	FormatOpenAI    = "openai"
+	FormatAnthropic = "anthropic"
+	FormatGemini    = "gemini"
+)
+
+const (
+	// DefaultAnthropicMaxTokens 请求没有指定 max_tokens 时使用（Anthropic 要求必填）
+	DefaultAnthropicMaxTokens = 4096
+
+	// anthropicVersion 客户端没有指定时使用的 anthropic-version
+	anthropicVersion = "2023-06-01"
+)
+
+// geminiModelPattern Gemini 模型名，模型名会拼接到请求路径中，只允许字母、数字和 . _ -
+var geminiModelPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Translator 将 OpenAI Chat Completions 请求翻译为上游格式，并将响应（含 SSE 和用量）翻译回来
+type Translator struct {
+	format string
+}
+
+// NewTranslator 创建格式翻译器，format 为 anthropic 或 gemini
+func NewTranslator(format string) (*Translator, error) {
+	switch format {
+	case FormatAnthropic, FormatGemini:
+		return &Translator{format: format}, nil
+	}
+	return nil, fmt.Errorf("unsupported translation format %q", format)
+}
+
+// Format 返回上游格式
+func (t *Translator) Format() string {
+	return t.format
+}
+
+// Translation 一次请求的翻译，保存翻译响应所需的请求信息
+type Translation struct {
+	format  string
+	model   string
+	stream  bool
+	created int64
+}
+
+// Request 翻译请求的路径、请求头和请求体。
+//
+// 只处理 POST .../chat/completions，其他请求返回 nil 并保持原样；请求体无法解析时返回错误
+func (t *Translator) Request(req *http.Request) (*Translation, error) {
+	if req.Method != http.MethodPost || !strings.HasSuffix(strings.TrimSuffix(req.URL.Path, "/"), "/chat/completions") {
+		return nil, nil
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, fmt.Errorf("missing request body")
+	}
+	data, _, ok, err := readBounded(req.Body, DefaultMaxBodySize)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("request body exceeds %d bytes", DefaultMaxBodySize)
+	}
+	var chat chatRequest
+	if err := json.Unmarshal(data, &chat); err != nil {
+		return nil, fmt.Errorf("invalid chat completions request: %w", err)
+	}
+	if chat.Model == "" {
+		return nil, fmt.Errorf("invalid chat completions request: model is required")
+	}
+
+	x := &Translation{format: t.format, model: chat.Model, stream: chat.Stream, created: time.Now().Unix()}
+	var body interface{}
+	switch t.format {
+	case FormatAnthropic:
+		body, err = anthropicRequest(chat)
+		req.URL.Path = "/v1/messages"
+		if req.Header.Get("Anthropic-Version") == "" {
+			req.Header.Set("Anthropic-Version", anthropicVersion)
+		}
+		moveBearer(req.Header, "X-Api-Key")
+	case FormatGemini:
+		if !geminiModelPattern.MatchString(chat.Model) {
+			return nil, fmt.Errorf("invalid chat completions request: invalid model %q", chat.Model)
+		}
+		body, err = geminiRequest(chat)
+		method := ":generateContent"
+		if chat.Stream {
+			method = ":streamGenerateContent"
+			query := req.URL.Query()
+			query.Set("alt", "sse")
+			req.URL.RawQuery = query.Encode()
+		}
+		req.URL.Path = "/v1beta/models/" + chat.Model + method
+		moveBearer(req.Header, "X-Goog-Api-Key")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid chat completions request: %w", err)
+	}
+	if data, err = json.Marshal(body); err != nil {
+		return nil, err
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.ContentLength = int64(len(data))
+	req.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	req.Header.Set("Content-Type", "application/json")
+	// 由 Transport 协商压缩并自动解压，保证响应可以翻译
+	req.Header.Del("Accept-Encoding")
+	return x, nil
+}
+
+// Response 将上游响应翻译为 OpenAI 格式：SSE 逐个事件翻译，JSON 整体翻译，错误响应转换为 OpenAI 错误格式
+func (x *Translation) Response(resp *http.Response) error {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		var events func(Event) []Event
+		switch x.format {
+		case FormatAnthropic:
+			events = (&anthropicStream{x: x, tools: make(map[int]int)}).events
+		case FormatGemini:
+			events = (&geminiStream{x: x}).events
+		}
+		return BodyTransform{Events: events}.Apply(resp)
+	}
+
+	var convert func([]byte) ([]byte, bool)
+	switch {
+	case resp.StatusCode >= 400:
+		convert = x.errorBody
+	case x.format == FormatAnthropic:
+		convert = x.anthropicResponse
+	case x.format == FormatGemini:
+		convert = x.geminiResponse
+	}
+	return BodyTransform{JSON: convert}.Apply(resp)
+}
+
+// moveBearer 将客户端的 Authorization: Bearer 凭证改为上游使用的请求头
+func moveBearer(header http.Header, name string) {
+	key, ok := strings.CutPrefix(header.Get("Authorization"), "Bearer ")
+	if !ok || header.Get(name) != "" {
+		return
+	}
+	header.Del("Authorization")
+	header.Set(name, key)
+}
+
+// chatRequest OpenAI Chat Completions 请求
+type chatRequest struct {
+	Model               string          `json:"model"`
+	Messages            []chatMessage   `json:"messages"`
+	MaxTokens           int             `json:"max_tokens"`
+	MaxCompletionTokens int             `json:"max_completion_tokens"`
+	Temperature         *float64        `json:"temperature"`
+	TopP                *float64        `json:"top_p"`
+	N                   int             `json:"n"`
+	Stop                json.RawMessage `json:"stop"`
+	Stream              bool            `json:"stream"`
+	Tools               []chatTool      `json:"tools"`
+	ToolChoice          json.RawMessage `json:"tool_choice"`
+	User                string          `json:"user"`
+}
+
+// maxTokens 获取输出 token 上限
+func (r chatRequest) maxTokens() int {
+	if r.MaxCompletionTokens > 0 {
+		return r.MaxCompletionTokens
+	}
+	return r.MaxTokens
+}
+
+// stops 解析 stop（字符串或字符串数组）
+func (r chatRequest) stops() []string {
+	var one string
+	if json.Unmarshal(r.Stop, &one) == nil {
+		if one == "" {
+			return nil
+		}
+		return []string{one}
+	}
+	var many []string
+	json.Unmarshal(r.Stop, &many)
+	return many
+}
+
+// toolChoice 解析 tool_choice，返回模式（auto、none、required）和指定的函数名
+func (r chatRequest) toolChoice() (mode, name string) {
+	if len(r.ToolChoice) == 0 {
+		return "", ""
+	}
+	if json.Unmarshal(r.ToolChoice, &mode) == nil {
+		return mode, ""
+	}
+	var named struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if json.Unmarshal(r.ToolChoice, &named) == nil && named.Function.Name != "" {
+		return "function", named.Function.Name
+	}
+	return "", ""
+}
+
+// chatMessage OpenAI 消息
+type chatMessage struct {
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content"`
+	ToolCalls  []toolCall      `json:"tool_calls"`
+	ToolCallID string          `json:"tool_call_id"`
+}
+
+// parts 解析消息内容（字符串或内容片段数组）
+func (m chatMessage) parts() ([]contentPart, error) {
+	if len(m.Content) == 0 || string(m.Content) == "null" {
+		return nil, nil
+	}
+	var text string
+	if json.Unmarshal(m.Content, &text) == nil {
+		if text == "" {
+			return nil, nil
+		}
+		return []contentPart{{Type: "text", Text: text}}, nil
+	}
+	var parts []contentPart
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return nil, fmt.Errorf("invalid content for %s message", m.Role)
+	}
+	return parts, nil
+}
+
+// text 连接消息中的文本片段
+func (m chatMessage) text() string {
+	parts, _ := m.parts()
+	var texts []string
+	for _, p := range parts {
+		if p.Type == "text" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// contentPart OpenAI 消息内容片段
+type contentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	ImageURL struct {
+		URL string `json:"url"`
+	} `json:"image_url"`
+}
+
+// dataURL 解析 data:image/png;base64,... 格式的图片
+func (p contentPart) dataURL() (mediaType, data string, ok bool) {
+	rest, ok := strings.CutPrefix(p.ImageURL.URL, "data:")
+	if !ok {
+		return "", "", false
+	}
+	meta, data, ok := strings.Cut(rest, ",")
+	if !ok || !strings.HasSuffix(meta, ";base64") {
+		return "", "", false
+	}
+	return strings.TrimSuffix(meta, ";base64"), data, true
+}
+
+// toolCall OpenAI 工具调用
+type toolCall struct {
+	Index    *int   `json:"index,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// arguments 将参数字符串解析为 JSON 对象，无法解析时返回空对象
+func (c toolCall) arguments() json.RawMessage {
+	args := json.RawMessage(c.Function.Arguments)
+	if !json.Valid(args) || !bytes.HasPrefix(bytes.TrimSpace(args), []byte("{")) {
+		return json.RawMessage("{}")
+	}
+	return args
+}
+
+// chatTool OpenAI 工具定义
+type chatTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description,omitempty"`
+		Parameters  json.RawMessage `json:"parameters,omitempty"`
+	} `json:"function"`
+}
+
+// chatResponse OpenAI 响应或流式响应块
+type chatResponse struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []chatChoice `json:"choices"`
+	Usage   *chatUsage   `json:"usage,omitempty"`
+}
+
+// chatChoice OpenAI 响应选项
+type chatChoice struct {
+	Index        int        `json:"index"`
+	Message      *chatDelta `json:"message,omitempty"`
+	Delta        *chatDelta `json:"delta,omitempty"`
+	FinishReason *string    `json:"finish_reason"`
+}
+
+// chatDelta 响应消息或流式增量
+type chatDelta struct {
+	Role      string     `json:"role,omitempty"`
+	Content   *string    `json:"content,omitempty"`
+	ToolCalls []toolCall `json:"tool_calls,omitempty"`
+}
+
+// chatUsage OpenAI 用量
+type chatUsage struct {
+	PromptTokens        int64 `json:"prompt_tokens"`
+	CompletionTokens    int64 `json:"completion_tokens"`
+	TotalTokens         int64 `json:"total_tokens"`
+	PromptTokensDetails *struct {
+		CachedTokens int64 `json:"cached_tokens"`
+	} `json:"prompt_tokens_details,omitempty"`
+}
+
+// newChatUsage 创建用量，cached 为命中缓存的提示词 token 数（已包含在 prompt 中）
+func newChatUsage(prompt, completion, cached int64) *chatUsage {
+	u := &chatUsage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}
+	if cached > 0 {
+		u.PromptTokensDetails = &struct {
+			CachedTokens int64 `json:"cached_tokens"`
+		}{cached}
+	}
+	return u
+}
+
+// completion 创建非流式响应
+func (x *Translation) completion(id, model string, msg chatDelta, finish string, usage *chatUsage) ([]byte, bool) {
+	if model == "" {
+		model = x.model
+	}
+	msg.Role = "assistant"
+	resp := chatResponse{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: x.created,
+		Model:   model,
+		Choices: []chatChoice{{Message: &msg, FinishReason: &finish}},
+		Usage:   usage,
+	}
+	data, err := json.Marshal(resp)
+	return data, err == nil
+}
+
+// chunk 创建流式响应块事件，delta 为空时只包含用量
+func (x *Translation) chunk(id, model string, delta *chatDelta, finish string, usage *chatUsage) Event {
+	var choices []chatChoice
+	if delta != nil {
+		choice := chatChoice{Delta: delta}
+		if finish != "" {
+			choice.FinishReason = &finish
+		}
+		choices = append(choices, choice)
+	}
+	return x.chunkChoices(id, model, choices, usage)
+}
+
+// chunkChoices 创建包含多个选项的流式响应块事件
+func (x *Translation) chunkChoices(id, model string, choices []chatChoice, usage *chatUsage) Event {
+	if model == "" {
+		model = x.model
+	}
+	if choices == nil {
+		choices = []chatChoice{}
+	}
+	resp := chatResponse{
+		ID:      id,
+		Object:  "chat.completion.chunk",
+		Created: x.created,
+		Model:   model,
+		Choices: choices,
+		Usage:   usage,
+	}
+	data, _ := json.Marshal(resp)
+	return Event{Data: string(data)}
+}
+
+// doneEvent OpenAI 流式响应的结束标记
+var doneEvent = Event{Data: "[DONE]"}
+
+// errorBody 将上游错误响应转换为 OpenAI 错误格式
+func (x *Translation) errorBody(data []byte) ([]byte, bool) {
+	var upstream struct {
+		Error struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Message string `json:"message"`
+			Code    int    `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &upstream); err != nil || upstream.Error.Message == "" {
+		return nil, false
+	}
+	errType := upstream.Error.Type
+	if errType == "" {
+		errType = strings.ToLower(upstream.Error.Status)
+	}
+	out := map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": upstream.Error.Message,
+			"type":    errType,
+			"code":    nil,
+		},
+	}
+	result, err := json.Marshal(out)
+	return result, err == nil
+}
+
+// stringPtr 返回字符串指针
+func stringPtr(s string) *string {
+	return &s
+}
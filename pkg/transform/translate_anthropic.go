@@ -0,0 +1,297 @@
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// anthropicMessage Anthropic Messages API 消息
+type anthropicMessage struct {
+	Role    string                   `json:"role"`
+	Content []map[string]interface{} `json:"content"`
+}
+
+// anthropicRequest 将 OpenAI 请求转换为 Anthropic Messages API 请求
+func anthropicRequest(chat chatRequest) (map[string]interface{}, error) {
+	var system []string
+	var messages []anthropicMessage
+	appendBlocks := func(role string, blocks []map[string]interface{}) {
+		if len(blocks) == 0 {
+			return
+		}
+		// Anthropic 要求 user 与 assistant 交替出现，连续的同角色消息需要合并
+		if n := len(messages); n > 0 && messages[n-1].Role == role {
+			messages[n-1].Content = append(messages[n-1].Content, blocks...)
+			return
+		}
+		messages = append(messages, anthropicMessage{Role: role, Content: blocks})
+	}
+
+	for _, msg := range chat.Messages {
+		switch msg.Role {
+		case "system", "developer":
+			if text := msg.text(); text != "" {
+				system = append(system, text)
+			}
+		case "user", "assistant":
+			parts, err := msg.parts()
+			if err != nil {
+				return nil, err
+			}
+			var blocks []map[string]interface{}
+			for _, p := range parts {
+				block, err := anthropicBlock(p)
+				if err != nil {
+					return nil, err
+				}
+				if block != nil {
+					blocks = append(blocks, block)
+				}
+			}
+			for _, call := range msg.ToolCalls {
+				blocks = append(blocks, map[string]interface{}{
+					"type":  "tool_use",
+					"id":    call.ID,
+					"name":  call.Function.Name,
+					"input": call.arguments(),
+				})
+			}
+			appendBlocks(msg.Role, blocks)
+		case "tool":
+			appendBlocks("user", []map[string]interface{}{{
+				"type":        "tool_result",
+				"tool_use_id": msg.ToolCallID,
+				"content":     msg.text(),
+			}})
+		default:
+			return nil, fmt.Errorf("unsupported message role %q", msg.Role)
+		}
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("messages must contain at least one user or assistant message")
+	}
+
+	maxTokens := chat.maxTokens()
+	if maxTokens <= 0 {
+		maxTokens = DefaultAnthropicMaxTokens
+	}
+	body := map[string]interface{}{
+		"model":      chat.Model,
+		"messages":   messages,
+		"max_tokens": maxTokens,
+	}
+	if len(system) > 0 {
+		body["system"] = strings.Join(system, "\n\n")
+	}
+	if chat.Temperature != nil {
+		body["temperature"] = *chat.Temperature
+	}
+	if chat.TopP != nil {
+		body["top_p"] = *chat.TopP
+	}
+	if stops := chat.stops(); len(stops) > 0 {
+		body["stop_sequences"] = stops
+	}
+	if chat.Stream {
+		body["stream"] = true
+	}
+	if chat.User != "" {
+		body["metadata"] = map[string]string{"user_id": chat.User}
+	}
+	if len(chat.Tools) > 0 {
+		tools := make([]map[string]interface{}, 0, len(chat.Tools))
+		for _, tool := range chat.Tools {
+			schema := tool.Function.Parameters
+			if len(schema) == 0 {
+				schema = json.RawMessage(`{"type":"object","properties":{}}`)
+			}
+			t := map[string]interface{}{"name": tool.Function.Name, "input_schema": schema}
+			if tool.Function.Description != "" {
+				t["description"] = tool.Function.Description
+			}
+			tools = append(tools, t)
+		}
+		body["tools"] = tools
+	}
+	switch mode, name := chat.toolChoice(); mode {
+	case "auto":
+		body["tool_choice"] = map[string]string{"type": "auto"}
+	case "required":
+		body["tool_choice"] = map[string]string{"type": "any"}
+	case "none":
+		body["tool_choice"] = map[string]string{"type": "none"}
+	case "function":
+		body["tool_choice"] = map[string]string{"type": "tool", "name": name}
+	}
+	return body, nil
+}
+
+// anthropicBlock 将 OpenAI 内容片段转换为 Anthropic 内容块
+func anthropicBlock(p contentPart) (map[string]interface{}, error) {
+	switch p.Type {
+	case "text":
+		if p.Text == "" {
+			return nil, nil
+		}
+		return map[string]interface{}{"type": "text", "text": p.Text}, nil
+	case "image_url":
+		if mediaType, data, ok := p.dataURL(); ok {
+			return map[string]interface{}{
+				"type":   "image",
+				"source": map[string]string{"type": "base64", "media_type": mediaType, "data": data},
+			}, nil
+		}
+		return map[string]interface{}{
+			"type":   "image",
+			"source": map[string]string{"type": "url", "url": p.ImageURL.URL},
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported content type %q", p.Type)
+}
+
+// anthropicUsage Anthropic 用量
+type anthropicUsage struct {
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+}
+
+// chat 转换为 OpenAI 用量，提示词 token 包含缓存读取和写入的部分
+func (u anthropicUsage) chat() *chatUsage {
+	prompt := u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens
+	return newChatUsage(prompt, u.OutputTokens, u.CacheReadInputTokens)
+}
+
+// anthropicFinishReason 转换 stop_reason
+func anthropicFinishReason(reason string) string {
+	switch reason {
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "refusal":
+		return "content_filter"
+	case "":
+		return ""
+	}
+	return "stop"
+}
+
+// anthropicResponse 将 Anthropic 响应转换为 OpenAI 响应
+func (x *Translation) anthropicResponse(data []byte) ([]byte, bool) {
+	var resp struct {
+		ID      string `json:"id"`
+		Type    string `json:"type"`
+		Model   string `json:"model"`
+		Content []struct {
+			Type  string          `json:"type"`
+			Text  string          `json:"text"`
+			ID    string          `json:"id"`
+			Name  string          `json:"name"`
+			Input json.RawMessage `json:"input"`
+		} `json:"content"`
+		StopReason string         `json:"stop_reason"`
+		Usage      anthropicUsage `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil || resp.Type != "message" {
+		return nil, false
+	}
+
+	var msg chatDelta
+	var texts []string
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			texts = append(texts, block.Text)
+		case "tool_use":
+			call := toolCall{ID: block.ID, Type: "function"}
+			call.Function.Name = block.Name
+			call.Function.Arguments = string(block.Input)
+			msg.ToolCalls = append(msg.ToolCalls, call)
+		}
+	}
+	// 只有工具调用时 content 为 null
+	if len(texts) > 0 || len(msg.ToolCalls) == 0 {
+		msg.Content = stringPtr(strings.Join(texts, ""))
+	}
+	return x.completion(resp.ID, resp.Model, msg, anthropicFinishReason(resp.StopReason), resp.Usage.chat())
+}
+
+// anthropicStream 将 Anthropic 流式事件转换为 OpenAI 流式响应块
+type anthropicStream struct {
+	x     *Translation
+	id    string
+	model string
+	usage anthropicUsage
+	tools map[int]int // 内容块下标到工具调用下标
+}
+
+func (s *anthropicStream) events(ev Event) []Event {
+	var data struct {
+		Type    string `json:"type"`
+		Index   int    `json:"index"`
+		Message struct {
+			ID    string         `json:"id"`
+			Model string         `json:"model"`
+			Usage anthropicUsage `json:"usage"`
+		} `json:"message"`
+		ContentBlock struct {
+			Type string `json:"type"`
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"content_block"`
+		Delta struct {
+			Type        string `json:"type"`
+			Text        string `json:"text"`
+			PartialJSON string `json:"partial_json"`
+			StopReason  string `json:"stop_reason"`
+		} `json:"delta"`
+		Usage *anthropicUsage `json:"usage"`
+	}
+	if !isJSONData(ev.Data) || json.Unmarshal([]byte(ev.Data), &data) != nil {
+		return nil
+	}
+
+	switch data.Type {
+	case "message_start":
+		s.id, s.model, s.usage = data.Message.ID, data.Message.Model, data.Message.Usage
+		return []Event{s.x.chunk(s.id, s.model, &chatDelta{Role: "assistant", Content: stringPtr("")}, "", nil)}
+	case "content_block_start":
+		if data.ContentBlock.Type != "tool_use" {
+			return nil
+		}
+		index := len(s.tools)
+		s.tools[data.Index] = index
+		call := toolCall{Index: &index, ID: data.ContentBlock.ID, Type: "function"}
+		call.Function.Name = data.ContentBlock.Name
+		return []Event{s.x.chunk(s.id, s.model, &chatDelta{ToolCalls: []toolCall{call}}, "", nil)}
+	case "content_block_delta":
+		switch data.Delta.Type {
+		case "text_delta":
+			return []Event{s.x.chunk(s.id, s.model, &chatDelta{Content: stringPtr(data.Delta.Text)}, "", nil)}
+		case "input_json_delta":
+			index, ok := s.tools[data.Index]
+			if !ok {
+				return nil
+			}
+			call := toolCall{Index: &index}
+			call.Function.Arguments = data.Delta.PartialJSON
+			return []Event{s.x.chunk(s.id, s.model, &chatDelta{ToolCalls: []toolCall{call}}, "", nil)}
+		}
+	case "message_delta":
+		if data.Usage != nil {
+			s.usage.OutputTokens = data.Usage.OutputTokens
+		}
+		if reason := anthropicFinishReason(data.Delta.StopReason); reason != "" {
+			return []Event{s.x.chunk(s.id, s.model, &chatDelta{}, reason, nil)}
+		}
+	case "message_stop":
+		return []Event{s.x.chunk(s.id, s.model, nil, "", s.usage.chat()), doneEvent}
+	case "error":
+		// 错误事件原样转发，客户端可以读取错误信息
+		return []Event{{Type: "error", Data: ev.Data}}
+	}
+	return nil
+}
@@ -0,0 +1,332 @@
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// geminiContent Gemini 消息
+type geminiContent struct {
+	Role  string                   `json:"role,omitempty"`
+	Parts []map[string]interface{} `json:"parts"`
+}
+
+// geminiRequest 将 OpenAI 请求转换为 Gemini generateContent 请求
+func geminiRequest(chat chatRequest) (map[string]interface{}, error) {
+	var system []map[string]interface{}
+	var contents []geminiContent
+	appendParts := func(role string, parts []map[string]interface{}) {
+		if len(parts) == 0 {
+			return
+		}
+		if n := len(contents); n > 0 && contents[n-1].Role == role {
+			contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+			return
+		}
+		contents = append(contents, geminiContent{Role: role, Parts: parts})
+	}
+	// functionResponse 需要函数名，从之前的工具调用中查找
+	toolNames := make(map[string]string)
+
+	for _, msg := range chat.Messages {
+		switch msg.Role {
+		case "system", "developer":
+			if text := msg.text(); text != "" {
+				system = append(system, map[string]interface{}{"text": text})
+			}
+		case "user", "assistant":
+			parts, err := msg.parts()
+			if err != nil {
+				return nil, err
+			}
+			var out []map[string]interface{}
+			for _, p := range parts {
+				part, err := geminiPart(p)
+				if err != nil {
+					return nil, err
+				}
+				if part != nil {
+					out = append(out, part)
+				}
+			}
+			for _, call := range msg.ToolCalls {
+				toolNames[call.ID] = call.Function.Name
+				out = append(out, map[string]interface{}{
+					"functionCall": map[string]interface{}{"name": call.Function.Name, "args": call.arguments()},
+				})
+			}
+			role := "user"
+			if msg.Role == "assistant" {
+				role = "model"
+			}
+			appendParts(role, out)
+		case "tool":
+			name := toolNames[msg.ToolCallID]
+			if name == "" {
+				return nil, fmt.Errorf("tool message references unknown tool_call_id %q", msg.ToolCallID)
+			}
+			var result interface{} = map[string]string{"content": msg.text()}
+			var parsed map[string]interface{}
+			if json.Unmarshal([]byte(msg.text()), &parsed) == nil {
+				result = parsed
+			}
+			appendParts("user", []map[string]interface{}{{
+				"functionResponse": map[string]interface{}{"name": name, "response": result},
+			}})
+		default:
+			return nil, fmt.Errorf("unsupported message role %q", msg.Role)
+		}
+	}
+	if len(contents) == 0 {
+		return nil, fmt.Errorf("messages must contain at least one user or assistant message")
+	}
+
+	body := map[string]interface{}{"contents": contents}
+	if len(system) > 0 {
+		body["systemInstruction"] = geminiContent{Parts: system}
+	}
+	gen := make(map[string]interface{})
+	if chat.Temperature != nil {
+		gen["temperature"] = *chat.Temperature
+	}
+	if chat.TopP != nil {
+		gen["topP"] = *chat.TopP
+	}
+	if n := chat.maxTokens(); n > 0 {
+		gen["maxOutputTokens"] = n
+	}
+	if stops := chat.stops(); len(stops) > 0 {
+		gen["stopSequences"] = stops
+	}
+	if chat.N > 1 {
+		gen["candidateCount"] = chat.N
+	}
+	if len(gen) > 0 {
+		body["generationConfig"] = gen
+	}
+	if len(chat.Tools) > 0 {
+		decls := make([]map[string]interface{}, 0, len(chat.Tools))
+		for _, tool := range chat.Tools {
+			decl := map[string]interface{}{"name": tool.Function.Name}
+			if tool.Function.Description != "" {
+				decl["description"] = tool.Function.Description
+			}
+			if len(tool.Function.Parameters) > 0 {
+				decl["parameters"] = tool.Function.Parameters
+			}
+			decls = append(decls, decl)
+		}
+		body["tools"] = []map[string]interface{}{{"functionDeclarations": decls}}
+	}
+	switch mode, name := chat.toolChoice(); mode {
+	case "auto":
+		body["toolConfig"] = geminiToolConfig("AUTO")
+	case "required":
+		body["toolConfig"] = geminiToolConfig("ANY")
+	case "none":
+		body["toolConfig"] = geminiToolConfig("NONE")
+	case "function":
+		config := geminiToolConfig("ANY")
+		config["functionCallingConfig"]["allowedFunctionNames"] = []string{name}
+		body["toolConfig"] = config
+	}
+	return body, nil
+}
+
+// geminiToolConfig 创建工具调用模式配置
+func geminiToolConfig(mode string) map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{"functionCallingConfig": {"mode": mode}}
+}
+
+// geminiPart 将 OpenAI 内容片段转换为 Gemini part
+func geminiPart(p contentPart) (map[string]interface{}, error) {
+	switch p.Type {
+	case "text":
+		if p.Text == "" {
+			return nil, nil
+		}
+		return map[string]interface{}{"text": p.Text}, nil
+	case "image_url":
+		if mediaType, data, ok := p.dataURL(); ok {
+			return map[string]interface{}{"inlineData": map[string]string{"mimeType": mediaType, "data": data}}, nil
+		}
+		return map[string]interface{}{"fileData": map[string]string{"fileUri": p.ImageURL.URL}}, nil
+	}
+	return nil, fmt.Errorf("unsupported content type %q", p.Type)
+}
+
+// geminiResponseBody Gemini 响应或流式响应块
+type geminiResponseBody struct {
+	ResponseID string `json:"responseId"`
+	Candidates []struct {
+		Index   int `json:"index"`
+		Content struct {
+			Parts []struct {
+				Text         string `json:"text"`
+				Thought      bool   `json:"thought"`
+				FunctionCall *struct {
+					Name string          `json:"name"`
+					Args json.RawMessage `json:"args"`
+				} `json:"functionCall"`
+			} `json:"parts"`
+		} `json:"content"`
+		FinishReason string `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata *geminiUsage    `json:"usageMetadata"`
+	ModelVersion  string          `json:"modelVersion"`
+	Error         json.RawMessage `json:"error"`
+}
+
+// geminiUsage Gemini 用量
+type geminiUsage struct {
+	PromptTokenCount        int64 `json:"promptTokenCount"`
+	CandidatesTokenCount    int64 `json:"candidatesTokenCount"`
+	ThoughtsTokenCount      int64 `json:"thoughtsTokenCount"`
+	CachedContentTokenCount int64 `json:"cachedContentTokenCount"`
+}
+
+// chat 转换为 OpenAI 用量，思考 token 计入输出
+func (u *geminiUsage) chat() *chatUsage {
+	if u == nil {
+		return nil
+	}
+	return newChatUsage(u.PromptTokenCount, u.CandidatesTokenCount+u.ThoughtsTokenCount, u.CachedContentTokenCount)
+}
+
+// geminiFinishReason 转换 finishReason
+func geminiFinishReason(reason string, toolCalls bool) string {
+	switch reason {
+	case "":
+		return ""
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return "content_filter"
+	}
+	if toolCalls {
+		return "tool_calls"
+	}
+	return "stop"
+}
+
+// choices 将候选转换为 OpenAI 选项，streaming 为 true 时生成流式增量
+func (b geminiResponseBody) choices(streaming bool) []chatChoice {
+	choices := make([]chatChoice, 0, len(b.Candidates))
+	for _, c := range b.Candidates {
+		var msg chatDelta
+		var texts []string
+		for _, part := range c.Content.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				index := len(msg.ToolCalls)
+				call := toolCall{ID: fmt.Sprintf("call_%s_%d_%d", b.ResponseID, c.Index, index), Type: "function"}
+				if streaming {
+					call.Index = &index
+				}
+				call.Function.Name = part.FunctionCall.Name
+				call.Function.Arguments = string(part.FunctionCall.Args)
+				if len(part.FunctionCall.Args) == 0 {
+					call.Function.Arguments = "{}"
+				}
+				msg.ToolCalls = append(msg.ToolCalls, call)
+			case !part.Thought:
+				texts = append(texts, part.Text)
+			}
+		}
+		if len(texts) > 0 || (!streaming && len(msg.ToolCalls) == 0) {
+			msg.Content = stringPtr(strings.Join(texts, ""))
+		}
+		choice := chatChoice{Index: c.Index}
+		if reason := geminiFinishReason(c.FinishReason, len(msg.ToolCalls) > 0); reason != "" || !streaming {
+			choice.FinishReason = &reason
+		}
+		if streaming {
+			choice.Delta = &msg
+		} else {
+			msg.Role = "assistant"
+			choice.Message = &msg
+		}
+		choices = append(choices, choice)
+	}
+	return choices
+}
+
+// geminiResponse 将 Gemini 响应转换为 OpenAI 响应
+func (x *Translation) geminiResponse(data []byte) ([]byte, bool) {
+	var resp geminiResponseBody
+	if err := json.Unmarshal(data, &resp); err != nil || resp.Candidates == nil {
+		return nil, false
+	}
+	model := resp.ModelVersion
+	if model == "" {
+		model = x.model
+	}
+	out := chatResponse{
+		ID:      "chatcmpl-" + resp.ResponseID,
+		Object:  "chat.completion",
+		Created: x.created,
+		Model:   model,
+		Choices: resp.choices(false),
+		Usage:   resp.UsageMetadata.chat(),
+	}
+	result, err := json.Marshal(out)
+	return result, err == nil
+}
+
+// geminiStream 将 Gemini 流式事件转换为 OpenAI 流式响应块
+type geminiStream struct {
+	x     *Translation
+	id    string
+	role  bool // 已发送 assistant 角色
+	calls int  // 已发送的工具调用数量
+	usage *geminiUsage
+	done  bool
+}
+
+func (s *geminiStream) events(ev Event) []Event {
+	var data geminiResponseBody
+	if s.done || !isJSONData(ev.Data) || json.Unmarshal([]byte(ev.Data), &data) != nil {
+		return nil
+	}
+	if len(data.Error) > 0 {
+		return []Event{{Type: "error", Data: ev.Data}}
+	}
+	if s.id == "" {
+		s.id = "chatcmpl-" + data.ResponseID
+	}
+	if data.UsageMetadata != nil {
+		s.usage = data.UsageMetadata
+	}
+
+	choices := data.choices(true)
+	finished := len(choices) > 0
+	calls := s.calls
+	for _, choice := range choices {
+		if !s.role {
+			choice.Delta.Role = "assistant"
+		}
+		// 工具调用可能分布在多个响应块中，下标需要连续
+		for i := range choice.Delta.ToolCalls {
+			index := s.calls + *choice.Delta.ToolCalls[i].Index
+			choice.Delta.ToolCalls[i].Index = &index
+			calls = max(calls, index+1)
+		}
+		if choice.FinishReason != nil && *choice.FinishReason == "stop" && calls > 0 {
+			*choice.FinishReason = "tool_calls"
+		}
+		finished = finished && choice.FinishReason != nil
+	}
+	s.calls = calls
+	s.role = s.role || len(choices) > 0
+	if len(choices) == 0 {
+		return nil
+	}
+	events := []Event{s.x.chunkChoices(s.id, data.ModelVersion, choices, nil)}
+	if finished {
+		// Gemini 没有单独的结束事件，所有候选都结束后补充用量和结束标记
+		s.done = true
+		events = append(events, s.x.chunk(s.id, data.ModelVersion, nil, "", s.usage.chat()), doneEvent)
+	}
+	return events
+}
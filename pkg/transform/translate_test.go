@@ -0,0 +1,261 @@
+package transform
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// translateRequest 翻译 OpenAI 请求，返回上游请求和解析后的请求体
+func translateRequest(t *testing.T, format, body string) (*http.Request, *Translation, map[string]interface{}) {
+	t.Helper()
+	tr, err := NewTranslator(format)
+	if err != nil {
+		t.Fatalf("new translator: %v", err)
+	}
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-test")
+	req.Header.Set("Accept-Encoding", "br")
+	x, err := tr.Request(req)
+	if err != nil || x == nil {
+		t.Fatalf("translate request: %v", err)
+	}
+	data, _ := io.ReadAll(req.Body)
+	if req.ContentLength != int64(len(data)) {
+		t.Errorf("Expected content length %d, got %d", len(data), req.ContentLength)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("decode translated body %q: %v", data, err)
+	}
+	return req, x, got
+}
+
+// translateResponse 翻译上游响应并返回响应体
+func translateResponse(t *testing.T, x *Translation, status int, contentType, body string) string {
+	t.Helper()
+	resp := newStreamResponse(contentType, body, -1)
+	resp.StatusCode = status
+	if err := x.Response(resp); err != nil {
+		t.Fatalf("translate response: %v", err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	return string(data)
+}
+
+// assertJSON 比较 JSON 中的字段
+func assertJSON(t *testing.T, data string, want map[string]interface{}) {
+	t.Helper()
+	var doc interface{}
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		t.Fatalf("decode %q: %v", data, err)
+	}
+	for path, value := range want {
+		sel, _ := ParseSelector(path)
+		got := sel.Get(doc)
+		if len(got) != 1 || !reflect.DeepEqual(got[0], value) {
+			t.Errorf("Expected %s = %v, got %v in %s", path, value, got, data)
+		}
+	}
+}
+
+const chatWithTools = `{
+	"model": "m",
+	"messages": [
+		{"role": "system", "content": "be brief"},
+		{"role": "user", "content": [{"type": "text", "text": "weather?"}, {"type": "image_url", "image_url": {"url": "data:image/png;base64,AAA"}}]},
+		{"role": "assistant", "content": null, "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "weather", "arguments": "{\"city\":\"x\"}"}}]},
+		{"role": "tool", "tool_call_id": "call_1", "content": "sunny"},
+		{"role": "user", "content": "thanks"}
+	],
+	"max_tokens": 100,
+	"temperature": 0.5,
+	"stop": "END",
+	"tools": [{"type": "function", "function": {"name": "weather", "parameters": {"type": "object"}}}],
+	"tool_choice": "required"
+}`
+
+func TestTranslateAnthropicRequest(t *testing.T) {
+	req, _, body := translateRequest(t, FormatAnthropic, chatWithTools)
+	if req.URL.Path != "/v1/messages" || req.Header.Get("X-Api-Key") != "sk-test" || req.Header.Get("Authorization") != "" {
+		t.Errorf("Unexpected request %s %v", req.URL.Path, req.Header)
+	}
+	if req.Header.Get("Anthropic-Version") == "" || req.Header.Get("Accept-Encoding") != "" {
+		t.Errorf("Unexpected headers %v", req.Header)
+	}
+	assertJSON(t, mustJSON(body), map[string]interface{}{
+		"$.system":                             "be brief",
+		"$.max_tokens":                         100.0,
+		"$.temperature":                        0.5,
+		"$.stop_sequences[0]":                  "END",
+		"$.messages[0].role":                   "user",
+		"$.messages[0].content[1].source.type": "base64",
+		"$.messages[1].content[0].type":        "tool_use",
+		"$.messages[1].content[0].input.city":  "x",
+		"$.messages[2].role":                   "user",
+		"$.messages[2].content[0].type":        "tool_result",
+		"$.messages[2].content[0].tool_use_id": "call_1",
+		"$.messages[2].content[1].text":        "thanks",
+		"$.tools[0].input_schema.type":         "object",
+		"$.tool_choice.type":                   "any",
+	})
+	if n := len(body["messages"].([]interface{})); n != 3 {
+		t.Errorf("Expected consecutive user messages to be merged into 3 messages, got %d", n)
+	}
+}
+
+func TestTranslateAnthropicResponse(t *testing.T) {
+	_, x, _ := translateRequest(t, FormatAnthropic, `{"model":"m","messages":[{"role":"user","content":"hi"}]}`)
+	got := translateResponse(t, x, 200, "application/json", `{
+		"id": "msg_1", "type": "message", "model": "claude",
+		"content": [{"type": "text", "text": "hello"}, {"type": "tool_use", "id": "tu_1", "name": "f", "input": {"a": 1}}],
+		"stop_reason": "tool_use",
+		"usage": {"input_tokens": 10, "output_tokens": 5, "cache_read_input_tokens": 4}
+	}`)
+	assertJSON(t, got, map[string]interface{}{
+		"$.object":                              "chat.completion",
+		"$.model":                               "claude",
+		"$.choices[0].message.content":          "hello",
+		"$.choices[0].message.tool_calls[0].id": "tu_1",
+		"$.choices[0].message.tool_calls[0].function.arguments": `{"a": 1}`,
+		"$.choices[0].finish_reason":                            "tool_calls",
+		"$.usage.prompt_tokens":                                 14.0,
+		"$.usage.completion_tokens":                             5.0,
+		"$.usage.total_tokens":                                  19.0,
+		"$.usage.prompt_tokens_details.cached_tokens":           4.0,
+	})
+
+	// 错误响应转换为 OpenAI 错误格式
+	got = translateResponse(t, x, 429, "application/json", `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`)
+	assertJSON(t, got, map[string]interface{}{"$.error.message": "slow down", "$.error.type": "rate_limit_error"})
+}
+
+func TestTranslateAnthropicStream(t *testing.T) {
+	_, x, _ := translateRequest(t, FormatAnthropic, `{"model":"m","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	stream := "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"model\":\"claude\",\"usage\":{\"input_tokens\":7,\"output_tokens\":1}}}\n\n" +
+		"event: ping\ndata: {\"type\":\"ping\"}\n\n" +
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi\"}}\n\n" +
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"tool_use\",\"id\":\"tu_1\",\"name\":\"f\"}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{}\"}}\n\n" +
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"},\"usage\":{\"output_tokens\":12}}\n\n" +
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+	chunks := streamData(translateResponse(t, x, 200, "text/event-stream", stream))
+
+	if len(chunks) != 7 || chunks[len(chunks)-1] != "[DONE]" {
+		t.Fatalf("Unexpected chunks %q", chunks)
+	}
+	assertJSON(t, chunks[0], map[string]interface{}{"$.id": "msg_1", "$.object": "chat.completion.chunk", "$.choices[0].delta.role": "assistant"})
+	assertJSON(t, chunks[1], map[string]interface{}{"$.choices[0].delta.content": "Hi"})
+	assertJSON(t, chunks[2], map[string]interface{}{"$.choices[0].delta.tool_calls[0].id": "tu_1", "$.choices[0].delta.tool_calls[0].index": 0.0})
+	assertJSON(t, chunks[3], map[string]interface{}{"$.choices[0].delta.tool_calls[0].function.arguments": "{}"})
+	assertJSON(t, chunks[4], map[string]interface{}{"$.choices[0].finish_reason": "tool_calls"})
+	assertJSON(t, chunks[5], map[string]interface{}{"$.usage.prompt_tokens": 7.0, "$.usage.completion_tokens": 12.0})
+}
+
+func TestTranslateGeminiRejectsModelPath(t *testing.T) {
+	tr, _ := NewTranslator(FormatGemini)
+	for _, model := range []string{"../files", "gemini-pro:countTokens", "a/b", "a%2Fb", "a?b"} {
+		req := httptest.NewRequest("POST", "/v1/chat/completions",
+			strings.NewReader(`{"model":`+strconv.Quote(model)+`,"messages":[{"role":"user","content":"hi"}]}`))
+		if _, err := tr.Request(req); err == nil {
+			t.Errorf("Expected model %q to be rejected, got path %s", model, req.URL.Path)
+		}
+	}
+}
+
+func TestTranslateGeminiRequest(t *testing.T) {
+	req, _, body := translateRequest(t, FormatGemini, strings.Replace(chatWithTools, `"model": "m",`, `"model": "gemini-pro", "stream": true,`, 1))
+	if req.URL.Path != "/v1beta/models/gemini-pro:streamGenerateContent" || req.URL.Query().Get("alt") != "sse" {
+		t.Errorf("Unexpected url %s", req.URL)
+	}
+	if req.Header.Get("X-Goog-Api-Key") != "sk-test" || req.Header.Get("Authorization") != "" {
+		t.Errorf("Unexpected headers %v", req.Header)
+	}
+	assertJSON(t, mustJSON(body), map[string]interface{}{
+		"$.systemInstruction.parts[0].text":                        "be brief",
+		"$.contents[0].role":                                       "user",
+		"$.contents[0].parts[1].inlineData.mimeType":               "image/png",
+		"$.contents[1].role":                                       "model",
+		"$.contents[1].parts[0].functionCall.name":                 "weather",
+		"$.contents[2].parts[0].functionResponse.name":             "weather",
+		"$.contents[2].parts[0].functionResponse.response.content": "sunny",
+		"$.contents[2].parts[1].text":                              "thanks",
+		"$.generationConfig.maxOutputTokens":                       100.0,
+		"$.generationConfig.stopSequences[0]":                      "END",
+		"$.tools[0].functionDeclarations[0].name":                  "weather",
+		"$.toolConfig.functionCallingConfig.mode":                  "ANY",
+	})
+
+	// 引用未知工具调用的 tool 消息无法翻译
+	tr, _ := NewTranslator(FormatGemini)
+	bad := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"g","messages":[{"role":"tool","tool_call_id":"x","content":"1"}]}`))
+	if _, err := tr.Request(bad); err == nil {
+		t.Error("Expected error for unknown tool_call_id")
+	}
+
+	// 其他路径不翻译
+	other := httptest.NewRequest("GET", "/v1/models", nil)
+	if x, err := tr.Request(other); x != nil || err != nil || other.URL.Path != "/v1/models" {
+		t.Errorf("Expected untouched request, got %v %v %s", x, err, other.URL.Path)
+	}
+}
+
+func TestTranslateGeminiResponse(t *testing.T) {
+	_, x, _ := translateRequest(t, FormatGemini, `{"model":"gemini-pro","messages":[{"role":"user","content":"hi"}]}`)
+	got := translateResponse(t, x, 200, "application/json", `{
+		"responseId": "r1",
+		"candidates": [{"index": 0, "content": {"role": "model", "parts": [{"text": "hel"}, {"text": "lo"}]}, "finishReason": "MAX_TOKENS"}],
+		"usageMetadata": {"promptTokenCount": 3, "candidatesTokenCount": 2, "thoughtsTokenCount": 1},
+		"modelVersion": "gemini-pro-001"
+	}`)
+	assertJSON(t, got, map[string]interface{}{
+		"$.id":                         "chatcmpl-r1",
+		"$.model":                      "gemini-pro-001",
+		"$.choices[0].message.role":    "assistant",
+		"$.choices[0].message.content": "hello",
+		"$.choices[0].finish_reason":   "length",
+		"$.usage.prompt_tokens":        3.0,
+		"$.usage.completion_tokens":    3.0,
+	})
+
+	got = translateResponse(t, x, 400, "application/json", `{"error":{"code":400,"message":"bad key","status":"INVALID_ARGUMENT"}}`)
+	assertJSON(t, got, map[string]interface{}{"$.error.message": "bad key", "$.error.type": "invalid_argument"})
+}
+
+func TestTranslateGeminiStream(t *testing.T) {
+	_, x, _ := translateRequest(t, FormatGemini, `{"model":"gemini-pro","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	stream := "data: {\"responseId\":\"r1\",\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"He\"}]}}],\"usageMetadata\":{\"promptTokenCount\":3}}\r\n\r\n" +
+		"data: {\"responseId\":\"r1\",\"candidates\":[{\"content\":{\"parts\":[{\"functionCall\":{\"name\":\"f\",\"args\":{\"a\":1}}}]}}]}\r\n\r\n" +
+		"data: {\"responseId\":\"r1\",\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"\"}]},\"finishReason\":\"STOP\"}],\"usageMetadata\":{\"promptTokenCount\":3,\"candidatesTokenCount\":4}}\r\n\r\n"
+	chunks := streamData(translateResponse(t, x, 200, "text/event-stream", stream))
+
+	if len(chunks) != 5 || chunks[4] != "[DONE]" {
+		t.Fatalf("Unexpected chunks %q", chunks)
+	}
+	assertJSON(t, chunks[0], map[string]interface{}{"$.id": "chatcmpl-r1", "$.choices[0].delta.role": "assistant", "$.choices[0].delta.content": "He"})
+	assertJSON(t, chunks[1], map[string]interface{}{"$.choices[0].delta.tool_calls[0].function.name": "f", "$.choices[0].delta.tool_calls[0].index": 0.0})
+	assertJSON(t, chunks[2], map[string]interface{}{"$.choices[0].finish_reason": "tool_calls"})
+	assertJSON(t, chunks[3], map[string]interface{}{"$.usage.prompt_tokens": 3.0, "$.usage.completion_tokens": 4.0})
+}
+
+// streamData 提取 SSE 响应中每个事件的数据
+func streamData(body string) []string {
+	var data []string
+	for _, line := range strings.Split(body, "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimRight(line, "\r"), "data: "); ok {
+			data = append(data, value)
+		}
+	}
+	return data
+}
+
+func mustJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
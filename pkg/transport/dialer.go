@@ -0,0 +1,188 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// Egress 出口配置
+type Egress struct {
+	Name    string
+	Proxies []string // 按顺序尝试的代理地址，为空表示直连
+}
+
+// key 获取出口索引
+func (e Egress) key() string {
+	return e.Name + "|" + strings.Join(e.Proxies, ",")
+}
+
+// chainDialer 依次尝试多个代理的拨号器，健康的代理优先
+type chainDialer struct {
+	proxies []*url.URL
+	forward *net.Dialer
+	health  *ProxyHealth
+}
+
+// newChainDialer 创建代理链拨号器
+func newChainDialer(proxies []string, forward *net.Dialer, health *ProxyHealth) (*chainDialer, error) {
+	d := &chainDialer{forward: forward, health: health}
+	for _, raw := range proxies {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url %q: %w", redact(raw), err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+		}
+		d.proxies = append(d.proxies, u)
+	}
+	return d, nil
+}
+
+// DialContext 通过代理建立连接，失败时按顺序切换到下一个代理
+func (d *chainDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var errs []string
+	for _, u := range d.ordered() {
+		start := time.Now()
+		conn, err := dialVia(ctx, u, d.forward, network, addr)
+		if ctx.Err() != nil {
+			// 请求本身被取消，不代表代理不可用
+			return nil, ctx.Err()
+		}
+		d.health.Report(u.String(), time.Since(start), err)
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, fmt.Errorf("all egress proxies failed: %s", strings.Join(errs, "; "))
+}
+
+// ordered 健康代理在前、不健康代理在后，各自保持配置顺序
+func (d *chainDialer) ordered() []*url.URL {
+	healthy := make([]*url.URL, 0, len(d.proxies))
+	var unhealthy []*url.URL
+	for _, u := range d.proxies {
+		if d.health.Healthy(u.String()) {
+			healthy = append(healthy, u)
+		} else {
+			unhealthy = append(unhealthy, u)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// dialVia 通过单个代理建立到目标地址的连接
+func dialVia(ctx context.Context, proxyURL *url.URL, forward *net.Dialer, network, addr string) (net.Conn, error) {
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if proxyURL.User != nil {
+			password, _ := proxyURL.User.Password()
+			auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+		}
+		dialer, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, forward)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := dialer.(proxy.ContextDialer).DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, fmt.Errorf("socks5 %s: %w", proxyURL.Host, err)
+		}
+		return conn, nil
+	default:
+		return dialConnect(ctx, proxyURL, forward, addr)
+	}
+}
+
+// dialConnect 通过 HTTP CONNECT 建立隧道
+func dialConnect(ctx context.Context, proxyURL *url.URL, forward *net.Dialer, addr string) (net.Conn, error) {
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+
+	conn, err := forward.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("connect proxy %s: %w", proxyURL.Host, err)
+	}
+	if proxyURL.Scheme == "https" {
+		conn = tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+	}
+
+	// 握手期间遵循上下文截止时间
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("connect proxy %s: %w", proxyURL.Host, err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("connect proxy %s: %w", proxyURL.Host, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("connect proxy %s: %s", proxyURL.Host, resp.Status)
+	}
+
+	// 代理可能已经发送了部分隧道数据
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn 优先读取缓冲区数据的连接
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// Read 读取数据
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// redact 隐藏代理地址中的密码
+func redact(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,171 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// startConnectProxy 启动需要 Basic 认证的 HTTP CONNECT 代理
+func startConnectProxy(t *testing.T, auth string) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect || r.Header.Get("Proxy-Authorization") != auth {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go io.Copy(target, conn)
+		go io.Copy(conn, target)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// startSOCKS5Proxy 启动需要用户名密码认证的最小 SOCKS5 代理
+func startSOCKS5Proxy(t *testing.T, user, password string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+
+				// 协商认证方式，只接受用户名密码认证
+				head := make([]byte, 2)
+				io.ReadFull(r, head)
+				io.ReadFull(r, make([]byte, head[1]))
+				conn.Write([]byte{5, 2})
+
+				// 用户名密码认证
+				io.ReadFull(r, head)
+				u := make([]byte, head[1])
+				io.ReadFull(r, u)
+				l, _ := r.ReadByte()
+				p := make([]byte, l)
+				io.ReadFull(r, p)
+				if string(u) != user || string(p) != password {
+					conn.Write([]byte{1, 1})
+					return
+				}
+				conn.Write([]byte{1, 0})
+
+				// CONNECT 请求，测试中只使用 IPv4 地址
+				req := make([]byte, 4)
+				io.ReadFull(r, req)
+				addr := make([]byte, 6)
+				io.ReadFull(r, addr)
+				host := net.IP(addr[:4]).String()
+				port := binary.BigEndian.Uint16(addr[4:])
+				target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+				if err != nil {
+					conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer target.Close()
+				conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				go io.Copy(target, r)
+				io.Copy(conn, target)
+			}(conn)
+		}
+	}()
+	return "socks5://" + user + ":" + password + "@" + ln.Addr().String()
+}
+
+func TestChainDialerFallback(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	connectProxy := startConnectProxy(t, "Basic dXNlcjpwYXNz") // user:pass
+	socksProxy := startSOCKS5Proxy(t, "user", "pass")
+	withAuth := "http://user:pass@" + connectProxy[len("http://"):]
+
+	tests := []struct {
+		name    string
+		proxies []string
+		healthy bool
+	}{
+		{"http connect with auth", []string{withAuth}, true},
+		{"socks5 with auth", []string{socksProxy}, true},
+		{"fallback after dead proxy", []string{"socks5://127.0.0.1:1", withAuth}, true},
+		{"wrong credentials", []string{"socks5://user:wrong@" + socksProxy[len("socks5://user:pass@"):]}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health := NewProxyHealth()
+			chain, err := newChainDialer(tt.proxies, &net.Dialer{Timeout: time.Second}, health)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := &http.Client{Transport: &http.Transport{DialContext: chain.DialContext}}
+			resp, err := client.Get(backend.URL)
+			if !tt.healthy {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("Expected request to fail")
+				}
+				if health.Healthy(tt.proxies[0]) {
+					t.Error("Expected proxy to be reported unhealthy")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Request through proxy failed: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != "ok" {
+				t.Errorf("Unexpected body %q", body)
+			}
+		})
+	}
+}
+
+func TestCheckProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+	target := backend.Listener.Addr().String()
+	socksProxy := startSOCKS5Proxy(t, "user", "pass")
+
+	health := NewProxyHealth()
+	checker := NewProxyChecker(health, func() []string {
+		return []string{socksProxy, "socks5://127.0.0.1:1"}
+	}, target, time.Minute, time.Second)
+
+	results := checker.CheckAll(context.Background())
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if !health.Healthy(socksProxy) || health.Healthy("socks5://127.0.0.1:1") {
+		t.Errorf("Unexpected proxy health: %+v", results)
+	}
+	for _, status := range results {
+		if status.URL == socksProxy {
+			t.Error("Expected password to be redacted")
+		}
+	}
+}
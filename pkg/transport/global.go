@@ -1,20 +1,65 @@
 package transport
 
-import "sub-router/internal/config"
+import (
+	"net/http"
+	"sync"
+
+	"sub-router/internal/config"
+)
 
 var (
 	// GlobalPool 全局连接池实例
 	GlobalPool *Pool
+
+	globalMu sync.Mutex
 )
 
 // InitGlobalPool 初始化全局连接池
 func InitGlobalPool(config config.TransportPoolConfig) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
 	GlobalPool = NewPool(config)
 }
 
+// Global 获取全局连接池，未初始化时按当前配置创建
+func Global() *Pool {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	if GlobalPool == nil {
+		GlobalPool = NewPool(config.GetTransportConfig())
+	}
+	return GlobalPool
+}
+
 // CloseGlobalPool 关闭全局连接池
 func CloseGlobalPool() {
 	if GlobalPool != nil {
 		GlobalPool.CloseIdleConnections()
 	}
 }
+
+// ClientForService 获取服务对应的客户端，按服务配置选择出口和连接超时
+func ClientForService(service string) (*http.Client, error) {
+	endpoint := endpointFor(config.Get(), service)
+	return Global().ClientFor(service, endpoint.Egress, endpoint.Timeouts)
+}
+
+// PruneGlobalPool 按新配置清理全局连接池中不再使用的客户端和 Transport
+func PruneGlobalPool(cfg *config.Config) {
+	services := make(map[string]Endpoint, len(cfg.APIMappings))
+	for name := range cfg.APIMappings {
+		services[name] = endpointFor(cfg, name)
+	}
+	Global().Prune(services)
+}
+
+// endpointFor 获取服务在指定配置下的出口和连接超时
+func endpointFor(cfg *config.Config, service string) Endpoint {
+	mapping, _ := cfg.APIMapping(service)
+	name, proxies := cfg.EgressFor(mapping)
+	endpoint := Endpoint{Egress: Egress{Name: name, Proxies: proxies}}
+	if mapping.Timeouts != nil {
+		endpoint.Timeouts = Timeouts{Connect: mapping.Timeouts.Connect, TLSHandshake: mapping.Timeouts.TLSHandshake}
+	}
+	return endpoint
+}
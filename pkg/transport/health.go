@@ -0,0 +1,168 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ProxyStatus 出口代理健康状态
+type ProxyStatus struct {
+	URL       string // 已隐藏密码
+	Healthy   bool
+	Message   string
+	Latency   time.Duration
+	CheckedAt time.Time
+}
+
+// ProxyHealth 出口代理健康状态表，由拨号结果和主动检查共同更新
+type ProxyHealth struct {
+	status map[string]*ProxyStatus
+	mu     sync.RWMutex
+}
+
+// GlobalProxyHealth 全局出口代理健康状态
+var GlobalProxyHealth = NewProxyHealth()
+
+// NewProxyHealth 创建出口代理健康状态表
+func NewProxyHealth() *ProxyHealth {
+	return &ProxyHealth{
+		status: make(map[string]*ProxyStatus),
+	}
+}
+
+// Report 记录一次代理连接结果
+func (h *ProxyHealth) Report(proxyURL string, latency time.Duration, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	status, ok := h.status[proxyURL]
+	if !ok {
+		status = &ProxyStatus{URL: redact(proxyURL)}
+		h.status[proxyURL] = status
+	}
+	status.Healthy = err == nil
+	status.Message = ""
+	if err != nil {
+		status.Message = err.Error()
+	}
+	status.Latency = latency
+	status.CheckedAt = time.Now()
+}
+
+// Healthy 判断代理是否健康，未检查过的代理视为健康
+func (h *ProxyHealth) Healthy(proxyURL string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	status, ok := h.status[proxyURL]
+	return !ok || status.Healthy
+}
+
+// Status 获取指定代理的健康状态
+func (h *ProxyHealth) Status(proxyURL string) (ProxyStatus, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	status, ok := h.status[proxyURL]
+	if !ok {
+		return ProxyStatus{}, false
+	}
+	return *status, true
+}
+
+// CheckProxy 通过代理连接目标地址，检查代理是否可用及认证是否正确
+func CheckProxy(ctx context.Context, proxyURL, target string) (time.Duration, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	conn, err := dialVia(ctx, u, &net.Dialer{}, "tcp", target)
+	if err != nil {
+		return time.Since(start), err
+	}
+	conn.Close()
+	return time.Since(start), nil
+}
+
+// ProxyChecker 出口代理主动健康检查器
+type ProxyChecker struct {
+	health   *ProxyHealth
+	proxies  func() []string
+	target   string
+	interval time.Duration
+	timeout  time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewProxyChecker 创建出口代理健康检查器，proxies 在每轮检查时调用以获取最新代理列表
+func NewProxyChecker(health *ProxyHealth, proxies func() []string, target string, interval, timeout time.Duration) *ProxyChecker {
+	return &ProxyChecker{
+		health:   health,
+		proxies:  proxies,
+		target:   target,
+		interval: interval,
+		timeout:  timeout,
+	}
+}
+
+// Start 启动后台检查
+func (c *ProxyChecker) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			c.CheckAll(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop 停止后台检查
+func (c *ProxyChecker) Stop() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.wg.Wait()
+}
+
+// CheckAll 并发检查所有代理并返回结果
+func (c *ProxyChecker) CheckAll(ctx context.Context) []ProxyStatus {
+	proxies := c.proxies()
+	var wg sync.WaitGroup
+	for _, p := range proxies {
+		wg.Add(1)
+		go func(proxyURL string) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+			latency, err := CheckProxy(checkCtx, proxyURL, c.target)
+			if ctx.Err() == nil {
+				c.health.Report(proxyURL, latency, err)
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	result := make([]ProxyStatus, 0, len(proxies))
+	for _, p := range proxies {
+		if status, ok := c.health.Status(p); ok {
+			result = append(result, status)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].URL < result[j].URL })
+	return result
+}
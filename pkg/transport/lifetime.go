@@ -0,0 +1,73 @@
+package transport
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// lifetimeTransport 限制连接最长存活时间的 Transport
+//
+// http.Transport 不支持连接最大存活时间，这里在 Transport 创建满
+// lifetime 后换用新的 Transport，旧 Transport 上的连接在空闲后被关闭，
+// 从而保证新请求不会使用存活超过 lifetime 的连接。
+type lifetimeTransport struct {
+	build    func() *http.Transport
+	lifetime time.Duration
+
+	mu      sync.Mutex
+	current *http.Transport
+	created time.Time
+}
+
+// newLifetimeTransport 创建限制连接存活时间的 Transport，lifetime 为 0 时不限制
+func newLifetimeTransport(build func() *http.Transport, lifetime time.Duration) *lifetimeTransport {
+	return &lifetimeTransport{
+		build:    build,
+		lifetime: lifetime,
+		current:  build(),
+		created:  time.Now(),
+	}
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *lifetimeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.transport().RoundTrip(req)
+}
+
+// transport 获取当前 Transport，超过存活时间时轮换
+func (t *lifetimeTransport) transport() *http.Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.lifetime > 0 && time.Since(t.created) >= t.lifetime {
+		old := t.current
+		t.current = t.build()
+		t.created = time.Now()
+		retireTransport(old, t.lifetime)
+	}
+	return t.current
+}
+
+// CloseIdleConnections 关闭空闲连接
+func (t *lifetimeTransport) CloseIdleConnections() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.current.CloseIdleConnections()
+}
+
+// retire 停用当前 Transport
+func (t *lifetimeTransport) retire() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	retireTransport(t.current, t.lifetime)
+}
+
+// retireTransport 关闭旧 Transport 的空闲连接，并在进行中的请求结束后再次清理
+func retireTransport(old *http.Transport, after time.Duration) {
+	old.CloseIdleConnections()
+	if after <= 0 {
+		after = time.Minute
+	}
+	time.AfterFunc(after, old.CloseIdleConnections)
+}
@@ -11,54 +11,74 @@ import (
 )
 
 // Pool HTTP连接池管理器
+//
+// 每个 (出口, 连接超时) 组合共享一个 Transport，每个服务对应一个 Client。
 type Pool struct {
 	config config.TransportPoolConfig
 	client *http.Client
 	mu     sync.RWMutex
 
+	transports map[string]*lifetimeTransport
+	clients    map[clientKey]*http.Client
+
 	// 连接池优化
-	MaxConnsPerHost       int
 	MaxIdleConnsPerHost   int
 	IdleConnTimeout       time.Duration
+	ConnectTimeout        time.Duration
 	TLSHandshakeTimeout   time.Duration
 	ExpectContinueTimeout time.Duration
-	ResponseHeaderTimeout time.Duration
+}
+
+// Timeouts 建立连接阶段的超时，0 表示使用连接池默认值。
+//
+// 响应头、总时长和流式空闲超时与单个请求相关，由调用方通过请求上下文控制
+type Timeouts struct {
+	Connect      time.Duration
+	TLSHandshake time.Duration
+}
+
+// Endpoint 服务使用的出口和连接超时
+type Endpoint struct {
+	Egress   Egress
+	Timeouts Timeouts
+}
+
+// clientKey 客户端索引
+type clientKey struct {
+	service   string
+	transport string
+}
+
+// transportKey 获取出口和超时组合的索引
+func transportKey(egress Egress, timeouts Timeouts) string {
+	return egress.key() + "|" + timeouts.Connect.String() + "|" + timeouts.TLSHandshake.String()
 }
 
 // NewPool 创建新的连接池
 func NewPool(config config.TransportPoolConfig) *Pool {
 	p := &Pool{
 		config:                config,
-		MaxConnsPerHost:       100,
 		MaxIdleConnsPerHost:   10,
 		IdleConnTimeout:       90 * time.Second,
+		ConnectTimeout:        30 * time.Second,
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
-		ResponseHeaderTimeout: 30 * time.Second,
-	}
-
-	transport := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		ForceAttemptHTTP2:      true,
-		MaxIdleConns:           config.MaxIdleConns,
-		MaxIdleConnsPerHost:    config.MaxIdleConnsPerHost,
-		IdleConnTimeout:        config.IdleConnTimeout,
-		MaxConnsPerHost:        config.MaxIdleConnsPerHost * 2,
-		ExpectContinueTimeout:  1 * time.Second,
-		MaxResponseHeaderBytes: 4 * 1024, // 4KB
 	}
+	p.reset()
+	return p
+}
 
-	p.client = &http.Client{
-		Transport: transport,
-		Timeout:   30 * time.Second,
+// reset 重建所有 Transport 和 Client，调用方需持有写锁
+func (p *Pool) reset() {
+	for _, t := range p.transports {
+		t.retire()
 	}
+	p.transports = make(map[string]*lifetimeTransport)
+	p.clients = make(map[clientKey]*http.Client)
 
-	p.optimizeConnPool()
-	return p
+	direct, _ := p.transportFor(Egress{}, Timeouts{})
+	// 不设置 Client.Timeout：总时长会截断流式响应，超时由调用方通过上下文控制
+	p.client = &http.Client{Transport: direct}
 }
 
 // Client 获取HTTP客户端
@@ -68,46 +88,143 @@ func (p *Pool) Client() *http.Client {
 	return p.client
 }
 
-// UpdateConfig 更新连接池配置
-func (p *Pool) UpdateConfig(config config.TransportPoolConfig) {
+// ClientFor 获取指定服务、出口和连接超时的客户端
+func (p *Pool) ClientFor(service string, egress Egress, timeouts Timeouts) (*http.Client, error) {
+	key := clientKey{service: service, transport: transportKey(egress, timeouts)}
+
+	p.mu.RLock()
+	client, ok := p.clients[key]
+	p.mu.RUnlock()
+	if ok {
+		return client, nil
+	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	if client, ok := p.clients[key]; ok {
+		return client, nil
+	}
 
-	transport := p.client.Transport.(*http.Transport)
-	transport.MaxIdleConns = config.MaxIdleConns
-	transport.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
-	transport.IdleConnTimeout = config.IdleConnTimeout
+	transport, err := p.transportFor(egress, timeouts)
+	if err != nil {
+		return nil, err
+	}
+	// 总超时由请求上下文控制，这里不设置 Client.Timeout
+	client = &http.Client{Transport: transport}
+	p.clients[key] = client
+	return client, nil
+}
+
+// transportFor 获取出口和超时组合对应的 Transport，调用方需持有写锁
+func (p *Pool) transportFor(egress Egress, timeouts Timeouts) (*lifetimeTransport, error) {
+	key := transportKey(egress, timeouts)
+	if t, ok := p.transports[key]; ok {
+		return t, nil
+	}
+
+	// 先解析一次以便尽早发现代理配置错误
+	if _, err := newChainDialer(egress.Proxies, nil, nil); err != nil {
+		return nil, err
+	}
+	cfg := p.config
+	t := newLifetimeTransport(func() *http.Transport {
+		return p.newTransport(cfg, egress, timeouts)
+	}, cfg.MaxConnLifetime)
+	p.transports[key] = t
+	return t, nil
+}
+
+// newTransport 根据配置创建 Transport
+func (p *Pool) newTransport(cfg config.TransportPoolConfig, egress Egress, timeouts Timeouts) *http.Transport {
+	if timeouts.Connect <= 0 {
+		timeouts.Connect = p.ConnectTimeout
+	}
+	if timeouts.TLSHandshake <= 0 {
+		timeouts.TLSHandshake = p.TLSHandshakeTimeout
+	}
+	dialer := &net.Dialer{
+		Timeout:   timeouts.Connect,
+		KeepAlive: 30 * time.Second,
+	}
 
-	if config.InsecureSkipVerify {
+	transport := &http.Transport{
+		Proxy:                  http.ProxyFromEnvironment,
+		DialContext:            dialer.DialContext,
+		ForceAttemptHTTP2:      true,
+		MaxIdleConns:           cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:    cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:        cfg.IdleConnTimeout,
+		MaxConnsPerHost:        cfg.MaxConnsPerHost,
+		TLSHandshakeTimeout:    timeouts.TLSHandshake,
+		ExpectContinueTimeout:  p.ExpectContinueTimeout,
+		MaxResponseHeaderBytes: cfg.MaxResponseHeaderBytes,
+	}
+	if transport.MaxResponseHeaderBytes <= 0 {
+		transport.MaxResponseHeaderBytes = config.DefaultMaxResponseHeaderBytes
+	}
+	if transport.MaxIdleConnsPerHost <= 0 {
+		transport.MaxIdleConnsPerHost = p.MaxIdleConnsPerHost
+	}
+	if transport.IdleConnTimeout <= 0 {
+		transport.IdleConnTimeout = p.IdleConnTimeout
+	}
+	if cfg.InsecureSkipVerify {
 		transport.TLSClientConfig = &tls.Config{
 			InsecureSkipVerify: true,
 		}
 	}
 
+	// 配置出口代理：所有代理都在拨号阶段建立隧道，不再使用环境变量中的代理
+	if len(egress.Proxies) > 0 {
+		chain, _ := newChainDialer(egress.Proxies, dialer, GlobalProxyHealth)
+		transport.Proxy = nil
+		transport.DialContext = chain.DialContext
+	}
+	return transport
+}
+
+// UpdateConfig 更新连接池配置，配置变化时重建所有 Transport
+func (p *Pool) UpdateConfig(config config.TransportPoolConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.config == config {
+		return
+	}
 	p.config = config
+	p.reset()
+}
+
+// Prune 移除已删除的服务以及出口、连接超时已变化的客户端，并停用不再被使用的 Transport。
+//
+// services 为各服务当前的出口和连接超时，停用的 Transport 会关闭空闲连接，进行中的请求不受影响
+func (p *Pool) Prune(services map[string]Endpoint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// 默认客户端使用的直连 Transport 始终保留
+	used := map[string]bool{transportKey(Egress{}, Timeouts{}): true}
+	for key := range p.clients {
+		endpoint, ok := services[key.service]
+		if !ok || transportKey(endpoint.Egress, endpoint.Timeouts) != key.transport {
+			delete(p.clients, key)
+			continue
+		}
+		used[key.transport] = true
+	}
+	for key, t := range p.transports {
+		if !used[key] {
+			t.retire()
+			delete(p.transports, key)
+		}
+	}
 }
 
 // CloseIdleConnections 关闭所有空闲连接
 func (p *Pool) CloseIdleConnections() {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
-	p.client.Transport.(*http.Transport).CloseIdleConnections()
-}
-
-// 优化连接池配置
-func (p *Pool) optimizeConnPool() {
-	transport := p.client.Transport.(*http.Transport)
-
-	// 设置最大连接数
-	transport.MaxConnsPerHost = p.MaxConnsPerHost
-	transport.MaxIdleConnsPerHost = p.MaxIdleConnsPerHost
-
-	// 设置超时
-	transport.IdleConnTimeout = p.IdleConnTimeout
-	transport.TLSHandshakeTimeout = p.TLSHandshakeTimeout
-	transport.ExpectContinueTimeout = p.ExpectContinueTimeout
-	transport.ResponseHeaderTimeout = p.ResponseHeaderTimeout
-
-	// 启用 HTTP/2
-	transport.ForceAttemptHTTP2 = true
+	for _, t := range p.transports {
+		t.CloseIdleConnections()
+	}
 }
@@ -0,0 +1,120 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"sub-router/internal/config"
+)
+
+func TestPoolClientFor(t *testing.T) {
+	pool := NewPool(config.TransportPoolConfig{MaxIdleConns: 10, MaxIdleConnsPerHost: 2})
+
+	// 相同服务和代理复用客户端
+	a, err := pool.ClientFor("openai", Egress{}, Timeouts{})
+	if err != nil {
+		t.Fatalf("ClientFor: %v", err)
+	}
+	if b, _ := pool.ClientFor("openai", Egress{}, Timeouts{}); a != b {
+		t.Error("Expected client to be reused")
+	}
+
+	// 不同服务共享同一出口的 Transport
+	c, _ := pool.ClientFor("claude", Egress{}, Timeouts{})
+	if c == a || c.Transport != a.Transport {
+		t.Error("Expected separate clients sharing one transport")
+	}
+
+	// 不同出口使用不同 Transport
+	d, err := pool.ClientFor("openai", Egress{Name: "us", Proxies: []string{"socks5://127.0.0.1:1080"}}, Timeouts{})
+	if err != nil {
+		t.Fatalf("ClientFor socks5: %v", err)
+	}
+	if d.Transport == a.Transport {
+		t.Error("Expected separate transport per proxy")
+	}
+
+	// 不同连接超时使用不同 Transport
+	f, _ := pool.ClientFor("openai", Egress{}, Timeouts{Connect: time.Second})
+	if f == a || f.Transport == a.Transport {
+		t.Error("Expected separate transport per connect timeout")
+	}
+	if handshake := f.Transport.(*lifetimeTransport).transport().TLSHandshakeTimeout; handshake != pool.TLSHandshakeTimeout {
+		t.Errorf("Expected default TLS handshake timeout, got %v", handshake)
+	}
+
+	// 默认不限制每个主机的连接数，响应头上限使用默认值
+	if tr := f.Transport.(*lifetimeTransport).transport(); tr.MaxConnsPerHost != 0 || tr.MaxResponseHeaderBytes != config.DefaultMaxResponseHeaderBytes {
+		t.Errorf("Unexpected connection limits %d %d", tr.MaxConnsPerHost, tr.MaxResponseHeaderBytes)
+	}
+
+	// 配置变化后重建
+	pool.UpdateConfig(config.TransportPoolConfig{MaxIdleConns: 20, InsecureSkipVerify: true, MaxConnsPerHost: 50, MaxResponseHeaderBytes: 64 << 10})
+	e, _ := pool.ClientFor("openai", Egress{}, Timeouts{})
+	if e == a {
+		t.Error("Expected clients to be rebuilt after config change")
+	}
+	if tr := e.Transport.(*lifetimeTransport).transport(); tr.MaxConnsPerHost != 50 || tr.MaxResponseHeaderBytes != 64<<10 {
+		t.Errorf("Expected configured connection limits, got %d %d", tr.MaxConnsPerHost, tr.MaxResponseHeaderBytes)
+	}
+	if tls := e.Transport.(*lifetimeTransport).transport().TLSClientConfig; tls == nil || !tls.InsecureSkipVerify {
+		t.Error("Expected TLSSkipVerify to be applied")
+	}
+}
+
+func TestPoolPrune(t *testing.T) {
+	pool := NewPool(config.TransportPoolConfig{})
+	proxied := Egress{Name: "us", Proxies: []string{"socks5://127.0.0.1:1080"}}
+
+	a, _ := pool.ClientFor("openai", proxied, Timeouts{})
+	b, _ := pool.ClientFor("claude", Egress{}, Timeouts{Connect: time.Second})
+	c, _ := pool.ClientFor("gemini", Egress{}, Timeouts{})
+
+	// openai 改为直连，claude 被删除，gemini 不变
+	pool.Prune(map[string]Endpoint{
+		"openai": {},
+		"gemini": {},
+	})
+	if len(pool.transports) != 1 {
+		t.Errorf("Expected only the direct transport to remain, got %d", len(pool.transports))
+	}
+	if got, _ := pool.ClientFor("gemini", Egress{}, Timeouts{}); got != c {
+		t.Error("Expected unchanged client to be kept")
+	}
+	if got, _ := pool.ClientFor("openai", Egress{}, Timeouts{}); got == a || got.Transport != c.Transport {
+		t.Error("Expected openai to use the shared direct transport")
+	}
+	if got, _ := pool.ClientFor("claude", Egress{}, Timeouts{Connect: time.Second}); got == b || got.Transport == b.Transport {
+		t.Error("Expected removed service transport to be rebuilt")
+	}
+}
+
+func TestLifetimeTransportRotation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	lt := newLifetimeTransport(func() *http.Transport { return &http.Transport{} }, 50*time.Millisecond)
+	first := lt.transport()
+
+	client := &http.Client{Transport: lt}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	// 未超过存活时间时继续使用同一 Transport
+	if lt.transport() != first {
+		t.Error("Transport rotated before lifetime")
+	}
+
+	// 超过存活时间后轮换
+	time.Sleep(60 * time.Millisecond)
+	if lt.transport() == first {
+		t.Error("Expected transport to rotate after lifetime")
+	}
+}
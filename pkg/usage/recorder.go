@@ -0,0 +1,117 @@
+package usage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"sub-router/pkg/metrics"
+)
+
+// Record 一条用量记录
+type Record struct {
+	Time    time.Time `json:"time"`
+	TraceID string    `json:"trace_id,omitempty"`
+	Client  string    `json:"client"`
+	Service string    `json:"service"`
+	Method  string    `json:"method"`
+	Path    string    `json:"path"`
+	Status  int       `json:"status"`
+	Stream  bool      `json:"stream"`
+	Latency float64   `json:"latency_ms"`
+	Usage
+}
+
+// Sink 用量记录的接收端
+type Sink interface {
+	Record(rec Record)
+}
+
+// Recorder 记录用量指标并分发给各接收端
+type Recorder struct {
+	mu    sync.RWMutex
+	sinks []Sink
+}
+
+// GlobalRecorder 全局用量记录器
+var GlobalRecorder = &Recorder{}
+
+// Add 添加接收端
+func (r *Recorder) Add(sink Sink) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sinks = append(r.sinks, sink)
+}
+
+// Remove 移除接收端
+func (r *Recorder) Remove(sink Sink) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, s := range r.sinks {
+		if s == sink {
+			r.sinks = append(r.sinks[:i:i], r.sinks[i+1:]...)
+			return
+		}
+	}
+}
+
+// Record 记录一条用量
+func (r *Recorder) Record(rec Record) {
+	model := rec.Model
+	if model == "" {
+		model = "unknown"
+	}
+	metrics.TokenUsage.WithLabelValues(rec.Client, rec.Service, model, "prompt").Add(float64(rec.PromptTokens))
+	metrics.TokenUsage.WithLabelValues(rec.Client, rec.Service, model, "completion").Add(float64(rec.CompletionTokens))
+	if rec.CachedTokens > 0 {
+		metrics.TokenUsage.WithLabelValues(rec.Client, rec.Service, model, "cached").Add(float64(rec.CachedTokens))
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, sink := range r.sinks {
+		sink.Record(rec)
+	}
+}
+
+// FileLog 追加写入的用量日志，每行一条 JSON 记录
+type FileLog struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// OpenFileLog 打开用量日志，文件不存在时创建
+func OpenFileLog(path string) (*FileLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileLog{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// Record 写入一条记录，单条记录在一次 write 调用中完成，不会与其他记录交错
+func (l *FileLog) Record(rec Record) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		l.enc.Encode(rec)
+	}
+}
+
+// Close 关闭日志文件
+func (l *FileLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
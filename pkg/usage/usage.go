@@ -0,0 +1,226 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Usage 一次请求的 token 用量
+type Usage struct {
+	Model            string `json:"model,omitempty"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	TotalTokens      int64  `json:"total_tokens"`
+	CachedTokens     int64  `json:"cached_tokens,omitempty"` // 命中提示词缓存的部分，已计入 PromptTokens
+}
+
+// Empty 判断是否没有解析到任何用量
+func (u Usage) Empty() bool {
+	return u.PromptTokens == 0 && u.CompletionTokens == 0 && u.TotalTokens == 0
+}
+
+// merge 合并后到的用量，非零项覆盖之前的值。
+//
+// 流式响应中 Anthropic 的 message_delta 和 Gemini 的每个分块携带的都是累计值，
+// OpenAI 只在最后一个分块中携带用量，因此总是以最新的非零值为准
+func (u *Usage) merge(other Usage) {
+	if other.Model != "" {
+		u.Model = other.Model
+	}
+	if other.PromptTokens > 0 {
+		u.PromptTokens = other.PromptTokens
+	}
+	if other.CompletionTokens > 0 {
+		u.CompletionTokens = other.CompletionTokens
+	}
+	if other.TotalTokens > 0 {
+		u.TotalTokens = other.TotalTokens
+	}
+	if other.CachedTokens > 0 {
+		u.CachedTokens = other.CachedTokens
+	}
+}
+
+// finish 补全总量
+func (u *Usage) finish() {
+	if u.TotalTokens < u.PromptTokens+u.CompletionTokens {
+		u.TotalTokens = u.PromptTokens + u.CompletionTokens
+	}
+}
+
+// payload 兼容 OpenAI、Anthropic 和 Gemini 响应的用量字段
+type payload struct {
+	Model        string       `json:"model"`
+	ModelVersion string       `json:"modelVersion"` // Gemini
+	Usage        *usageBlock  `json:"usage"`        // OpenAI、Anthropic
+	Metadata     *geminiUsage `json:"usageMetadata"`
+	Message      *payload     `json:"message"`  // Anthropic message_start 事件
+	Response     *payload     `json:"response"` // OpenAI Responses API 的 response.completed 事件
+}
+
+// usageBlock OpenAI（prompt/completion）和 Anthropic、OpenAI Responses API（input/output）的用量
+type usageBlock struct {
+	PromptTokens        int64 `json:"prompt_tokens"`
+	CompletionTokens    int64 `json:"completion_tokens"`
+	TotalTokens         int64 `json:"total_tokens"`
+	PromptTokensDetails struct {
+		CachedTokens int64 `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+	InputTokensDetails       struct {
+		CachedTokens int64 `json:"cached_tokens"`
+	} `json:"input_tokens_details"`
+}
+
+// geminiUsage Gemini 的 usageMetadata
+type geminiUsage struct {
+	PromptTokenCount        int64 `json:"promptTokenCount"`
+	CandidatesTokenCount    int64 `json:"candidatesTokenCount"`
+	ThoughtsTokenCount      int64 `json:"thoughtsTokenCount"`
+	TotalTokenCount         int64 `json:"totalTokenCount"`
+	CachedContentTokenCount int64 `json:"cachedContentTokenCount"`
+}
+
+// usage 提取用量
+func (p *payload) usage() Usage {
+	var u Usage
+	switch {
+	case p.Message != nil:
+		u = p.Message.usage()
+	case p.Response != nil:
+		u = p.Response.usage()
+	}
+
+	if p.Model != "" {
+		u.Model = p.Model
+	} else if p.ModelVersion != "" {
+		u.Model = p.ModelVersion
+	}
+	if b := p.Usage; b != nil {
+		u.merge(Usage{
+			PromptTokens:     b.PromptTokens + b.InputTokens + b.CacheCreationInputTokens + b.CacheReadInputTokens,
+			CompletionTokens: b.CompletionTokens + b.OutputTokens,
+			TotalTokens:      b.TotalTokens,
+			CachedTokens:     b.PromptTokensDetails.CachedTokens + b.InputTokensDetails.CachedTokens + b.CacheReadInputTokens,
+		})
+	}
+	if m := p.Metadata; m != nil {
+		u.merge(Usage{
+			PromptTokens:     m.PromptTokenCount,
+			CompletionTokens: m.CandidatesTokenCount + m.ThoughtsTokenCount,
+			TotalTokens:      m.TotalTokenCount,
+			CachedTokens:     m.CachedContentTokenCount,
+		})
+	}
+	return u
+}
+
+// Parse 解析非流式 JSON 响应中的用量，支持 Gemini 流式接口返回的 JSON 数组
+func Parse(body []byte) (Usage, bool) {
+	body = bytes.TrimSpace(body)
+	var u Usage
+	if len(body) > 0 && body[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(body, &items); err != nil {
+			return u, false
+		}
+		for _, item := range items {
+			if v, ok := parseObject(item); ok {
+				u.merge(v)
+			}
+		}
+	} else if v, ok := parseObject(body); ok {
+		u = v
+	}
+	u.finish()
+	return u, !u.Empty()
+}
+
+// parseObject 解析单个 JSON 对象
+func parseObject(data []byte) (Usage, bool) {
+	var p payload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Usage{}, false
+	}
+	return p.usage(), true
+}
+
+// usageField 携带用量的 JSON 字段前缀（usage、usageMetadata）
+var usageField = []byte(`"usage`)
+
+// StreamParser 从 SSE 或 NDJSON 流中增量解析用量，按行处理，不缓存整个响应
+type StreamParser struct {
+	usage   Usage
+	line    []byte
+	maxLine int
+	skip    bool // 当前行超过 maxLine，丢弃到行尾
+}
+
+// NewStreamParser 创建流式解析器，maxLine 为单行最大长度
+func NewStreamParser(maxLine int) *StreamParser {
+	if maxLine <= 0 {
+		maxLine = 1 << 20
+	}
+	return &StreamParser{maxLine: maxLine}
+}
+
+// Write 写入流数据，总是返回 len(p)
+func (s *StreamParser) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			s.append(p)
+			break
+		}
+		s.append(p[:i])
+		if !s.skip {
+			s.parseLine(s.line)
+		}
+		s.line, s.skip = s.line[:0], false
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// append 追加到当前行
+func (s *StreamParser) append(p []byte) {
+	if s.skip {
+		return
+	}
+	if len(s.line)+len(p) > s.maxLine {
+		s.line, s.skip = s.line[:0], true
+		return
+	}
+	s.line = append(s.line, p...)
+}
+
+// parseLine 解析一行：SSE 的 data 字段或 NDJSON 的一个对象
+func (s *StreamParser) parseLine(line []byte) {
+	line = bytes.TrimSpace(line)
+	if data, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+		line = bytes.TrimSpace(data)
+	}
+	// 只解析携带用量的事件，内容分块无需反序列化；各家携带用量的事件都同时带有模型名
+	if len(line) == 0 || line[0] != '{' || !bytes.Contains(line, usageField) {
+		return
+	}
+	if u, ok := parseObject(line); ok {
+		s.usage.merge(u)
+	}
+}
+
+// Usage 获取目前解析到的用量
+func (s *StreamParser) Usage() (Usage, bool) {
+	if len(s.line) > 0 && !s.skip {
+		s.parseLine(s.line)
+		s.line = s.line[:0]
+	}
+	u := s.usage
+	u.finish()
+	return u, !u.Empty()
+}
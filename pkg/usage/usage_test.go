@@ -0,0 +1,135 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want Usage
+	}{
+		{
+			name: "openai",
+			body: `{"id":"chatcmpl-1","model":"gpt-4o","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":5,"total_tokens":17,"prompt_tokens_details":{"cached_tokens":4}}}`,
+			want: Usage{Model: "gpt-4o", PromptTokens: 12, CompletionTokens: 5, TotalTokens: 17, CachedTokens: 4},
+		},
+		{
+			name: "anthropic",
+			body: `{"id":"msg_1","model":"claude-sonnet-4","content":[],"usage":{"input_tokens":10,"cache_read_input_tokens":20,"output_tokens":7}}`,
+			want: Usage{Model: "claude-sonnet-4", PromptTokens: 30, CompletionTokens: 7, TotalTokens: 37, CachedTokens: 20},
+		},
+		{
+			name: "gemini",
+			body: `{"candidates":[],"modelVersion":"gemini-2.0-flash","usageMetadata":{"promptTokenCount":8,"candidatesTokenCount":3,"totalTokenCount":11}}`,
+			want: Usage{Model: "gemini-2.0-flash", PromptTokens: 8, CompletionTokens: 3, TotalTokens: 11},
+		},
+		{
+			name: "gemini stream array",
+			body: `[{"usageMetadata":{"promptTokenCount":8,"candidatesTokenCount":1}},{"modelVersion":"gemini-pro","usageMetadata":{"promptTokenCount":8,"candidatesTokenCount":6,"totalTokenCount":14}}]`,
+			want: Usage{Model: "gemini-pro", PromptTokens: 8, CompletionTokens: 6, TotalTokens: 14},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Parse([]byte(tt.body))
+			if !ok || got != tt.want {
+				t.Errorf("Parse() = %+v, %v, want %+v", got, ok, tt.want)
+			}
+		})
+	}
+
+	if _, ok := Parse([]byte(`{"object":"list","data":[]}`)); ok {
+		t.Error("Expected no usage for response without usage block")
+	}
+}
+
+func TestStreamParser(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   Usage
+	}{
+		{
+			name: "openai",
+			stream: "data: {\"model\":\"gpt-4o\",\"choices\":[{\"delta\":{\"content\":\"hi\"}}],\"usage\":null}\n\n" +
+				"data: {\"model\":\"gpt-4o\",\"choices\":[],\"usage\":{\"prompt_tokens\":9,\"completion_tokens\":2,\"total_tokens\":11}}\n\n" +
+				"data: [DONE]\n\n",
+			want: Usage{Model: "gpt-4o", PromptTokens: 9, CompletionTokens: 2, TotalTokens: 11},
+		},
+		{
+			name: "anthropic",
+			stream: "event: message_start\n" +
+				"data: {\"type\":\"message_start\",\"message\":{\"model\":\"claude-sonnet-4\",\"usage\":{\"input_tokens\":25,\"output_tokens\":1}}}\n\n" +
+				"event: content_block_delta\n" +
+				"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"hello\"}}\n\n" +
+				"event: message_delta\n" +
+				"data: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":15}}\n\n",
+			want: Usage{Model: "claude-sonnet-4", PromptTokens: 25, CompletionTokens: 15, TotalTokens: 40},
+		},
+		{
+			name: "gemini",
+			stream: "data: {\"modelVersion\":\"gemini-pro\",\"usageMetadata\":{\"promptTokenCount\":4,\"candidatesTokenCount\":1,\"totalTokenCount\":5}}\r\n\r\n" +
+				"data: {\"modelVersion\":\"gemini-pro\",\"usageMetadata\":{\"promptTokenCount\":4,\"candidatesTokenCount\":9,\"totalTokenCount\":13}}\r\n\r\n",
+			want: Usage{Model: "gemini-pro", PromptTokens: 4, CompletionTokens: 9, TotalTokens: 13},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 按小块写入，模拟跨分块的行
+			parser := NewStreamParser(0)
+			for i := 0; i < len(tt.stream); i += 7 {
+				end := i + 7
+				if end > len(tt.stream) {
+					end = len(tt.stream)
+				}
+				parser.Write([]byte(tt.stream[i:end]))
+			}
+			got, ok := parser.Usage()
+			if !ok || got != tt.want {
+				t.Errorf("Usage() = %+v, %v, want %+v", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage", "usage.log")
+	log, err := OpenFileLog(path)
+	if err != nil {
+		t.Fatalf("OpenFileLog: %v", err)
+	}
+	recorder := &Recorder{}
+	recorder.Add(log)
+	recorder.Record(Record{Client: "team-a", Service: "openai", Usage: Usage{Model: "gpt-4o", PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5}})
+	recorder.Record(Record{Client: "team-b", Service: "claude", Usage: Usage{PromptTokens: 1, CompletionTokens: 1, TotalTokens: 2}})
+	log.Close()
+
+	// 重新打开后追加写入
+	log, _ = OpenFileLog(path)
+	log.Record(Record{Client: "team-a", Service: "openai"})
+	log.Close()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("Invalid log line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, rec)
+	}
+	if len(records) != 3 || records[0].Model != "gpt-4o" || records[0].TotalTokens != 5 || records[1].Client != "team-b" {
+		t.Errorf("Unexpected records: %+v", records)
+	}
+}
@@ -13,11 +13,11 @@ import (
 
 func BenchmarkProxyHandler(b *testing.B) {
 	// 设置测试配置
-	config.GlobalConfig = config.Config{
-		APIMappings: map[string]string{
-			"test": "http://localhost:8888",
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"test": {URL: "http://localhost:8888"},
 		},
-	}
+	})
 
 	// 创建测试服务器
 	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -26,7 +26,11 @@ func BenchmarkProxyHandler(b *testing.B) {
 	defer backend.Close()
 
 	// 更新测试配置
-	config.GlobalConfig.APIMappings["test"] = backend.URL
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"test": {URL: backend.URL},
+		},
+	})
 
 	// 创建路由
 	gin.SetMode(gin.ReleaseMode)
@@ -40,11 +40,11 @@ func TestProxyIntegration(t *testing.T) {
 	defer testServer.Close()
 
 	// 配置代理
-	config.GlobalConfig = config.Config{
-		APIMappings: map[string]string{
-			"test": testServer.URL, // 使用测试服务器的 URL
+	config.Set(&config.Config{
+		APIMappings: map[string]config.APIMapping{
+			"test": {URL: testServer.URL}, // 使用测试服务器的 URL
 		},
-	}
+	})
 
 	// 创建负载均衡器
 	balancer := loadbalance.NewRoundRobinBalancer()